@@ -0,0 +1,42 @@
+package pongo2
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrAccessDenied is returned (wrapped) when a template accesses a struct
+// field, method or map key denied by the template set's AccessPolicy.
+var ErrAccessDenied = errors.New("access denied by access policy")
+
+// AccessPolicy decides which struct fields, methods and map keys of Go values
+// can be reached from templates. It's consulted for every field, method and
+// map key access during variable resolution (`user.Name`, `user["Name"]`,
+// `user.DisplayName()`), see TemplateSet.AccessPolicy. The `in` operator, the
+// `in` test and the dictsort filter treat denied fields and map keys as
+// missing, and `{% for %}` skips denied map keys. Filters serializing whole
+// values (like json_script) and Go code using the Value API (GetItem,
+// Contains, Element, Iterate) aren't covered.
+//
+// Unlike Options.DisableNestedFunctions, which is all-or-nothing, a policy
+// can e.g. expose `User.DisplayName()` while hiding `User.PasswordHash`.
+// Implementations must be safe for concurrent use.
+type AccessPolicy interface {
+	// AllowField reports whether the field of the struct type typ may be
+	// accessed. For promoted fields of embedded structs, typ is the outer
+	// struct type and field.Index has more than one element.
+	AllowField(typ reflect.Type, field reflect.StructField) bool
+
+	// AllowMethod reports whether the method of typ may be accessed (and
+	// called). method.Type includes the receiver as first input argument.
+	AllowMethod(typ reflect.Type, method reflect.Method) bool
+
+	// AllowMapKey reports whether the key of the map type typ may be accessed.
+	AllowMapKey(typ reflect.Type, key reflect.Value) bool
+}
+
+// accessDenied returns the error for a denied access on typ.
+func (vr *variableResolver) accessDenied(kind string, name any, typ reflect.Type) error {
+	return fmt.Errorf("%w: %s '%v' of %s (variable %s)", ErrAccessDenied, kind, name, typ, vr.String())
+}
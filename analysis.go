@@ -0,0 +1,447 @@
+package pongo2
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TemplateAnalysis is the result of Template.Analyze. It describes what a
+// template uses, found by walking its parsed document (without executing it).
+type TemplateAnalysis struct {
+	// Variables are the context variables the template reads, i.e. the
+	// variables which aren't set by the template itself (by for, set, with,
+	// macro arguments etc.) before they're used, one entry per distinct path,
+	// sorted by path. Variables set in conditional bodies (if branches, loops)
+	// are only considered set within those bodies.
+	Variables []VariableUse
+
+	// Filters, Tests and Tags are the names of the filters, tests and tags
+	// used by the template (sorted).
+	Filters []string
+	Tests   []string
+	Tags    []string
+
+	// Blocks are the blocks defined by the template, OverriddenBlocks the
+	// ones of them which are defined by a parent template, too (sorted).
+	Blocks           []string
+	OverriddenBlocks []string
+
+	// Macros are the macros defined by the template, ImportedMacros the
+	// names macros are imported as (sorted).
+	Macros         []string
+	ImportedMacros []string
+
+	// Dependencies are the targets of all extends, include, import and ssi
+	// tags of the template, in the order of appearance.
+	Dependencies []TemplateDependency
+}
+
+// VariableUse is a context variable read by a template.
+type VariableUse struct {
+	// Name is the name of the variable (the context key).
+	Name string
+
+	// Path is the attribute path read, like user.address.city. Literal
+	// subscripts are part of it (user["name"] becomes user.name); the path
+	// ends before a subscript with a non-literal value and after a function
+	// call.
+	Path string
+
+	// Position of the first use of the path
+	Line   int
+	Column int
+}
+
+// TemplateDependency is the target of an extends, include, import or ssi tag.
+type TemplateDependency struct {
+	// Kind is "extends", "include", "import" or "ssi".
+	Kind string
+
+	// Name is the (resolved) filename of the target. It's empty for includes
+	// with a filename evaluated at execution time (Dynamic).
+	Name    string
+	Dynamic bool
+
+	// Template is the compiled target, if it has been compiled along with
+	// the template (nil for dynamic includes and ssi without "parsed").
+	Template *Template
+
+	// Position of the tag
+	Line   int
+	Column int
+}
+
+// VariableNames returns the distinct names of the context variables the
+// template reads (sorted).
+func (a *TemplateAnalysis) VariableNames() []string {
+	var names []string
+	for _, v := range a.Variables {
+		if !slices.Contains(names, v.Name) {
+			names = append(names, v.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Analyze walks the template's parsed document and returns what it uses:
+// the context variables read, the filters, tests and tags, the blocks and
+// macros and the templates it depends on.
+//
+// Only this template is analyzed; the templates it depends on (like its
+// parent) can be analyzed through TemplateDependency.Template. The arguments
+// and bodies of custom tags are only analyzed if their nodes implement
+// IInspectableNodeTag.
+func (tpl *Template) Analyze() *TemplateAnalysis {
+	tpl.compileMu.Lock()
+	defer tpl.compileMu.Unlock()
+
+	a := &analyzer{
+		tpl:       tpl,
+		scopes:    []map[string]bool{{"pongo2": true, "_": true}},
+		variables: make(map[string]VariableUse),
+		filters:   make(map[string]bool),
+		tests:     make(map[string]bool),
+		tags:      make(map[string]bool),
+		blocks:    make(map[string]bool),
+		macros:    make(map[string]bool),
+		imported:  make(map[string]bool),
+	}
+	if tpl.root != nil {
+		a.nodes(tpl.root.Nodes)
+	}
+
+	result := &TemplateAnalysis{
+		Filters:        slices.Sorted(maps.Keys(a.filters)),
+		Tests:          slices.Sorted(maps.Keys(a.tests)),
+		Tags:           slices.Sorted(maps.Keys(a.tags)),
+		Blocks:         slices.Sorted(maps.Keys(a.blocks)),
+		Macros:         slices.Sorted(maps.Keys(a.macros)),
+		ImportedMacros: slices.Sorted(maps.Keys(a.imported)),
+		Dependencies:   a.dependencies,
+	}
+	for _, path := range slices.Sorted(maps.Keys(a.variables)) {
+		// Macros can be called before they're defined
+		if v := a.variables[path]; !a.macros[v.Name] {
+			result.Variables = append(result.Variables, v)
+		}
+	}
+	for _, name := range result.Blocks {
+		for parent := tpl.parent; parent != nil; parent = parent.parent {
+			if _, ok := parent.blocks[name]; ok {
+				result.OverriddenBlocks = append(result.OverriddenBlocks, name)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// analyzer collects the information of a TemplateAnalysis.
+type analyzer struct {
+	tpl *Template
+
+	// scopes are the names set by the template, innermost last
+	scopes []map[string]bool
+
+	variables    map[string]VariableUse // by path
+	filters      map[string]bool
+	tests        map[string]bool
+	tags         map[string]bool
+	blocks       map[string]bool
+	macros       map[string]bool
+	imported     map[string]bool
+	dependencies []TemplateDependency
+}
+
+// bind sets names in the innermost scope.
+func (a *analyzer) bind(names ...string) {
+	for _, name := range names {
+		if name != "" {
+			a.scopes[len(a.scopes)-1][name] = true
+		}
+	}
+}
+
+// scoped calls fn within a new scope in which names are set.
+func (a *analyzer) scoped(fn func(), names ...string) {
+	a.scopes = append(a.scopes, make(map[string]bool))
+	a.bind(names...)
+	fn()
+	a.scopes = a.scopes[:len(a.scopes)-1]
+}
+
+// conditional analyzes wrapper, which isn't necessarily executed, in a new
+// scope: variables set in it are not bound after it.
+func (a *analyzer) conditional(wrapper *NodeWrapper) {
+	a.scoped(func() { a.wrapper(wrapper) })
+}
+
+func (a *analyzer) isBound(name string) bool {
+	for _, scope := range a.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// dependency records the target of an extends, include, import or ssi tag.
+func (a *analyzer) dependency(kind, name string, tpl *Template, token *Token) {
+	dep := TemplateDependency{Kind: kind, Name: name, Dynamic: name == "", Template: tpl}
+	if dep.Template == nil && name != "" {
+		for _, t := range a.tpl.dependencies {
+			if t.name == name {
+				dep.Template = t
+				break
+			}
+		}
+	}
+	if token != nil {
+		dep.Line, dep.Column = token.Line, token.Col
+	}
+	a.dependencies = append(a.dependencies, dep)
+}
+
+func (a *analyzer) nodes(nodes []INode) {
+	for _, n := range nodes {
+		a.node(n)
+	}
+}
+
+func (a *analyzer) wrapper(wrapper *NodeWrapper) {
+	if wrapper != nil {
+		a.nodes(wrapper.nodes)
+	}
+}
+
+func (a *analyzer) node(n INode) {
+	switch n := n.(type) {
+	case *nodeVariable:
+		a.expr(n.expr)
+	case *NodeWrapper:
+		a.wrapper(n)
+	case *nodeTag:
+		a.tags[n.name] = true
+		a.tag(n)
+	}
+}
+
+// tag analyzes the arguments and bodies of the built-in tags and of custom
+// tags implementing IInspectableNodeTag.
+func (a *analyzer) tag(tag *nodeTag) {
+	switch n := tag.node.(type) {
+	case *tagAutoescapeNode:
+		a.wrapper(n.wrapper)
+	case *tagBlockNode:
+		a.blocks[n.name] = true
+		a.scoped(func() { a.wrapper(a.tpl.blocks[n.name]) }, "block")
+	case *tagCycleNode:
+		a.exprs(n.args)
+		a.bind(n.asName)
+	case *tagExtendsNode:
+		a.dependency("extends", n.filename, a.tpl.parent, tag.token)
+	case *tagFilterNode:
+		for _, fc := range n.filterChain {
+			a.filters[fc.name] = true
+			a.expr(fc.paramExpr)
+		}
+		a.wrapper(n.bodyWrapper)
+	case *tagFirstofNode:
+		a.exprs(n.args)
+	case *tagForNode:
+		a.expr(n.objectEvaluator)
+		a.scoped(func() { a.wrapper(n.bodyWrapper) }, n.key, n.value, "forloop")
+		a.conditional(n.emptyWrapper)
+	case *tagIfNode:
+		a.exprs(n.conditions)
+		for _, w := range n.wrappers {
+			a.conditional(w)
+		}
+	case *tagIfchangedNode:
+		a.exprs(n.watchedExpr)
+		a.conditional(n.thenWrapper)
+		a.conditional(n.elseWrapper)
+	case *tagIfEqualNode:
+		a.exprs([]IEvaluator{n.var1, n.var2})
+		a.conditional(n.thenWrapper)
+		a.conditional(n.elseWrapper)
+	case *tagIfNotEqualNode:
+		a.exprs([]IEvaluator{n.var1, n.var2})
+		a.conditional(n.thenWrapper)
+		a.conditional(n.elseWrapper)
+	case *tagImportNode:
+		a.dependency("import", n.filename, nil, tag.token)
+		for name := range n.macros {
+			a.imported[name] = true
+			a.bind(name)
+		}
+	case *tagIncludeNode:
+		if n.lazy {
+			a.expr(n.filenameEvaluator)
+			a.dependency("include", "", nil, tag.token)
+		} else {
+			a.dependency("include", n.filename, n.tpl, tag.token)
+		}
+		for _, key := range slices.Sorted(maps.Keys(n.withPairs)) {
+			a.expr(n.withPairs[key])
+		}
+	case *tagMacroNode:
+		a.macros[n.name] = true
+		for _, name := range n.argsOrder {
+			a.expr(n.args[name])
+		}
+		a.scoped(func() { a.wrapper(n.wrapper) }, n.argsOrder...)
+	case *tagSetNode:
+		a.expr(n.expression)
+		a.bind(n.name)
+	case *tagSpacelessNode:
+		a.wrapper(n.wrapper)
+	case *tagSSINode:
+		a.dependency("ssi", n.filename, n.template, tag.token)
+	case *tagTranslateNode:
+		a.expr(n.msg)
+		a.exprs(n.args)
+		a.bind(n.as)
+	case *tagWidthratioNode:
+		a.exprs([]IEvaluator{n.current, n.max, n.width})
+		a.bind(n.ctxName)
+	case *tagWithNode:
+		for _, key := range slices.Sorted(maps.Keys(n.withPairs)) {
+			a.expr(n.withPairs[key])
+		}
+		a.scoped(func() { a.wrapper(n.wrapper) }, slices.Collect(maps.Keys(n.withPairs))...)
+	case IInspectableNodeTag:
+		args, bodies := n.Children()
+		a.exprs(args)
+		for _, w := range bodies {
+			a.conditional(w)
+		}
+	}
+}
+
+func (a *analyzer) exprs(exprs []IEvaluator) {
+	for _, e := range exprs {
+		a.expr(e)
+	}
+}
+
+func (a *analyzer) expr(e IEvaluator) {
+	switch e := e.(type) {
+	case *Expression:
+		a.exprs([]IEvaluator{e.expr1, e.expr2})
+	case *relationalExpression:
+		a.exprs([]IEvaluator{e.expr1, e.expr2})
+	case *notExpression:
+		a.expr(e.expr)
+	case *simpleExpression:
+		a.exprs([]IEvaluator{e.term1, e.term2})
+	case *term:
+		a.exprs([]IEvaluator{e.factor1, e.factor2})
+	case *power:
+		a.exprs([]IEvaluator{e.power1, e.power2})
+	case *nodeFilteredVariable:
+		a.expr(e.resolver)
+		for _, fc := range e.filterChain {
+			a.filters[fc.name] = true
+			a.expr(fc.parameter)
+			a.exprs(fc.parameters)
+			a.namedExprs(fc.namedParameters)
+		}
+	case *testCall:
+		a.tests[e.name] = true
+		a.expr(e.term)
+		a.exprs(e.parameters)
+		a.namedExprs(e.namedParameters)
+	case *variableResolver:
+		a.variable(e)
+	}
+}
+
+func (a *analyzer) namedExprs(exprs map[string]IEvaluator) {
+	for _, name := range slices.Sorted(maps.Keys(exprs)) {
+		a.expr(exprs[name])
+	}
+}
+
+// variable records the context variable read by vr (if it's not set by the
+// template) and analyzes the expressions used in its path.
+func (a *analyzer) variable(vr *variableResolver) {
+	if len(vr.parts) == 0 {
+		return
+	}
+	if typ := vr.parts[0].typ; typ == varTypeArray || typ == varTypeDict {
+		for _, part := range vr.parts {
+			a.expr(part.subscript)
+		}
+		return
+	}
+
+	path := []string{vr.parts[0].s}
+	complete := false // the path ended before the last part
+	for i, part := range vr.parts {
+		for _, arg := range part.callingArgs {
+			if e, ok := arg.(IEvaluator); ok {
+				a.expr(e)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(part.namedCallingArgs)) {
+			if e, ok := part.namedCallingArgs[name].(IEvaluator); ok {
+				a.expr(e)
+			}
+		}
+		if i == 0 {
+			complete = part.isFunctionCall
+			continue
+		}
+
+		switch part.typ {
+		case varTypeSubscript:
+			a.expr(part.subscript)
+			if key, ok := literalKey(part.subscript); ok && !complete {
+				path = append(path, key)
+			} else {
+				complete = true
+			}
+		case varTypeIdent, varTypeInt:
+			if !complete {
+				path = append(path, part.String())
+			}
+		default:
+			complete = true
+		}
+		if part.isFunctionCall {
+			complete = true
+		}
+	}
+
+	name := path[0]
+	if a.isBound(name) {
+		return
+	}
+	p := strings.Join(path, ".")
+	if _, seen := a.variables[p]; !seen {
+		use := VariableUse{Name: name, Path: p}
+		if vr.locationToken != nil {
+			use.Line, use.Column = vr.locationToken.Line, vr.locationToken.Col
+		}
+		a.variables[p] = use
+	}
+}
+
+// literalKey returns the key of a subscript with a literal (string or
+// integer) value.
+func literalKey(e IEvaluator) (string, bool) {
+	if fv, ok := e.(*nodeFilteredVariable); ok && len(fv.filterChain) == 0 {
+		e = fv.resolver
+	}
+	switch e := e.(type) {
+	case *stringResolver:
+		return e.val, true
+	case *intResolver:
+		return strconv.Itoa(e.val), true
+	}
+	return "", false
+}
@@ -0,0 +1,158 @@
+package pongo2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateAnalyze(t *testing.T) {
+	set := NewSet("analyze", NewMapLoader(map[string]string{
+		"base.html": `{% block title %}{{ site.name }}{% endblock %}{% block content %}{% endblock %}`,
+		"child.html": `{% extends "base.html" %}{% import "macros.html" field as f %}` +
+			`{% block title %}{{ block.Super }} - {{ page.title|upper }}{% endblock %}` +
+			`{% block sidebar %}{% include "sidebar.html" with active=page.slug %}{% include tpl_name %}{% endblock %}` +
+			`{% block content %}{% for item in items %}{{ forloop.Counter }}{{ item.name }}{{ f(item) }}{% empty %}{{ empty_text|default:"-" }}{% endfor %}{% endblock %}`,
+		"macros.html":  `{% macro field(x, label=default_label) export %}{{ x.value }} {{ label }}{% endmacro %}`,
+		"sidebar.html": `{{ active }}`,
+	}))
+
+	tests := []struct {
+		name string
+		tpl  string
+		want *TemplateAnalysis
+	}{
+		{
+			name: "variables",
+			tpl: `{{ user.name }}{{ user["email"] }}{{ user.address.city|lower }}{{ users[key] }}{{ list.0 }}` +
+				`{{ call(arg).x }}{{ pongo2.version }}{{ _("text") }}{{ [a, 1] }}{{ user.name }}`,
+			want: &TemplateAnalysis{
+				Variables: []VariableUse{
+					{Name: "a", Path: "a", Line: 1, Column: 148},
+					{Name: "arg", Path: "arg", Line: 1, Column: 100},
+					{Name: "call", Path: "call", Line: 1, Column: 95},
+					{Name: "key", Path: "key", Line: 1, Column: 73},
+					{Name: "list", Path: "list.0", Line: 1, Column: 83},
+					{Name: "user", Path: "user.address.city", Line: 1, Column: 38},
+					{Name: "user", Path: "user.email", Line: 1, Column: 19},
+					{Name: "user", Path: "user.name", Line: 1, Column: 4},
+					{Name: "users", Path: "users", Line: 1, Column: 67},
+				},
+				Filters: []string{"lower"},
+			},
+		},
+		{
+			name: "scopes",
+			tpl: `{% for k, v in data %}{{ k }}{{ v.x }}{{ forloop.Counter }}{% set tmp = v %}{{ tmp }}{% endfor %}{{ k }}{{ tmp }}` +
+				`{% with total=price*2 %}{{ total }}{% endwith %}{% set n = 1 %}{{ n }}` +
+				`{% if x is defined %}{{ x }}{% endif %}{% cycle "a" "b" as c silent %}{{ c }}`,
+			want: &TemplateAnalysis{
+				Variables: []VariableUse{
+					{Name: "data", Path: "data", Line: 1, Column: 16},
+					{Name: "k", Path: "k", Line: 1, Column: 101},
+					{Name: "price", Path: "price", Line: 1, Column: 128},
+					{Name: "tmp", Path: "tmp", Line: 1, Column: 108},
+					{Name: "x", Path: "x", Line: 1, Column: 190},
+				},
+				Tests: []string{"defined"},
+				Tags:  []string{"cycle", "for", "if", "set", "with"},
+			},
+		},
+		{
+			name: "conditional set",
+			tpl:  `{% if a %}{% set z = 1 %}{{ z }}{% else %}{% set y = 2 %}{% endif %}{{ z }}{{ y }}{% set w = 3 %}{% if a %}{{ w }}{% endif %}`,
+			want: &TemplateAnalysis{
+				Variables: []VariableUse{
+					{Name: "a", Path: "a", Line: 1, Column: 7},
+					{Name: "y", Path: "y", Line: 1, Column: 79},
+					{Name: "z", Path: "z", Line: 1, Column: 72},
+				},
+				Tags: []string{"if", "set"},
+			},
+		},
+		{
+			name: "filters and tags",
+			tpl:  `{% filter lower|truncatechars:n %}{{ text|default:fallback|safe }}{% endfilter %}{% autoescape off %}{% spaceless %}{% endspaceless %}{% endautoescape %}`,
+			want: &TemplateAnalysis{
+				Variables: []VariableUse{
+					{Name: "fallback", Path: "fallback", Line: 1, Column: 51},
+					{Name: "n", Path: "n", Line: 1, Column: 31},
+					{Name: "text", Path: "text", Line: 1, Column: 38},
+				},
+				Filters: []string{"default", "lower", "safe", "truncatechars"},
+				Tags:    []string{"autoescape", "filter", "spaceless"},
+			},
+		},
+		{
+			name: "macros",
+			tpl:  `{{ greet(name) }}{% macro greet(who, greeting=hello) %}{{ greeting }} {{ who }}{{ suffix }}{% endmacro %}`,
+			want: &TemplateAnalysis{
+				Variables: []VariableUse{
+					{Name: "hello", Path: "hello", Line: 1, Column: 47},
+					{Name: "name", Path: "name", Line: 1, Column: 10},
+					{Name: "suffix", Path: "suffix", Line: 1, Column: 83},
+				},
+				Tags:   []string{"macro"},
+				Macros: []string{"greet"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := set.FromString(tt.tpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tpl.Analyze(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("dependencies", func(t *testing.T) {
+		tpl, err := set.FromFile("child.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tpl.Analyze()
+
+		if want := []string{"empty_text", "items", "page", "tpl_name"}; !reflect.DeepEqual(got.VariableNames(), want) {
+			t.Errorf("VariableNames() = %v, want %v", got.VariableNames(), want)
+		}
+		if want := []string{"block", "extends", "for", "import", "include"}; !reflect.DeepEqual(got.Tags, want) {
+			t.Errorf("Tags = %v, want %v", got.Tags, want)
+		}
+		if want := []string{"content", "sidebar", "title"}; !reflect.DeepEqual(got.Blocks, want) {
+			t.Errorf("Blocks = %v, want %v", got.Blocks, want)
+		}
+		if want := []string{"content", "title"}; !reflect.DeepEqual(got.OverriddenBlocks, want) {
+			t.Errorf("OverriddenBlocks = %v, want %v", got.OverriddenBlocks, want)
+		}
+		if want := []string{"f"}; !reflect.DeepEqual(got.ImportedMacros, want) {
+			t.Errorf("ImportedMacros = %v, want %v", got.ImportedMacros, want)
+		}
+
+		type dep struct {
+			kind, name string
+			dynamic    bool
+			compiled   bool
+		}
+		var deps []dep
+		for _, d := range got.Dependencies {
+			deps = append(deps, dep{d.Kind, d.Name, d.Dynamic, d.Template != nil})
+		}
+		want := []dep{
+			{"extends", "base.html", false, true},
+			{"import", "macros.html", false, true},
+			{"include", "sidebar.html", false, true},
+			{"include", "", true, false},
+		}
+		if !reflect.DeepEqual(deps, want) {
+			t.Errorf("Dependencies = %+v, want %+v", deps, want)
+		}
+
+		macros := got.Dependencies[1].Template.Analyze()
+		if want := []string{"default_label"}; !reflect.DeepEqual(macros.VariableNames(), want) {
+			t.Errorf("macros.html VariableNames() = %v, want %v", macros.VariableNames(), want)
+		}
+	})
+}
@@ -10,12 +10,26 @@ import (
 type Args struct {
 	// TemplateSet is included so that filters (such as map) can invoke other filters
 	set *TemplateSet
+	// context of the template executing the filter or test (nil for Go callers)
+	ctx *ExecutionContext
 	// positional arguments
 	args []*Value
 	// named arguments
 	kwArgs map[string]*Value
 }
 
+// KeywordOptions marks an options struct for Go functions called from
+// templates: if the last parameter of a function is a struct (or pointer to
+// one) embedding KeywordOptions, keyword arguments set its fields by name.
+//
+//	type PaginateOptions struct {
+//		pongo2.KeywordOptions
+//		PerPage int `pongo2:"per_page"`
+//	}
+//
+//	func Paginate(items []Item, opts PaginateOptions) []Item
+type KeywordOptions struct{}
+
 // NewArgs creates a new Args object containing the specified arguments. named and args may both be nil
 // (the zero value of Args is valid).
 func NewArgs(set *TemplateSet, named map[string]*Value, args ...*Value) *Args {
@@ -184,12 +198,13 @@ func (p *Parser) parseArgs() (positional []IEvaluator, named map[string]IEvaluat
 	}
 }
 
-var emptyArgs = &Args{}
-
+// evaluateArgs evaluates the parameters of a filter or test call. The returned
+// Args always refer to the template set and ctx, even without any parameters,
+// as tests such as "test" need access to the set and filters such as dictsort
+// to the access policy.
 func evaluateArgs(ctx *ExecutionContext, parameters []IEvaluator, namedParameters map[string]IEvaluator) (*Args, error) {
-	var args *Args
+	args := &Args{set: ctx.template.set, ctx: ctx}
 	if len(parameters) > 0 {
-		args = &Args{set: ctx.template.set}
 		args.args = make([]*Value, 0, len(parameters))
 		for _, parameter := range parameters {
 			param, err := parameter.Evaluate(ctx)
@@ -200,9 +215,6 @@ func evaluateArgs(ctx *ExecutionContext, parameters []IEvaluator, namedParameter
 		}
 	}
 	if len(namedParameters) > 0 {
-		if args == nil {
-			args = &Args{set: ctx.template.set}
-		}
 		args.kwArgs = make(map[string]*Value, len(namedParameters))
 		for key, parameter := range namedParameters {
 			param, err := parameter.Evaluate(ctx)
@@ -213,10 +225,6 @@ func evaluateArgs(ctx *ExecutionContext, parameters []IEvaluator, namedParameter
 		}
 	}
 
-	if args == nil {
-		args = emptyArgs
-	}
-
 	return args, nil
 }
 
@@ -0,0 +1,723 @@
+package pongo2
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NodeKind is the kind of a Node.
+type NodeKind int
+
+const (
+	// NodeDocument is the root of a template; its children are the
+	// template's top-level nodes.
+	NodeDocument NodeKind = iota
+
+	// NodeText is the text between tags and variables (Text).
+	NodeText
+
+	// NodeVariable is a {{ expression }}; Args holds the expression.
+	NodeVariable
+
+	// NodeTag is a {% tag %}. Name is the tag's name, the children are the
+	// tag's bodies (NodeBody).
+	NodeTag
+
+	// NodeBody is a body of a tag, e.g. the nodes between {% if %} and
+	// {% else %}. Name is the tag which ends the body (like "else" or
+	// "endif").
+	NodeBody
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case NodeDocument:
+		return "document"
+	case NodeText:
+		return "text"
+	case NodeVariable:
+		return "variable"
+	case NodeTag:
+		return "tag"
+	case NodeBody:
+		return "body"
+	}
+	return "NodeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Node is a node of the syntax tree of a template (see Template.AST).
+//
+// The tree is a snapshot: changing it doesn't change the template.
+type Node struct {
+	Kind NodeKind
+
+	// Name is the tag's name (NodeTag) or the name of the tag ending the
+	// body (NodeBody).
+	Name string
+
+	// Text is the text of a NodeText (after the whitespace control has been
+	// applied).
+	Text string
+
+	// Position of the node (the tag's name for tags); 0 for documents and
+	// bodies. Token is the node's first token, if known.
+	Line   int
+	Column int
+	Token  *Token
+
+	// Args are the expression of a NodeVariable or the positional
+	// expressions of a tag, like the condition of {% if %} or the sequence of
+	// {% for %}. NamedArgs are the named expressions of a tag, like the
+	// pairs of {% with %} and {% include ... with %} or the argument
+	// defaults of {% macro %}.
+	Args      []*Expr
+	NamedArgs map[string]*Expr
+
+	// Filters is the filter chain of {% filter %}.
+	Filters []*FilterCall
+
+	// Idents are the names a tag defines: the loop variables of {% for %},
+	// the variable of {% set %} and {% cycle ... as %}, the keys of
+	// {% with %}, the name of a block, the name of a macro followed by its
+	// argument names and the names of imported macros.
+	Idents []string
+
+	// Filename is the (static) target of extends, include, import and ssi.
+	Filename string
+
+	// Children are the top-level nodes of a document and the nodes of a
+	// body; for tags, they're the tag's bodies in document order.
+	Children []*Node
+
+	// Tag is the node created by the tag's parser (NodeTag only). Custom
+	// tags can use it to access their own node types.
+	Tag INodeTag
+}
+
+// Expressions returns the top-level expressions of the node: its Args, its
+// NamedArgs (sorted by name) and the arguments of its Filters.
+func (n *Node) Expressions() []*Expr {
+	exprs := slices.Clone(n.Args)
+	for _, name := range slices.Sorted(maps.Keys(n.NamedArgs)) {
+		exprs = append(exprs, n.NamedArgs[name])
+	}
+	for _, fc := range n.Filters {
+		exprs = append(exprs, fc.expressions()...)
+	}
+	return exprs
+}
+
+// ExprKind is the kind of an Expr.
+type ExprKind int
+
+const (
+	// ExprLiteral is a string, integer, float or boolean literal (Value).
+	ExprLiteral ExprKind = iota
+
+	// ExprVariable is a variable with its attributes, subscripts and
+	// function calls (Parts).
+	ExprVariable
+
+	// ExprList is a list literal; its items are the Operands.
+	ExprList
+
+	// ExprDict is a dict literal; Keys are its keys, the Operands the
+	// values.
+	ExprDict
+
+	// ExprUnary is "not x" or "-x" (Operator and one operand).
+	ExprUnary
+
+	// ExprBinary is a logical, comparison or arithmetic operation (Operator
+	// and two operands).
+	ExprBinary
+
+	// ExprFilter is an expression with filters applied: the filtered
+	// expression is Operands[0], the chain is Filters.
+	ExprFilter
+
+	// ExprTest is "x is [not] name(args)": x is Operands[0], the test is
+	// Name (Negated for "is not"), its arguments are Args and NamedArgs.
+	ExprTest
+
+	// ExprOther is an expression of an unknown type (e.g. created by a
+	// custom tag).
+	ExprOther
+)
+
+func (k ExprKind) String() string {
+	switch k {
+	case ExprLiteral:
+		return "literal"
+	case ExprVariable:
+		return "variable"
+	case ExprList:
+		return "list"
+	case ExprDict:
+		return "dict"
+	case ExprUnary:
+		return "unary"
+	case ExprBinary:
+		return "binary"
+	case ExprFilter:
+		return "filter"
+	case ExprTest:
+		return "test"
+	case ExprOther:
+		return "other"
+	}
+	return "ExprKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Expr is an expression of the syntax tree of a template.
+type Expr struct {
+	Kind ExprKind
+
+	// Position of the expression (of its first token, if known)
+	Line   int
+	Column int
+	Token  *Token
+
+	// Value is the value of an ExprLiteral: a string, int, float64 or bool.
+	Value any
+
+	// Operator is the operator of an ExprUnary or ExprBinary as written,
+	// e.g. "not", "and", "&&", "==", "in" or "+".
+	Operator string
+
+	// Name and Negated describe the test of an ExprTest.
+	Name    string
+	Negated bool
+
+	// Parts are the parts of an ExprVariable; the first one is the
+	// variable's name.
+	Parts []*ExprPart
+
+	// Keys are the keys of an ExprDict (in the order of Operands).
+	Keys []string
+
+	// Operands are the operands of operations, the items of lists and
+	// dicts and the input of filters and tests.
+	Operands []*Expr
+
+	// Filters is the filter chain of an ExprFilter.
+	Filters []*FilterCall
+
+	// Args and NamedArgs are the arguments of an ExprTest.
+	Args      []*Expr
+	NamedArgs map[string]*Expr
+}
+
+// Path returns the attribute path of an ExprVariable, like
+// "user.address.city". Literal subscripts are part of it (user["name"] is
+// "user.name"); the path ends before a subscript with a non-literal value
+// and after a function call. It's empty for other expressions.
+func (e *Expr) Path() string {
+	if e.Kind != ExprVariable {
+		return ""
+	}
+	var path []string
+	for _, part := range e.Parts {
+		switch part.Kind {
+		case PartIdent:
+			path = append(path, part.Name)
+		case PartIndex:
+			path = append(path, strconv.Itoa(part.Index))
+		case PartSubscript:
+			key, ok := part.Subscript.literalKey()
+			if !ok {
+				return strings.Join(path, ".")
+			}
+			path = append(path, key)
+		default:
+			return strings.Join(path, ".")
+		}
+		if part.Call {
+			break
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// literalKey returns the value of a string or integer literal as a key.
+func (e *Expr) literalKey() (string, bool) {
+	if e.Kind != ExprLiteral {
+		return "", false
+	}
+	switch v := e.Value.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	}
+	return "", false
+}
+
+// ExprPartKind is the kind of an ExprPart.
+type ExprPartKind int
+
+const (
+	// PartIdent is a name (the variable's or an attribute's), as in user.name.
+	PartIdent ExprPartKind = iota
+
+	// PartIndex is a numeric attribute (Index), as in users.0.
+	PartIndex
+
+	// PartSubscript is a subscript (Subscript), as in users[key].
+	PartSubscript
+
+	// PartNil is a nil attribute, as in user.nil.
+	PartNil
+)
+
+// ExprPart is a part of an ExprVariable.
+type ExprPart struct {
+	Kind      ExprPartKind
+	Name      string
+	Index     int
+	Subscript *Expr
+
+	// Call is set if the part is called as a function; Args and NamedArgs
+	// are the arguments of the call.
+	Call      bool
+	Args      []*Expr
+	NamedArgs map[string]*Expr
+}
+
+// FilterCall is a filter applied to an expression (or the body of a
+// {% filter %} tag).
+type FilterCall struct {
+	Name string
+
+	// Position of the filter's name, if known
+	Line   int
+	Column int
+	Token  *Token
+
+	// Args and NamedArgs are the filter's arguments, like the argument of
+	// |default:"-".
+	Args      []*Expr
+	NamedArgs map[string]*Expr
+}
+
+func (fc *FilterCall) expressions() []*Expr {
+	exprs := slices.Clone(fc.Args)
+	for _, name := range slices.Sorted(maps.Keys(fc.NamedArgs)) {
+		exprs = append(exprs, fc.NamedArgs[name])
+	}
+	return exprs
+}
+
+// IInspectableNodeTag is an optional interface for the nodes created by
+// custom tags (see RegisterTag) to expose their arguments and bodies in the
+// syntax tree (Template.AST) and to the template analysis (Template.Analyze).
+// Tags which don't implement it have neither Args nor Children in the tree.
+type IInspectableNodeTag interface {
+	INodeTag
+
+	// Children returns the expressions parsed from the tag's arguments and
+	// the bodies of the tag (the wrappers returned by Parser.WrapUntilTag) in
+	// document order.
+	Children() (args []IEvaluator, bodies []*NodeWrapper)
+}
+
+// AST returns the syntax tree of the template, a NodeDocument. The tree
+// contains the template itself, not its parent or included templates (whose
+// trees are available through their own templates).
+func (tpl *Template) AST() *Node {
+	tpl.compileMu.Lock()
+	defer tpl.compileMu.Unlock()
+
+	doc := &Node{Kind: NodeDocument}
+	if tpl.root != nil {
+		doc.Children = astNodes(tpl, tpl.root.Nodes)
+	}
+	return doc
+}
+
+func astNodes(tpl *Template, nodes []INode) []*Node {
+	var result []*Node
+	for _, n := range nodes {
+		if node := astNode(tpl, n); node != nil {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func astNode(tpl *Template, n INode) *Node {
+	switch n := n.(type) {
+	case *nodeHTML:
+		node := &Node{Kind: NodeText, Text: n.text}
+		node.setToken(n.token)
+		return node
+	case *nodeVariable:
+		node := &Node{Kind: NodeVariable, Args: []*Expr{astExpr(n.expr)}}
+		node.setToken(n.locationToken)
+		return node
+	case *NodeWrapper:
+		return astBody(tpl, n)
+	case *nodeTag:
+		node := &Node{Kind: NodeTag, Name: n.name, Tag: n.node}
+		node.setToken(n.token)
+		node.tag(tpl, n.node)
+		return node
+	}
+	return nil
+}
+
+func astBody(tpl *Template, wrapper *NodeWrapper) *Node {
+	if wrapper == nil {
+		return nil
+	}
+	return &Node{Kind: NodeBody, Name: wrapper.Endtag, Children: astNodes(tpl, wrapper.nodes)}
+}
+
+func (n *Node) setToken(token *Token) {
+	if token != nil {
+		n.Token, n.Line, n.Column = token, token.Line, token.Col
+	}
+}
+
+func (n *Node) addArgs(exprs ...IEvaluator) {
+	for _, e := range exprs {
+		if e != nil {
+			n.Args = append(n.Args, astExpr(e))
+		}
+	}
+}
+
+func (n *Node) addNamedArgs(exprs map[string]IEvaluator) {
+	for name, e := range exprs {
+		if e == nil {
+			continue
+		}
+		if n.NamedArgs == nil {
+			n.NamedArgs = make(map[string]*Expr, len(exprs))
+		}
+		n.NamedArgs[name] = astExpr(e)
+	}
+}
+
+func (n *Node) addBodies(tpl *Template, wrappers ...*NodeWrapper) {
+	for _, w := range wrappers {
+		if body := astBody(tpl, w); body != nil {
+			n.Children = append(n.Children, body)
+		}
+	}
+}
+
+func (n *Node) addIdents(idents ...string) {
+	for _, ident := range idents {
+		if ident != "" {
+			n.Idents = append(n.Idents, ident)
+		}
+	}
+}
+
+// tag fills in the arguments and bodies of a tag node.
+func (n *Node) tag(tpl *Template, tag INodeTag) {
+	switch t := tag.(type) {
+	case *tagAutoescapeNode:
+		n.addBodies(tpl, t.wrapper)
+	case *tagBlockNode:
+		n.addIdents(t.name)
+		n.addBodies(tpl, tpl.blocks[t.name])
+	case *tagCycleNode:
+		n.addArgs(t.args...)
+		n.addIdents(t.asName)
+	case *tagExtendsNode:
+		n.Filename = t.filename
+	case *tagFilterNode:
+		for _, fc := range t.filterChain {
+			call := &FilterCall{Name: fc.name}
+			if fc.token != nil {
+				call.Token, call.Line, call.Column = fc.token, fc.token.Line, fc.token.Col
+			}
+			if fc.paramExpr != nil {
+				call.Args = []*Expr{astExpr(fc.paramExpr)}
+			}
+			n.Filters = append(n.Filters, call)
+		}
+		n.addBodies(tpl, t.bodyWrapper)
+	case *tagFirstofNode:
+		n.addArgs(t.args...)
+	case *tagForNode:
+		n.addIdents(t.key, t.value)
+		n.addArgs(t.objectEvaluator)
+		n.addBodies(tpl, t.bodyWrapper, t.emptyWrapper)
+	case *tagIfNode:
+		n.addArgs(t.conditions...)
+		n.addBodies(tpl, t.wrappers...)
+	case *tagIfchangedNode:
+		n.addArgs(t.watchedExpr...)
+		n.addBodies(tpl, t.thenWrapper, t.elseWrapper)
+	case *tagIfEqualNode:
+		n.addArgs(t.var1, t.var2)
+		n.addBodies(tpl, t.thenWrapper, t.elseWrapper)
+	case *tagIfNotEqualNode:
+		n.addArgs(t.var1, t.var2)
+		n.addBodies(tpl, t.thenWrapper, t.elseWrapper)
+	case *tagImportNode:
+		n.Filename = t.filename
+		n.addIdents(slices.Sorted(maps.Keys(t.macros))...)
+	case *tagIncludeNode:
+		if t.lazy {
+			n.addArgs(t.filenameEvaluator)
+		} else {
+			n.Filename = t.filename
+		}
+		n.addNamedArgs(t.withPairs)
+	case *tagMacroNode:
+		n.addIdents(t.name)
+		n.addIdents(t.argsOrder...)
+		n.addNamedArgs(t.args)
+		n.addBodies(tpl, t.wrapper)
+	case *tagSetNode:
+		n.addIdents(t.name)
+		n.addArgs(t.expression)
+	case *tagSpacelessNode:
+		n.addBodies(tpl, t.wrapper)
+	case *tagSSINode:
+		n.Filename = t.filename
+	case *tagTranslateNode:
+		n.addArgs(t.msg)
+		n.addArgs(t.args...)
+		n.addIdents(t.as)
+	case *tagWidthratioNode:
+		n.addArgs(t.current, t.max, t.width)
+		n.addIdents(t.ctxName)
+	case *tagWithNode:
+		n.addIdents(slices.Sorted(maps.Keys(t.withPairs))...)
+		n.addNamedArgs(t.withPairs)
+		n.addBodies(tpl, t.wrapper)
+	case IInspectableNodeTag:
+		args, bodies := t.Children()
+		n.addArgs(args...)
+		n.addBodies(tpl, bodies...)
+	}
+}
+
+func astExprs(exprs []IEvaluator) []*Expr {
+	var result []*Expr
+	for _, e := range exprs {
+		if e != nil {
+			result = append(result, astExpr(e))
+		}
+	}
+	return result
+}
+
+func astNamedExprs(exprs map[string]IEvaluator) map[string]*Expr {
+	if len(exprs) == 0 {
+		return nil
+	}
+	result := make(map[string]*Expr, len(exprs))
+	for name, e := range exprs {
+		result[name] = astExpr(e)
+	}
+	return result
+}
+
+func astExpr(e IEvaluator) *Expr {
+	expr := &Expr{Kind: ExprOther}
+	switch e := e.(type) {
+	case *Expression:
+		expr.binary(e.opToken.Val, astExpr(e.expr1), astExpr(e.expr2))
+	case *relationalExpression:
+		expr.binary(e.opToken.Val, astExpr(e.expr1), astExpr(e.expr2))
+	case *term:
+		expr.binary(e.opToken.Val, astExpr(e.factor1), astExpr(e.factor2))
+	case *power:
+		expr.binary("^", astExpr(e.power1), astExpr(e.power2))
+	case *notExpression:
+		expr.Kind, expr.Operator, expr.Operands = ExprUnary, "not", []*Expr{astExpr(e.expr)}
+	case *simpleExpression:
+		operand := astExpr(e.term1)
+		if e.negativeSign {
+			operand = &Expr{Kind: ExprUnary, Operator: "-", Line: operand.Line, Column: operand.Column, Token: operand.Token, Operands: []*Expr{operand}}
+		}
+		if e.term2 == nil {
+			return operand
+		}
+		expr.binary(e.opToken.Val, operand, astExpr(e.term2))
+	case *stringResolver:
+		expr.Kind, expr.Value = ExprLiteral, e.val
+	case *intResolver:
+		expr.Kind, expr.Value = ExprLiteral, e.val
+	case *floatResolver:
+		expr.Kind, expr.Value = ExprLiteral, e.val
+	case *boolResolver:
+		expr.Kind, expr.Value = ExprLiteral, e.val
+	case *nodeFilteredVariable:
+		if len(e.filterChain) == 0 {
+			return astExpr(e.resolver)
+		}
+		expr.Kind, expr.Operands = ExprFilter, []*Expr{astExpr(e.resolver)}
+		for _, fc := range e.filterChain {
+			call := &FilterCall{Name: fc.name, NamedArgs: astNamedExprs(fc.namedParameters)}
+			if fc.token != nil {
+				call.Token, call.Line, call.Column = fc.token, fc.token.Line, fc.token.Col
+			}
+			if fc.parameter != nil {
+				call.Args = []*Expr{astExpr(fc.parameter)}
+			} else {
+				call.Args = astExprs(fc.parameters)
+			}
+			expr.Filters = append(expr.Filters, call)
+		}
+	case *testCall:
+		expr.Kind, expr.Name, expr.Negated = ExprTest, e.name, e.negate
+		expr.Operands = []*Expr{astExpr(e.term)}
+		expr.Args, expr.NamedArgs = astExprs(e.parameters), astNamedExprs(e.namedParameters)
+	case *variableResolver:
+		expr.variable(e)
+	}
+	if token := e.GetPositionToken(); token != nil {
+		expr.Token, expr.Line, expr.Column = token, token.Line, token.Col
+	}
+	return expr
+}
+
+func (expr *Expr) binary(op string, left, right *Expr) {
+	expr.Kind, expr.Operator, expr.Operands = ExprBinary, op, []*Expr{left, right}
+}
+
+func (expr *Expr) variable(vr *variableResolver) {
+	if len(vr.parts) == 0 || vr.parts[0].typ == varTypeArray || vr.parts[0].typ == varTypeDict {
+		// List or dict literal (an empty one has no parts)
+		expr.Kind = ExprList
+		if vr.locationToken != nil && vr.locationToken.Val == "{" {
+			expr.Kind = ExprDict
+		}
+		for _, part := range vr.parts {
+			if part.typ == varTypeDict {
+				expr.Keys = append(expr.Keys, part.s)
+			}
+			expr.Operands = append(expr.Operands, astExpr(part.subscript))
+		}
+		return
+	}
+
+	expr.Kind = ExprVariable
+	for _, part := range vr.parts {
+		p := &ExprPart{Call: part.isFunctionCall}
+		switch part.typ {
+		case varTypeIdent:
+			p.Kind, p.Name = PartIdent, part.s
+		case varTypeInt:
+			p.Kind, p.Index = PartIndex, part.i
+		case varTypeSubscript:
+			p.Kind, p.Subscript = PartSubscript, astExpr(part.subscript)
+		default:
+			p.Kind = PartNil
+		}
+		for _, arg := range part.callingArgs {
+			if e, ok := arg.(IEvaluator); ok {
+				p.Args = append(p.Args, astExpr(e))
+			}
+		}
+		for name, arg := range part.namedCallingArgs {
+			if e, ok := arg.(IEvaluator); ok {
+				if p.NamedArgs == nil {
+					p.NamedArgs = make(map[string]*Expr)
+				}
+				p.NamedArgs[name] = astExpr(e)
+			}
+		}
+		expr.Parts = append(expr.Parts, p)
+	}
+}
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node *Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order: it starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor w for
+// each of the children of node, followed by a call of w.Visit(nil).
+func Walk(v Visitor, node *Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range node.Children {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(node *Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the children of node, followed by a call of
+// f(nil).
+//
+// The expressions of a node can be inspected with InspectExpr:
+//
+//	pongo2.Inspect(tpl.AST(), func(n *pongo2.Node) bool {
+//		if n != nil {
+//			for _, e := range n.Expressions() {
+//				pongo2.InspectExpr(e, func(e *pongo2.Expr) bool {
+//					if e != nil && e.Kind == pongo2.ExprVariable {
+//						fmt.Println(e.Path())
+//					}
+//					return true
+//				})
+//			}
+//		}
+//		return true
+//	})
+func Inspect(node *Node, f func(*Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// InspectExpr traverses an expression in depth-first order: it starts by
+// calling f(expr); expr must not be nil. If f returns true, InspectExpr
+// invokes f recursively for each subexpression (operands, subscripts,
+// function call, filter and test arguments), followed by a call of f(nil).
+func InspectExpr(expr *Expr, f func(*Expr) bool) {
+	if !f(expr) {
+		return
+	}
+	for _, e := range expr.children() {
+		InspectExpr(e, f)
+	}
+	f(nil)
+}
+
+// children returns the subexpressions of the expression in document order.
+func (expr *Expr) children() []*Expr {
+	var children []*Expr
+	for _, part := range expr.Parts {
+		if part.Subscript != nil {
+			children = append(children, part.Subscript)
+		}
+		children = append(children, part.Args...)
+		for _, name := range slices.Sorted(maps.Keys(part.NamedArgs)) {
+			children = append(children, part.NamedArgs[name])
+		}
+	}
+	children = append(children, expr.Operands...)
+	for _, fc := range expr.Filters {
+		children = append(children, fc.expressions()...)
+	}
+	children = append(children, expr.Args...)
+	for _, name := range slices.Sorted(maps.Keys(expr.NamedArgs)) {
+		children = append(children, expr.NamedArgs[name])
+	}
+	return children
+}
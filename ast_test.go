@@ -0,0 +1,258 @@
+package pongo2
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tagRepeatNode is a custom tag exposing its children via IInspectableNodeTag.
+type tagRepeatNode struct {
+	count   IEvaluator
+	wrapper *NodeWrapper
+}
+
+func (node *tagRepeatNode) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
+	count, err := node.count.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	for range count.Integer() {
+		if err := node.wrapper.Execute(ctx, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (node *tagRepeatNode) Children() ([]IEvaluator, []*NodeWrapper) {
+	return []IEvaluator{node.count}, []*NodeWrapper{node.wrapper}
+}
+
+func tagRepeatParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error) {
+	count, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	wrapper, _, err := doc.WrapUntilTag("endrepeat")
+	if err != nil {
+		return nil, err
+	}
+	return &tagRepeatNode{count: count, wrapper: wrapper}, nil
+}
+
+// dumpAST returns a compact description of a syntax tree.
+func dumpAST(node *Node) string {
+	var b strings.Builder
+	depth := 0
+	Inspect(node, func(n *Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		fmt.Fprintf(&b, "%s%s", strings.Repeat("  ", depth), n.Kind)
+		if n.Name != "" {
+			fmt.Fprintf(&b, " %s", n.Name)
+		}
+		if n.Kind == NodeText {
+			fmt.Fprintf(&b, " %q", n.Text)
+		}
+		if n.Line > 0 {
+			fmt.Fprintf(&b, " @%d:%d", n.Line, n.Column)
+		}
+		for _, e := range n.Expressions() {
+			fmt.Fprintf(&b, " [%s]", dumpExpr(e))
+		}
+		if len(n.Idents) > 0 {
+			fmt.Fprintf(&b, " idents=%v", n.Idents)
+		}
+		if n.Filename != "" {
+			fmt.Fprintf(&b, " file=%s", n.Filename)
+		}
+		b.WriteString("\n")
+		depth++
+		return true
+	})
+	return b.String()
+}
+
+func dumpExpr(e *Expr) string {
+	switch e.Kind {
+	case ExprLiteral:
+		return fmt.Sprintf("%#v", e.Value)
+	case ExprVariable:
+		return e.Path()
+	case ExprUnary:
+		return e.Operator + " " + dumpExpr(e.Operands[0])
+	case ExprBinary:
+		return "(" + dumpExpr(e.Operands[0]) + " " + e.Operator + " " + dumpExpr(e.Operands[1]) + ")"
+	case ExprFilter:
+		s := dumpExpr(e.Operands[0])
+		for _, f := range e.Filters {
+			s += "|" + f.Name
+			for _, arg := range f.Args {
+				s += ":" + dumpExpr(arg)
+			}
+		}
+		return s
+	case ExprTest:
+		return dumpExpr(e.Operands[0]) + " is " + e.Name
+	}
+	var items []string
+	for i, op := range e.Operands {
+		if e.Kind == ExprDict {
+			items = append(items, e.Keys[i]+": "+dumpExpr(op))
+		} else {
+			items = append(items, dumpExpr(op))
+		}
+	}
+	return e.Kind.String() + "{" + strings.Join(items, ", ") + "}"
+}
+
+func TestTemplateAST(t *testing.T) {
+	set := NewSet("ast", NewMapLoader(map[string]string{
+		"part.html": "part",
+	}))
+	if err := set.RegisterTag("repeat", tagRepeatParser); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tpl  string
+		want string
+	}{
+		{
+			name: "text and variables",
+			tpl:  `Hi {{ user.name|default:"you"|upper }}{{ -a + 2 * b }}{{ items.0["k"] }}`,
+			want: `document
+  text "Hi " @1:1
+  variable @1:4 [user.name|default:"you"|upper]
+  variable @1:39 [(- a + (2 * b))]
+  variable @1:55 [items.0.k]
+`,
+		},
+		{
+			name: "tags",
+			tpl: `{% if x > 1 and not y %}a{% elif z is defined %}b{% else %}c{% endif %}` +
+				`{% for k, v in m %}{{ k }}{% empty %}-{% endfor %}{% set s = [1, "a"] %}{% with d=s.0 %}{% endwith %}`,
+			want: `document
+  tag if @1:4 [((x > 1) and not y)] [z is defined]
+    body elif
+      text "a" @1:25
+    body else
+      text "b" @1:49
+    body endif
+      text "c" @1:60
+  tag for @1:75 [m] idents=[k v]
+    body empty
+      variable @1:91 [k]
+    body endfor
+      text "-" @1:109
+  tag set @1:125 [list{1, "a"}] idents=[s]
+  tag with @1:147 [s.0] idents=[d]
+    body endwith
+`,
+		},
+		{
+			name: "dependencies and filter tag",
+			tpl:  `{% include "part.html" with x=y %}{% include name %}{% filter lower|cut:" " %}A B{% endfilter %}`,
+			want: `document
+  tag include @1:4 [y] file=part.html
+  tag include @1:38 [name]
+  tag filter @1:56 [" "]
+    body endfilter
+      text "A B" @1:79
+`,
+		},
+		{
+			name: "custom tag",
+			tpl:  `{% repeat n %}{{ item }}{% endrepeat %}`,
+			want: `document
+  tag repeat @1:4 [n]
+    body endrepeat
+      variable @1:15 [item]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := set.FromString(tt.tpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dumpAST(tpl.AST()); got != tt.want {
+				t.Errorf("AST() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("custom tag analysis", func(t *testing.T) {
+		tpl, err := set.FromString(`{% repeat n %}{{ item|upper }}{% endrepeat %}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := tpl.Analyze()
+		if want := []string{"item", "n"}; !reflect.DeepEqual(a.VariableNames(), want) {
+			t.Errorf("VariableNames() = %v, want %v", a.VariableNames(), want)
+		}
+		if want := []string{"upper"}; !reflect.DeepEqual(a.Filters, want) {
+			t.Errorf("Filters = %v, want %v", a.Filters, want)
+		}
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		tpl, err := set.FromString(`{% if a %}{{ b(c, d=e) }}{% endif %}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var visits, nils int
+		Inspect(tpl.AST(), func(n *Node) bool {
+			if n == nil {
+				nils++
+			} else {
+				visits++
+			}
+			return true
+		})
+		if visits != 4 || nils != visits {
+			t.Errorf("Inspect() visited %d nodes with %d nil calls, want 4 and 4", visits, nils)
+		}
+
+		var names []string
+		Inspect(tpl.AST(), func(n *Node) bool {
+			if n == nil {
+				return false
+			}
+			for _, e := range n.Expressions() {
+				InspectExpr(e, func(e *Expr) bool {
+					if e != nil && e.Kind == ExprVariable {
+						names = append(names, e.Path())
+					}
+					return true
+				})
+			}
+			return n.Kind != NodeTag // don't descend into tags
+		})
+		if want := []string{"a"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("variables outside of tags = %v, want %v", names, want)
+		}
+
+		names = nil
+		Inspect(tpl.AST(), func(n *Node) bool {
+			if n != nil && n.Kind == NodeVariable {
+				InspectExpr(n.Args[0], func(e *Expr) bool {
+					if e != nil && e.Kind == ExprVariable {
+						names = append(names, e.Path())
+					}
+					return true
+				})
+			}
+			return true
+		})
+		if want := []string{"b", "c", "e"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("variables = %v, want %v", names, want)
+		}
+	})
+}
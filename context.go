@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"slices"
+	"sync/atomic"
 )
 
 // A Context type provides constants, variables, instances or functions to a template.
@@ -71,12 +73,22 @@ type ExecutionContext struct {
 	template *Template
 
 	// Tracks recursive macro call depth; errors if exceeding maxMacroDepth.
-	macroDepth int
+	// Atomic, as includes rendered in parallel may call the macros defined
+	// in this context concurrently.
+	macroDepth atomic.Int32
+
+	// Memoized results of Lazy values, shared by all contexts of a render.
+	lazies *lazyCache
 
 	// When true, {{ variable }} output is HTML-escaped. Toggle with {% autoescape %}.
 	// The |safe filter bypasses escaping.
 	Autoescape bool
 
+	// The filter used to escape {{ variable }} output (Options.AutoescapeFilter
+	// or the one of the template's autoescape mode). {% autoescape "mode" %}
+	// changes it, an empty name disables escaping.
+	AutoescapeFilter string
+
 	// If this is set to true, variables that resolve to *Template or string values
 	// containing template tags are further resolved.
 	DeepResolve bool
@@ -90,6 +102,15 @@ type ExecutionContext struct {
 	// If this is set to true, struct fields, map keys, and variable names will be treated as case-insensitive.
 	IgnoreVariableCase bool
 
+	// If this is set to true, struct fields can also be accessed by their `pongo2` or `json` struct tag name.
+	StructTags bool
+
+	// If this is set to true, undefined variables are an execution error.
+	StrictUndefined bool
+
+	// If this is set to true, undefined variables are rendered as a placeholder.
+	DebugUndefined bool
+
 	// Assigns a translation function to be used for the translate tag.
 	Translator TranslateFunc
 
@@ -121,14 +142,19 @@ func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
 
 	return &ExecutionContext{
 		template: tpl,
+		lazies:   &lazyCache{},
 
 		Public:                  ctx,
 		Private:                 privateCtx,
 		Autoescape:              tpl.set.autoescape,
+		AutoescapeFilter:        tpl.Options.AutoescapeFilter,
 		DeepResolve:             tpl.Options.DeepResolve,
 		DisableContextFunctions: tpl.Options.DisableContextFunctions,
 		DisableNestedFunctions:  tpl.Options.DisableNestedFunctions,
 		IgnoreVariableCase:      tpl.Options.IgnoreVariableCase,
+		StructTags:              tpl.Options.StructTags,
+		StrictUndefined:         tpl.Options.StrictUndefined,
+		DebugUndefined:          tpl.Options.DebugUndefined,
 		Translator:              tpl.Options.Translator,
 	}
 }
@@ -141,14 +167,19 @@ func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
 func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	newctx := &ExecutionContext{
 		template: parent.template,
+		lazies:   parent.lazies,
 
 		Public:                  parent.Public,
 		Private:                 make(Context),
 		Autoescape:              parent.Autoescape,
+		AutoescapeFilter:        parent.AutoescapeFilter,
 		DeepResolve:             parent.DeepResolve,
 		DisableContextFunctions: parent.DisableContextFunctions,
 		DisableNestedFunctions:  parent.DisableNestedFunctions,
 		IgnoreVariableCase:      parent.IgnoreVariableCase,
+		StructTags:              parent.StructTags,
+		StrictUndefined:         parent.StrictUndefined,
+		DebugUndefined:          parent.DebugUndefined,
 		Translator:              parent.Translator,
 	}
 	newctx.Shared = parent.Shared
@@ -159,10 +190,22 @@ func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	return newctx
 }
 
+// accessPolicy returns the AccessPolicy of the template set being executed,
+// or nil for a nil context.
+func (ctx *ExecutionContext) accessPolicy() AccessPolicy {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.template.set.AccessPolicy
+}
+
 func (ctx *ExecutionContext) Error(msg string, token *Token) error {
 	return ctx.OrigError(errors.New(msg), token)
 }
 
+// OrigError wraps err into an *Error located at token. The error's Code is
+// taken over from err (if it has one, see ErrorCode), otherwise it's
+// ErrCodeExecution; so is the Stack if err is an *Error.
 func (ctx *ExecutionContext) OrigError(err error, token *Token) error {
 	filename := ctx.template.name
 	var line, col int
@@ -173,6 +216,15 @@ func (ctx *ExecutionContext) OrigError(err error, token *Token) error {
 		line = token.Line
 		col = token.Col
 	}
+	code := errorCodeOf(err)
+	if code == "" {
+		code = ErrCodeExecution
+	}
+	var stack []ErrorFrame
+	var inner *Error
+	if errors.As(err, &inner) {
+		stack = slices.Clone(inner.Stack)
+	}
 	return &Error{
 		Template:  ctx.template,
 		Filename:  filename,
@@ -181,6 +233,8 @@ func (ctx *ExecutionContext) OrigError(err error, token *Token) error {
 		Token:     token,
 		Sender:    "execution",
 		OrigError: err,
+		Code:      code,
+		Stack:     stack,
 	}
 }
 
@@ -0,0 +1,235 @@
+package pongo2
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+var (
+	typeOfDuration = reflect.TypeFor[time.Duration]()
+	typeOfTime     = reflect.TypeFor[time.Time]()
+)
+
+// errIncompatibleType is returned by coerceValue if a value can't be
+// converted to the requested type at all.
+var errIncompatibleType = errors.New("incompatible type")
+
+// coerceValue converts v to typ, so that it can be passed as an argument to a
+// Go function called from a template. Besides assignable values, it performs
+// the following conversions:
+//
+//   - integers, unsigned integers and floats into each other, failing on
+//     overflows, fractional parts and inexact int-to-float conversions
+//     (float64 to float32 rounds, but fails on overflows)
+//   - between string, bool and numeric types and their named versions
+//   - strings into []byte, time.Duration (time.ParseDuration) and
+//     time.Time (RFC 3339)
+//   - slices and arrays (including template lists) into typed slices and
+//     arrays, and maps (including template dicts) into typed maps, by
+//     converting each element
+//
+// nil converts into the zero value of pointers, interfaces, slices, maps,
+// funcs and channels.
+func coerceValue(v reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	if typ == typeOfValuePtr {
+		if v.IsValid() {
+			if val, ok := reflect.TypeAssert[*Value](v); ok {
+				return reflect.ValueOf(val), nil
+			}
+		}
+		return reflect.ValueOf(&Value{val: v}), nil
+	}
+
+	// Unpack *Value (e. g. items of template lists and dicts) and interfaces
+	if v.IsValid() {
+		if val, ok := reflect.TypeAssert[*Value](v); ok && val != nil {
+			v = val.val
+		}
+	}
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, errIncompatibleType
+	}
+	if v.Type().AssignableTo(typ) {
+		return v, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ == typeOfDuration && v.Kind() == reflect.String {
+			d, err := time.ParseDuration(v.String())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(d), nil
+		}
+		i, err := coerceInt(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if reflect.Zero(typ).OverflowInt(i) {
+			return reflect.Value{}, fmt.Errorf("value %d overflows %s", i, typ)
+		}
+		return reflect.ValueOf(i).Convert(typ), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := coerceUint(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if reflect.Zero(typ).OverflowUint(u) {
+			return reflect.Value{}, fmt.Errorf("value %d overflows %s", u, typ)
+		}
+		return reflect.ValueOf(u).Convert(typ), nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := coerceFloat(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if reflect.Zero(typ).OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("value %g overflows %s", f, typ)
+		}
+		return reflect.ValueOf(f).Convert(typ), nil
+
+	case reflect.Bool, reflect.String:
+		if v.Kind() == typ.Kind() {
+			return v.Convert(typ), nil
+		}
+
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.String {
+			return v.Convert(typ), nil
+		}
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			out := reflect.MakeSlice(typ, v.Len(), v.Len())
+			return out, coerceElements(v, out)
+		}
+
+	case reflect.Array:
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() == typ.Len() {
+			out := reflect.New(typ).Elem()
+			return out, coerceElements(v, out)
+		}
+
+	case reflect.Map:
+		if v.Kind() == reflect.Map {
+			out := reflect.MakeMapWithSize(typ, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				key, err := coerceValue(iter.Key(), typ.Key())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %v: %w", iter.Key(), err)
+				}
+				val, err := coerceValue(iter.Value(), typ.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value of key %v: %w", iter.Key(), err)
+				}
+				out.SetMapIndex(key, val)
+			}
+			return out, nil
+		}
+
+	case reflect.Struct:
+		if typ == typeOfTime && v.Kind() == reflect.String {
+			t, err := time.Parse(time.RFC3339, v.String())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(t), nil
+		}
+	}
+
+	return reflect.Value{}, errIncompatibleType
+}
+
+// coerceElements converts all elements of the slice or array v into out,
+// which must have the same length.
+func coerceElements(v, out reflect.Value) error {
+	for i := range v.Len() {
+		elem, err := coerceValue(v.Index(i), out.Type().Elem())
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		out.Index(i).Set(elem)
+	}
+	return nil
+}
+
+func coerceInt(v reflect.Value) (int64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", v.Uint())
+		}
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("value %g has a fractional part", f)
+		}
+		// -2^63 is exactly representable as float64, 2^63 is out of range already
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %g overflows int64", f)
+		}
+		return int64(f), nil
+	}
+	return 0, errIncompatibleType
+}
+
+func coerceUint(v reflect.Value) (uint64, error) {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return 0, fmt.Errorf("negative value %d can't be converted to an unsigned integer", v.Int())
+		}
+		return uint64(v.Int()), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("value %g has a fractional part", f)
+		}
+		if f < 0 {
+			return 0, fmt.Errorf("negative value %g can't be converted to an unsigned integer", f)
+		}
+		if f >= math.MaxUint64 {
+			return 0, fmt.Errorf("value %g overflows uint64", f)
+		}
+		return uint64(f), nil
+	}
+	return 0, errIncompatibleType
+}
+
+func coerceFloat(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f := float64(v.Int())
+		if f >= math.MaxInt64 || int64(f) != v.Int() {
+			return 0, fmt.Errorf("value %d can't be represented exactly as a float", v.Int())
+		}
+		return f, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f := float64(v.Uint())
+		if f >= math.MaxUint64 || uint64(f) != v.Uint() {
+			return 0, fmt.Errorf("value %d can't be represented exactly as a float", v.Uint())
+		}
+		return f, nil
+	}
+	return 0, errIncompatibleType
+}
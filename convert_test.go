@@ -0,0 +1,139 @@
+package pongo2
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type namedString string
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       any
+		typ      reflect.Type
+		expected any
+		errMsg   string
+	}{
+		{name: "int to int64", in: 5, typ: reflect.TypeFor[int64](), expected: int64(5)},
+		{name: "int to int8 overflow", in: 300, typ: reflect.TypeFor[int8](), errMsg: "overflows int8"},
+		{name: "int to uint", in: 5, typ: reflect.TypeFor[uint](), expected: uint(5)},
+		{name: "negative int to uint", in: -1, typ: reflect.TypeFor[uint32](), errMsg: "negative value"},
+		{name: "uint64 to int64 overflow", in: uint64(math.MaxUint64), typ: reflect.TypeFor[int64](), errMsg: "overflows int64"},
+		{name: "float to float32", in: 1.5, typ: reflect.TypeFor[float32](), expected: float32(1.5)},
+		{name: "float to float32 overflow", in: 1e300, typ: reflect.TypeFor[float32](), errMsg: "overflows float32"},
+		{name: "integral float to int", in: 3.0, typ: reflect.TypeFor[int](), expected: 3},
+		{name: "fractional float to int", in: 3.5, typ: reflect.TypeFor[int](), errMsg: "fractional part"},
+		{name: "huge float to int64", in: 1e19, typ: reflect.TypeFor[int64](), errMsg: "overflows int64"},
+		{name: "int to float64", in: 7, typ: reflect.TypeFor[float64](), expected: 7.0},
+		{name: "inexact int to float64", in: int64(1<<53 + 1), typ: reflect.TypeFor[float64](), errMsg: "represented exactly"},
+		{name: "string to named string", in: "abc", typ: reflect.TypeFor[namedString](), expected: namedString("abc")},
+		{name: "string to bytes", in: "abc", typ: reflect.TypeFor[[]byte](), expected: []byte("abc")},
+		{name: "string to int", in: "5", typ: reflect.TypeFor[int](), errMsg: "incompatible type"},
+		{name: "int to string", in: 5, typ: reflect.TypeFor[string](), errMsg: "incompatible type"},
+		{name: "string to duration", in: "1h30m", typ: reflect.TypeFor[time.Duration](), expected: 90 * time.Minute},
+		{name: "invalid duration", in: "soon", typ: reflect.TypeFor[time.Duration](), errMsg: "invalid duration"},
+		{name: "int to duration", in: 5, typ: reflect.TypeFor[time.Duration](), expected: time.Duration(5)},
+		{
+			name:     "string to time",
+			in:       "2024-01-02T03:04:05Z",
+			typ:      reflect.TypeFor[time.Time](),
+			expected: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{name: "list to typed slice", in: []*Value{AsValue(1), AsValue(2.0)}, typ: reflect.TypeFor[[]int64](), expected: []int64{1, 2}},
+		{name: "list to any slice", in: []*Value{AsValue(1), AsValue("a")}, typ: reflect.TypeFor[[]any](), expected: []any{1, "a"}},
+		{name: "list to array", in: []*Value{AsValue(1), AsValue(2)}, typ: reflect.TypeFor[[2]uint8](), expected: [2]uint8{1, 2}},
+		{name: "list element error", in: []*Value{AsValue(1), AsValue("a")}, typ: reflect.TypeFor[[]int](), errMsg: "element 1: incompatible type"},
+		{
+			name:     "dict to typed map",
+			in:       map[string]*Value{"a": AsValue(1), "b": AsValue(2)},
+			typ:      reflect.TypeFor[map[namedString]float64](),
+			expected: map[namedString]float64{"a": 1, "b": 2},
+		},
+		{name: "dict value error", in: map[string]*Value{"a": AsValue(-1)}, typ: reflect.TypeFor[map[string]uint](), errMsg: "value of key a: negative value"},
+		{name: "nil to pointer", in: nil, typ: reflect.TypeFor[*int](), expected: (*int)(nil)},
+		{name: "nil to slice", in: nil, typ: reflect.TypeFor[[]int](), expected: []int(nil)},
+		{name: "nil to int", in: nil, typ: reflect.TypeFor[int](), errMsg: "incompatible type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerceValue(reflect.ValueOf(tt.in), tt.typ)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Type() != tt.typ {
+				t.Errorf("expected type %s, got %s", tt.typ, got.Type())
+			}
+			if !reflect.DeepEqual(got.Interface(), tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, got.Interface())
+			}
+		})
+	}
+}
+
+func TestFunctionArgumentCoercion(t *testing.T) {
+	ctx := Context{
+		"item":      map[string]any{"cents": 1299},
+		"fmt_price": func(cents int64) string { return strings.Repeat("$", int(cents/1000)) },
+		"half":      func(f float32) float64 { return float64(f) / 2 },
+		"sum": func(nums []int64) int64 {
+			var s int64
+			for _, n := range nums {
+				s += n
+			}
+			return s
+		},
+		"dict":       map[string]any{"a": 1, "b": 2.0},
+		"lookup":     func(m map[string]uint8, key string) uint8 { return m[key] },
+		"byte":       func(b int8) int8 { return b },
+		"sleep_secs": func(d time.Duration) float64 { return d.Seconds() },
+		"variadic":   func(nums ...uint) uint { return uint(len(nums)) },
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		errMsg   string
+	}{
+		{name: "int to int64", template: "{{ fmt_price(item.cents) }}", expected: "$"},
+		{name: "float literal to float32", template: "{{ half(3.0) }}", expected: "1.500000"},
+		{name: "list to typed slice", template: "{{ sum([1, 2, 3]) }}", expected: "6"},
+		{name: "map to typed map", template: `{{ lookup(dict, "b") }}`, expected: "2"},
+		{name: "string to duration", template: `{{ sleep_secs("1m") }}`, expected: "60.000000"},
+		{name: "variadic", template: "{{ variadic(1, 2, 3) }}", expected: "3"},
+		{name: "overflow", template: "{{ byte(200) }}", errMsg: "function input argument 0 of 'byte' can't be converted to int8: value 200 overflows int8"},
+		{name: "variadic negative", template: "{{ variadic(1, -2) }}", errMsg: "function variadic input argument of 'variadic' can't be converted to uint"},
+		{name: "incompatible", template: `{{ byte("a") }}`, errMsg: "must be of type int8 or *pongo2.Value (not string)"},
+	}
+
+	set := NewSet("test-coercion", &DummyLoader{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := set.RenderTemplateString(tt.template, ctx)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package pongo2
+
+import (
+	"net/http"
+	"sync"
+)
+
+// DebugHandler returns an http.Handler calling fn. If fn returns an error,
+// the handler responds with a debug page describing it (see WriteDebugPage).
+//
+// fn should render its templates with ExecuteWriter (or into a buffer), so
+// nothing is written to w if the execution fails:
+//
+//	http.Handle("/", pongo2.DebugHandler(func(w http.ResponseWriter, r *http.Request) error {
+//		return tpl.ExecuteWriter(pongo2.Context{"request": r}, w)
+//	}))
+//
+// The page shows parts of the template sources, so DebugHandler must only be
+// used on development servers.
+func DebugHandler(fn func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			WriteDebugPage(w, err)
+		}
+	})
+}
+
+// WriteDebugPage responds with status 500 and an HTML page describing err,
+// similar to Django's template debug page: for every *Error (all errors of an
+// *ErrorList), it shows the message, the error code, the source lines around
+// the error with the offending part marked and the include/macro/block stack.
+// Other errors are shown with their message only.
+//
+// The page shows parts of the template sources, so it must only be used on
+// development servers.
+func WriteDebugPage(w http.ResponseWriter, err error) {
+	type line struct {
+		Number        int
+		Text          string
+		IsError       bool
+		Before, After string
+	}
+	type pageError struct {
+		Message  string
+		Code     ErrorCode
+		Sender   string
+		Filename string
+		Line     int
+		Column   int
+		Lines    []line
+		Stack    []ErrorFrame
+	}
+
+	var errs []pageError
+	for _, e := range reportErrors(err) {
+		pe := pageError{
+			Code:     e.Code,
+			Sender:   e.Sender,
+			Filename: e.Filename,
+			Line:     e.Line,
+			Column:   e.Column,
+			Stack:    e.Stack,
+		}
+		if e.OrigError != nil {
+			pe.Message = e.OrigError.Error()
+		}
+		if excerpt, ok := e.Excerpt(debugPageContextLines); ok {
+			pe.Filename = excerpt.Filename
+			for _, l := range excerpt.Lines {
+				if l.Number == excerpt.Line {
+					before, at, after := excerpt.split(l.Text)
+					pe.Lines = append(pe.Lines, line{Number: l.Number, Text: at, IsError: true, Before: before, After: after})
+				} else {
+					pe.Lines = append(pe.Lines, line{Number: l.Number, Text: l.Text})
+				}
+			}
+		}
+		errs = append(errs, pe)
+	}
+	if len(errs) == 0 {
+		errs = append(errs, pageError{Message: err.Error()})
+	}
+
+	page, renderErr := debugPage().Execute(Context{"errors": errs})
+	if renderErr != nil {
+		// Shouldn't happen, but the error must be reported anyway
+		http.Error(w, ErrorReport(err, debugPageContextLines), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write([]byte(page))
+}
+
+// debugPageContextLines is the number of source lines shown before and after
+// the line of an error on the debug page.
+const debugPageContextLines = 5
+
+// debugPage is the (lazily compiled) template of the debug page.
+var debugPage = sync.OnceValue(func() *Template {
+	set := NewSet("pongo2-debug-page", NewMapLoader(nil))
+	return Must(set.FromString(debugPageTemplate))
+})
+
+const debugPageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex,nofollow">
+<title>{{ errors.0.Message|truncatechars:80 }}</title>
+<style>
+body { margin: 0; font: 14px/1.4 sans-serif; color: #222; }
+header { padding: 16px 24px; background: #ffc; border-bottom: 1px solid #ddd; }
+h1 { margin: 0 0 4px; font-size: 20px; }
+section { padding: 16px 24px; border-bottom: 1px solid #eee; }
+h2 { margin: 0 0 8px; font-size: 16px; }
+table.meta td { padding: 1px 12px 1px 0; vertical-align: top; }
+table.meta td:first-child { color: #666; }
+table.source { border-collapse: collapse; margin: 12px 0; font: 13px/1.5 monospace; white-space: pre; tab-size: 4; }
+table.source td { padding: 0 8px; }
+table.source td.number { color: #999; text-align: right; user-select: none; }
+table.source tr.error { background: #fdd; }
+table.source mark { background: #f66; color: #fff; }
+ul.stack { margin: 4px 0; padding-left: 20px; font-family: monospace; }
+</style>
+</head>
+<body>
+<header>
+<h1>Template error{{ errors|length|pluralize }}</h1>
+<div>{{ errors|length }} error{{ errors|length|pluralize }} occurred while compiling or executing a template.</div>
+</header>
+{% for e in errors %}
+<section>
+<h2>{{ e.Message }}</h2>
+<table class="meta">
+{% if e.Code %}<tr><td>Code</td><td>{{ e.Code }}</td></tr>{% endif %}
+{% if e.Filename %}<tr><td>Template</td><td>{{ e.Filename }}{% if e.Line %}, line {{ e.Line }}, column {{ e.Column }}{% endif %}</td></tr>{% endif %}
+{% if e.Sender %}<tr><td>Where</td><td>{{ e.Sender }}</td></tr>{% endif %}
+</table>
+{% if e.Lines %}
+<table class="source">
+{% for l in e.Lines %}
+{% if l.IsError %}<tr class="error"><td class="number">{{ l.Number }}</td><td>{{ l.Before }}<mark>{{ l.Text }}</mark>{{ l.After }}</td></tr>
+{% else %}<tr><td class="number">{{ l.Number }}</td><td>{{ l.Text }}</td></tr>
+{% endif %}
+{% endfor %}
+</table>
+{% endif %}
+{% if e.Stack %}
+<div>Stack (innermost first):</div>
+<ul class="stack">
+{% for frame in e.Stack %}<li>{{ frame.Kind }} '{{ frame.Name }}' at {{ frame.Filename }}:{{ frame.Line }}:{{ frame.Column }}</li>
+{% endfor %}
+</ul>
+{% endif %}
+</section>
+{% endfor %}
+</body>
+</html>
+`
@@ -180,10 +180,11 @@ func (vr *variableResolver) resolveInterface(ctx *ExecutionContext, i interface{
 	case *Template:
 		it.Options.Update(&Options{
 			DeepResolve:             ctx.DeepResolve,
-			AutoescapeFilter:        ctx.template.Options.AutoescapeFilter,
+			AutoescapeFilter:        ctx.AutoescapeFilter,
 			DisableContextFunctions: ctx.DisableContextFunctions,
 			DisableNestedFunctions:  ctx.DisableNestedFunctions,
 			IgnoreVariableCase:      ctx.IgnoreVariableCase,
+			StructTags:              ctx.StructTags,
 		})
 
 		resolved, err := it.Evaluate(ctx.Public)
@@ -204,10 +205,11 @@ func (vr *variableResolver) resolveInterface(ctx *ExecutionContext, i interface{
 		}
 		tpl.Options.Update(&Options{
 			DeepResolve:             ctx.DeepResolve,
-			AutoescapeFilter:        ctx.template.Options.AutoescapeFilter,
+			AutoescapeFilter:        ctx.AutoescapeFilter,
 			DisableContextFunctions: ctx.DisableContextFunctions,
 			DisableNestedFunctions:  ctx.DisableNestedFunctions,
 			IgnoreVariableCase:      ctx.IgnoreVariableCase,
+			StructTags:              ctx.StructTags,
 		})
 		resolved, err := tpl.Evaluate(ctx.Public)
 		if err != nil {
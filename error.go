@@ -2,6 +2,8 @@ package pongo2
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"strconv"
@@ -22,6 +24,165 @@ type Error struct {
 	Token     *Token
 	Sender    string
 	OrigError error
+
+	// Code classifies the error (see ErrorCode). It's empty for errors of
+	// custom tags and filters which don't set it.
+	Code ErrorCode
+
+	// Stack lists the includes, macros and blocks of parent templates the
+	// execution was in when the error occurred, innermost first.
+	Stack []ErrorFrame
+}
+
+// ErrorCode is a stable, machine-readable classification of an Error.
+type ErrorCode string
+
+const (
+	ErrCodeSyntax            ErrorCode = "syntax"             // invalid template syntax (lexer and parser)
+	ErrCodeUnknownTag        ErrorCode = "unknown-tag"        // tag not registered
+	ErrCodeUnknownFilter     ErrorCode = "unknown-filter"     // filter not registered
+	ErrCodeUnknownTest       ErrorCode = "unknown-test"       // test not registered
+	ErrCodeSandbox           ErrorCode = "sandbox-violation"  // tag, filter or test banned or not allowed
+	ErrCodeTemplateNotFound  ErrorCode = "template-not-found" // template (or ssi file) can't be found
+	ErrCodeUndefinedVariable ErrorCode = "undefined-variable" // undefined variable (with Options.StrictUndefined)
+	ErrCodeTypeMismatch      ErrorCode = "type-mismatch"      // value of the wrong type (e.g. calling a non-function)
+	ErrCodeDivideByZero      ErrorCode = "divide-by-zero"     // division or modulo by zero
+	ErrCodeFilter            ErrorCode = "filter"             // error returned by a filter
+	ErrCodeExecution         ErrorCode = "execution"          // any other execution error
+)
+
+// ErrorFrame is an entry of Error.Stack.
+type ErrorFrame struct {
+	// Kind is "include", "macro" or "block" (a block of a parent template
+	// overridden by a child template).
+	Kind string `json:"kind"`
+
+	// Name is the included template, the macro or the block.
+	Name string `json:"name"`
+
+	// The position of the include tag, the macro definition or the block tag
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// newErrorFrame creates a frame at the position of token (in tpl).
+func newErrorFrame(kind, name string, tpl *Template, token *Token) ErrorFrame {
+	frame := ErrorFrame{Kind: kind, Name: name, Filename: tpl.name}
+	if token != nil {
+		frame.Filename, frame.Line, frame.Column = token.Filename, token.Line, token.Col
+	}
+	return frame
+}
+
+// addErrorFrame appends frame to the stack of err if it's an *Error.
+func addErrorFrame(err error, frame ErrorFrame) error {
+	var e *Error
+	if errors.As(err, &e) {
+		e.Stack = append(e.Stack, frame)
+	}
+	return err
+}
+
+// codedError attaches an ErrorCode to a plain error. ExecutionContext.OrigError
+// takes the code over into the *Error it creates.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func withErrorCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+func (ce *codedError) Error() string {
+	return ce.err.Error()
+}
+
+func (ce *codedError) Unwrap() error {
+	return ce.err
+}
+
+// errorCodeOf returns the code of the outermost *Error or codedError in
+// err's chain.
+func errorCodeOf(err error) ErrorCode {
+	for err != nil {
+		switch e := err.(type) {
+		case *Error:
+			if e.Code != "" {
+				return e.Code
+			}
+		case *codedError:
+			return e.code
+		}
+		err = errors.Unwrap(err)
+	}
+	return ""
+}
+
+// withCode sets the code of the error.
+func (e *Error) withCode(code ErrorCode) *Error {
+	e.Code = code
+	return e
+}
+
+// Position is a position in a template's source. Offset is the byte offset,
+// Line and Column are 1-based (Column counts bytes).
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Range returns the source range of the error's token: start is the
+// position of the token, end the position right after it. ok is false if
+// the error has no token (or the token wasn't created by the lexer).
+func (e *Error) Range() (start, end Position, ok bool) {
+	t := e.Token
+	if t == nil || t.EndLine == 0 {
+		return Position{}, Position{}, false
+	}
+	return Position{Offset: t.Offset, Line: t.Line, Column: t.Col},
+		Position{Offset: t.EndOffset, Line: t.EndLine, Column: t.EndCol}, true
+}
+
+// errorJSON is the JSON representation of an Error.
+type errorJSON struct {
+	Code     ErrorCode    `json:"code,omitempty"`
+	Message  string       `json:"message"`
+	Sender   string       `json:"sender,omitempty"`
+	Filename string       `json:"filename,omitempty"`
+	Line     int          `json:"line,omitempty"`
+	Column   int          `json:"column,omitempty"`
+	Start    *Position    `json:"start,omitempty"`
+	End      *Position    `json:"end,omitempty"`
+	Token    string       `json:"token,omitempty"`
+	Stack    []ErrorFrame `json:"stack,omitempty"`
+}
+
+// MarshalJSON encodes the error as a JSON object with the fields code,
+// message (the message of OrigError), sender, filename, line, column, start
+// and end (see Range), token (the token's value) and stack. Empty fields are
+// omitted.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	out := errorJSON{
+		Code:     e.Code,
+		Sender:   e.Sender,
+		Filename: e.Filename,
+		Line:     e.Line,
+		Column:   e.Column,
+		Stack:    e.Stack,
+	}
+	if e.OrigError != nil {
+		out.Message = e.OrigError.Error()
+	}
+	if e.Token != nil {
+		out.Token = e.Token.Val
+	}
+	if start, end, ok := e.Range(); ok {
+		out.Start, out.End = &start, &end
+	}
+	return json.Marshal(out)
 }
 
 // updateFromTokenIfNeeded updates the error with template and token information
@@ -92,9 +253,88 @@ func (e *Error) Error() string {
 	return b.String()
 }
 
+// ErrorList is returned by the compilation of a template with
+// Options.CollectErrors. It holds all errors found in the template (and the
+// templates it's compiled with) in the order they were found.
+type ErrorList struct {
+	Errors []*Error
+}
+
+// add appends err, flattening ErrorLists. Errors of other types are wrapped
+// in an *Error.
+func (el *ErrorList) add(err error) {
+	var list *ErrorList
+	var e *Error
+	switch {
+	case errors.As(err, &list):
+		for _, e := range list.Errors {
+			el.addError(e)
+		}
+	case errors.As(err, &e):
+		el.addError(e)
+	default:
+		el.addError(&Error{Sender: "parser", OrigError: err})
+	}
+}
+
+// addError appends e unless there's already an error with the same message
+// at the same position (e.g. the EOF error of nested unclosed tags).
+func (el *ErrorList) addError(e *Error) {
+	for _, other := range el.Errors {
+		if other.Filename == e.Filename && other.Line == e.Line && other.Column == e.Column &&
+			errorMessage(other) == errorMessage(e) {
+			return
+		}
+	}
+	el.Errors = append(el.Errors, e)
+}
+
+// errorMessage returns the message of e without its position.
+func errorMessage(e *Error) string {
+	if e.OrigError == nil {
+		return ""
+	}
+	return e.OrigError.Error()
+}
+
+// Error returns the errors, one per line.
+func (el *ErrorList) Error() string {
+	msgs := make([]string, len(el.Errors))
+	for i, e := range el.Errors {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// MarshalJSON encodes the errors as a JSON array (see Error.MarshalJSON).
+func (el *ErrorList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(el.Errors)
+}
+
+// Unwrap returns the errors for use with errors.Is and errors.As.
+func (el *ErrorList) Unwrap() []error {
+	errs := make([]error, len(el.Errors))
+	for i, e := range el.Errors {
+		errs[i] = e
+	}
+	return errs
+}
+
 // RawLine returns the affected line from the original template, if available.
+// The line is taken from the source kept by the error's Template if possible
+// (which also works for string templates), otherwise it's read through the
+// template's loaders.
 func (e *Error) RawLine() (line string, available bool, outErr error) {
-	if e.Line <= 0 || e.Filename == "<string>" {
+	if e.Line <= 0 {
+		return "", false, nil
+	}
+	if lines, ok := e.sourceLines(); ok {
+		if e.Line > len(lines) {
+			return "", false, nil
+		}
+		return lines[e.Line-1], true, nil
+	}
+	if e.Filename == "<string>" {
 		return "", false, nil
 	}
 
@@ -0,0 +1,175 @@
+package pongo2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SourceExcerpt is the part of a template's source an error refers to.
+type SourceExcerpt struct {
+	// Filename is the name of the template.
+	Filename string
+
+	// Lines are the error's line and the lines around it.
+	Lines []SourceLine
+
+	// Line is the number of the error's line. Column and EndColumn are the
+	// 1-based byte columns of the offending part of the line; EndColumn is
+	// exclusive.
+	Line      int
+	Column    int
+	EndColumn int
+}
+
+// SourceLine is a line of a SourceExcerpt.
+type SourceLine struct {
+	Number int
+	Text   string
+}
+
+// sourceLines returns the lines of the source of the error's template (or
+// the dependency of it named e.Filename).
+func (e *Error) sourceLines() ([]string, bool) {
+	if e.Template == nil {
+		return nil, false
+	}
+	filename := e.Filename
+	if filename == "" {
+		filename = e.Template.name
+	}
+	code, ok := e.Template.sourceCode(filename)
+	if !ok {
+		return nil, false
+	}
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, true
+}
+
+// Excerpt returns the error's line and up to contextLines lines before and
+// after it. The source is taken from the template the error occurred in,
+// so this works for string templates, too. ok is false if the error has no
+// line or the template's source isn't available.
+func (e *Error) Excerpt(contextLines int) (excerpt *SourceExcerpt, ok bool) {
+	lines, ok := e.sourceLines()
+	if !ok || e.Line <= 0 || e.Line > len(lines) {
+		return nil, false
+	}
+
+	excerpt = &SourceExcerpt{
+		Filename:  e.Filename,
+		Line:      e.Line,
+		Column:    max(e.Column, 1),
+		EndColumn: max(e.Column, 1) + 1,
+	}
+	if excerpt.Filename == "" {
+		excerpt.Filename = e.Template.name
+	}
+	if _, end, ok := e.Range(); ok && end.Line == e.Line && end.Column > excerpt.Column {
+		excerpt.EndColumn = end.Column
+	}
+
+	first, last := max(e.Line-contextLines, 1), min(e.Line+contextLines, len(lines))
+	for n := first; n <= last; n++ {
+		excerpt.Lines = append(excerpt.Lines, SourceLine{Number: n, Text: lines[n-1]})
+	}
+	return excerpt, true
+}
+
+// split splits the text of the error's line into the parts before, at and
+// after the offending columns.
+func (excerpt *SourceExcerpt) split(text string) (before, at, after string) {
+	start := min(excerpt.Column-1, len(text))
+	end := min(max(excerpt.EndColumn-1, start), len(text))
+	return text[:start], text[start:end], text[end:]
+}
+
+// Report returns a multi-line description of the error: the error message
+// and code, the source lines around the error (see Excerpt) with a caret
+// marking the offending part of the line and the include/macro/block stack
+// that led to the error.
+//
+// Example:
+//
+//	[Error (where: execution) in page.html | Line 2 Col 11 near 'zero'] integer divide by zero
+//	Code: divide-by-zero
+//
+//	  1 | <h1>{{ title }}</h1>
+//	  2 | <p>{{ 1 / zero }}</p>
+//	    |           ^^^^
+//	  3 | <p>{{ text }}</p>
+//
+//	in include 'page.html' at base.html:5:4
+func (e *Error) Report(contextLines int) string {
+	var b strings.Builder
+	b.WriteString(e.Error())
+	if e.Code != "" {
+		b.WriteString("\nCode: " + string(e.Code))
+	}
+
+	if excerpt, ok := e.Excerpt(contextLines); ok {
+		width := len(fmt.Sprint(excerpt.Lines[len(excerpt.Lines)-1].Number))
+		b.WriteString("\n\n")
+		for _, line := range excerpt.Lines {
+			fmt.Fprintf(&b, "  %*d | %s\n", width, line.Number, line.Text)
+			if line.Number == excerpt.Line {
+				before, at, _ := excerpt.split(line.Text)
+				fmt.Fprintf(&b, "  %*s | %s%s\n", width, "", caretIndent(before), strings.Repeat("^", max(utf8.RuneCountInString(at), 1)))
+			}
+		}
+	}
+
+	if len(e.Stack) > 0 {
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		for _, frame := range e.Stack {
+			fmt.Fprintf(&b, "\nin %s '%s' at %s:%d:%d", frame.Kind, frame.Name, frame.Filename, frame.Line, frame.Column)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// caretIndent returns the whitespace to put in front of a caret to place it
+// after text (keeping tabs, so the caret lines up).
+func caretIndent(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, text)
+}
+
+// ErrorReport returns the Report of all *Errors of err (one for each error of
+// an *ErrorList), separated by blank lines. For other errors, it returns
+// err.Error().
+func ErrorReport(err error, contextLines int) string {
+	errs := reportErrors(err)
+	if len(errs) == 0 {
+		return err.Error()
+	}
+	reports := make([]string, len(errs))
+	for i, e := range errs {
+		reports[i] = e.Report(contextLines)
+	}
+	return strings.Join(reports, "\n\n")
+}
+
+// reportErrors returns the *Errors of err.
+func reportErrors(err error) []*Error {
+	var list *ErrorList
+	if errors.As(err, &list) {
+		return list.Errors
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return []*Error{e}
+	}
+	return nil
+}
@@ -1,8 +1,13 @@
 package pongo2
 
 import (
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"testing/fstest"
 )
@@ -132,3 +137,386 @@ func TestErrorRawLine(t *testing.T) {
 		}
 	})
 }
+
+func TestCollectErrors(t *testing.T) {
+	templates := map[string]string{
+		"base.html":  `{% block a %}{{ x|nofilter }}{% endblock %}`,
+		"child.html": `{% extends "base.html" %}{% block a %}{% nosuchtag %}{% endblock %}`,
+	}
+	set := NewSet("test-collect-errors", NewMapLoader(templates))
+	set.Options.CollectErrors = true
+	if err := set.BanTag("lorem"); err != nil {
+		t.Fatalf("BanTag failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		lines    []int
+		msgs     []string
+	}{
+		{
+			name:     "valid",
+			template: `{% if x %}{{ x|upper }}{% endif %}`,
+		},
+		{
+			name:     "independent errors",
+			template: "{{ x|nofilter }}\n{% nosuchtag %}\n{% lorem %}\n{{ x + }}\nok {{ y }}",
+			lines:    []int{1, 2, 3, 4},
+			msgs:     []string{"Filter 'nofilter' does not exist", "Tag 'nosuchtag' not found", "not allowed", ""},
+		},
+		{
+			name:     "errors inside blocks",
+			template: "{% for i in items %}\n{{ i|nofilter }}\n{% if %}\n{% endif %}{% endfor %}\n{% nosuchtag %}",
+			lines:    []int{2, 3, 5},
+			msgs:     []string{"Filter 'nofilter' does not exist", "", "Tag 'nosuchtag' not found"},
+		},
+		{
+			name:     "failed block tag",
+			template: "{% if x == %}\n{{ a|nofilter }}\n{% else %}\n{% endif %}\n{{ b|nofilter }}",
+			lines:    []int{1, 2, 5},
+			msgs:     []string{"", "Filter 'nofilter' does not exist", "Filter 'nofilter' does not exist"},
+		},
+		{
+			name:     "unknown tag inside failed block tag",
+			template: "{% if %}\n{% frobnicate %}\n{% endif %}",
+			lines:    []int{1, 2},
+			msgs:     []string{"", "Tag 'frobnicate' not found"},
+		},
+		{
+			name:     "misspelled intermediate tag of failed block tag",
+			template: "{% for x %}\n{% elsee %}\n{% empty %}\n{% endfor %}",
+			lines:    []int{1, 2},
+			msgs:     []string{"", "Tag 'elsee' not found"},
+		},
+		{
+			name:     "nested unclosed tags",
+			template: "{% if x %}\n{% if y %}\n{{ z }}",
+			lines:    []int{3},
+			msgs:     []string{"Unexpected EOF, expected tag elif or elseif or else or endif."},
+		},
+		{
+			name:     "unclosed tag inside block tag",
+			template: "{% if x %}\n{% for a in b %}\n{{ a|bad }}\n{% endif %}",
+			lines:    []int{3, 4},
+			msgs:     []string{"Filter 'bad' does not exist", "Unexpected tag 'endif', expected tag empty or endfor."},
+		},
+		{
+			name:     "lexer error",
+			template: "{{ x",
+			lines:    []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := set.FromString(tt.template)
+			if len(tt.lines) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var list *ErrorList
+			if !errors.As(err, &list) {
+				t.Fatalf("expected an *ErrorList, got %v", err)
+			}
+			if len(list.Errors) != len(tt.lines) {
+				t.Fatalf("expected %d errors, got %d:\n%v", len(tt.lines), len(list.Errors), err)
+			}
+			for i, e := range list.Errors {
+				if e.Line != tt.lines[i] {
+					t.Errorf("error %d: expected line %d, got %d (%v)", i, tt.lines[i], e.Line, e)
+				}
+				if i < len(tt.msgs) && !strings.Contains(e.Error(), tt.msgs[i]) {
+					t.Errorf("error %d: expected %q in %v", i, tt.msgs[i], e)
+				}
+			}
+		})
+	}
+
+	t.Run("dependencies", func(t *testing.T) {
+		_, err := set.FromFile("child.html")
+		var list *ErrorList
+		if !errors.As(err, &list) || len(list.Errors) != 2 {
+			t.Fatalf("expected 2 errors, got %v", err)
+		}
+		if list.Errors[0].Filename != "base.html" || list.Errors[1].Filename != "child.html" {
+			t.Errorf("unexpected files: %s, %s", list.Errors[0].Filename, list.Errors[1].Filename)
+		}
+		var e *Error
+		if !errors.As(err, &e) || e != list.Errors[0] {
+			t.Error("errors.As should find the first *Error")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := NewSet("test-no-collect-errors", &DummyLoader{}).FromString("{{ x|nofilter }}{% nosuchtag %}")
+		var list *ErrorList
+		var e *Error
+		if errors.As(err, &list) || !errors.As(err, &e) {
+			t.Errorf("expected a single *Error, got %v", err)
+		}
+	})
+}
+
+func TestErrorCodes(t *testing.T) {
+	templates := map[string]string{
+		"base.html":   "<title>{% block title %}{% endblock %}</title>",
+		"child.html":  "{% extends \"base.html\" %}{% block title %}{{ 1 / zero }}{% endblock %}",
+		"widget.html": "\n  {{ 1 / zero }}",
+		"page.html":   "{% macro m() %}{% include \"widget.html\" %}{% endmacro %}\n{{ m() }}",
+	}
+	set := NewSet("test-error-codes", NewMapLoader(templates))
+	if err := set.BanFilter("lower"); err != nil {
+		t.Fatalf("BanFilter failed: %v", err)
+	}
+
+	compileErrors := []struct {
+		template string
+		code     ErrorCode
+	}{
+		{`{{ x|nofilter }}`, ErrCodeUnknownFilter},
+		{`{% nosuchtag %}`, ErrCodeUnknownTag},
+		{`{% if x is nosuchtest %}{% endif %}`, ErrCodeUnknownTest},
+		{`{{ x|lower }}`, ErrCodeSandbox},
+		{`{{ x + }}`, ErrCodeSyntax},
+		{`{{ "x }}`, ErrCodeSyntax},
+	}
+	for _, tt := range compileErrors {
+		t.Run(tt.template, func(t *testing.T) {
+			_, err := set.FromString(tt.template)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if e.Code != tt.code {
+				t.Errorf("expected code %q, got %q (%v)", tt.code, e.Code, err)
+			}
+		})
+	}
+
+	ctx := Context{
+		"zero": 0,
+		"s":    "str",
+		"fn":   func(i int) int { return i },
+		"name": "missing.html",
+	}
+	executionErrors := []struct {
+		template string
+		code     ErrorCode
+	}{
+		{`{{ 1 / zero }}`, ErrCodeDivideByZero},
+		{`{{ 1 % zero }}`, ErrCodeDivideByZero},
+		{`{{ s() }}`, ErrCodeTypeMismatch},
+		{`{{ fn("x") }}`, ErrCodeTypeMismatch},
+		{`{{ 1|slice:"x" }}`, ErrCodeFilter},
+		{`{% include name %}`, ErrCodeTemplateNotFound},
+	}
+	for _, tt := range executionErrors {
+		t.Run(tt.template, func(t *testing.T) {
+			tpl, err := set.FromString(tt.template)
+			if err != nil {
+				t.Fatalf("unexpected compile error: %v", err)
+			}
+			_, err = tpl.Execute(ctx)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if e.Code != tt.code {
+				t.Errorf("expected code %q, got %q (%v)", tt.code, e.Code, err)
+			}
+		})
+	}
+
+	t.Run("template not found", func(t *testing.T) {
+		_, err := set.FromFile("missing.html")
+		var e *Error
+		if !errors.As(err, &e) || e.Code != ErrCodeTemplateNotFound {
+			t.Errorf("expected code %q, got %v", ErrCodeTemplateNotFound, err)
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		_, err := set.FromString("ab\n{{ x|nofilter }}")
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected an *Error, got %v", err)
+		}
+		start, end, ok := e.Range()
+		if !ok {
+			t.Fatal("expected a range")
+		}
+		if expected := (Position{Offset: 8, Line: 2, Column: 6}); start != expected {
+			t.Errorf("expected start %+v, got %+v", expected, start)
+		}
+		if expected := (Position{Offset: 16, Line: 2, Column: 14}); end != expected {
+			t.Errorf("expected end %+v, got %+v", expected, end)
+		}
+	})
+
+	t.Run("stack", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			stack []ErrorFrame
+		}{
+			{"child.html", []ErrorFrame{
+				{Kind: "block", Name: "title", Filename: "base.html", Line: 1, Column: 11},
+			}},
+			{"page.html", []ErrorFrame{
+				{Kind: "include", Name: "widget.html", Filename: "page.html", Line: 1, Column: 19},
+				{Kind: "macro", Name: "m", Filename: "page.html", Line: 1, Column: 4},
+			}},
+		}
+		for _, tt := range tests {
+			tpl, err := set.FromFile(tt.name)
+			if err != nil {
+				t.Fatalf("unexpected compile error: %v", err)
+			}
+			_, err = tpl.Execute(ctx)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("%s: expected an *Error, got %v", tt.name, err)
+			}
+			if e.Code != ErrCodeDivideByZero {
+				t.Errorf("%s: expected code %q, got %q", tt.name, ErrCodeDivideByZero, e.Code)
+			}
+			if !reflect.DeepEqual(e.Stack, tt.stack) {
+				t.Errorf("%s: expected stack %+v, got %+v", tt.name, tt.stack, e.Stack)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		_, err := set.FromString("{{ x|nofilter }}")
+		b, jerr := json.Marshal(err)
+		if jerr != nil {
+			t.Fatalf("marshalling failed: %v", jerr)
+		}
+		expected := `{"code":"unknown-filter","message":"Filter 'nofilter' does not exist.","sender":"parser","filename":"\u003cstring\u003e","line":1,"column":6,` +
+			`"start":{"offset":5,"line":1,"column":6},"end":{"offset":13,"line":1,"column":14},"token":"nofilter"}`
+		if string(b) != expected {
+			t.Errorf("expected %s, got %s", expected, b)
+		}
+
+		set := NewSet("test-error-codes-json", &DummyLoader{})
+		set.Options.CollectErrors = true
+		_, err = set.FromString("{{ x|nofilter }}{% nosuchtag %}")
+		b, jerr = json.Marshal(err)
+		if jerr != nil {
+			t.Fatalf("marshalling failed: %v", jerr)
+		}
+		var list []map[string]any
+		if err := json.Unmarshal(b, &list); err != nil {
+			t.Fatalf("expected a JSON array, got %s", b)
+		}
+		if len(list) != 2 || list[0]["code"] != "unknown-filter" || list[1]["code"] != "unknown-tag" {
+			t.Errorf("unexpected JSON: %s", b)
+		}
+	})
+}
+
+func TestErrorReport(t *testing.T) {
+	templates := map[string]string{
+		"base.html": "<main>\n\t{% include \"page.html\" %}\n</main>",
+		"page.html": "<h1>{{ title }}</h1>\n<p>{{ 1 / zero }}</p>\n<p>{{ text }}</p>",
+	}
+	set := NewSet("test-error-report", NewMapLoader(templates))
+
+	t.Run("string template", func(t *testing.T) {
+		_, err := set.FromString("a\n\tb {{ x|nofilter }}\nc")
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected an *Error, got %v", err)
+		}
+		line, available, lerr := e.RawLine()
+		if !available || lerr != nil || line != "\tb {{ x|nofilter }}" {
+			t.Errorf("unexpected RawLine result: %q, %v, %v", line, available, lerr)
+		}
+		expected := e.Error() + "\nCode: unknown-filter\n\n" +
+			"  1 | a\n" +
+			"  2 | \tb {{ x|nofilter }}\n" +
+			"    | \t       ^^^^^^^^\n" +
+			"  3 | c"
+		if report := e.Report(1); report != expected {
+			t.Errorf("expected report:\n%s\ngot:\n%s", expected, report)
+		}
+	})
+
+	t.Run("include", func(t *testing.T) {
+		tpl, err := set.FromFile("base.html")
+		if err != nil {
+			t.Fatalf("unexpected compile error: %v", err)
+		}
+		_, err = tpl.Execute(Context{"zero": 0})
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected an *Error, got %v", err)
+		}
+		expected := e.Error() + "\nCode: divide-by-zero\n\n" +
+			"  2 | <p>{{ 1 / zero }}</p>\n" +
+			"    |           ^^^^\n\n" +
+			"in include 'page.html' at base.html:2:5"
+		if report := ErrorReport(err, 0); report != expected {
+			t.Errorf("expected report:\n%s\ngot:\n%s", expected, report)
+		}
+	})
+
+	t.Run("error list", func(t *testing.T) {
+		set := NewSet("test-error-report-list", &DummyLoader{})
+		set.Options.CollectErrors = true
+		_, err := set.FromString("{{ x|nofilter }}\n{% nosuchtag %}")
+		report := ErrorReport(err, 0)
+		if !strings.Contains(report, "  1 | {{ x|nofilter }}\n    |      ^^^^^^^^") ||
+			!strings.Contains(report, "  2 | {% nosuchtag %}\n    |    ^^^^^^^^^") {
+			t.Errorf("unexpected report:\n%s", report)
+		}
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		if report := ErrorReport(errors.New("plain"), 3); report != "plain" {
+			t.Errorf("expected the error message, got %q", report)
+		}
+	})
+}
+
+func TestDebugHandler(t *testing.T) {
+	set := NewSet("test-debug-handler", &DummyLoader{})
+	tpl, err := set.FromString("<p>\n{{ value|slice:\"x\" }}</p>")
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+
+	handler := DebugHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return tpl.ExecuteWriter(Context{"value": "<b>"}, w)
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"<td>Code</td><td>filter</td>",
+		`<tr class="error"><td class="number">2</td><td>{{ value|<mark>slice</mark>:&quot;x&quot; }}&lt;/p&gt;</td></tr>`,
+		`<tr><td class="number">1</td><td>&lt;p&gt;</td></tr>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected the page to contain %q, got:\n%s", expected, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	DebugHandler(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("expected the handler's response, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,752 @@
+package pongo2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Contextual autoescaping (Options.ContextualAutoescape) tracks the state of
+// an HTML parser across the HTML parts of a template while it's compiled, so
+// that every {{ variable }} knows where in the document its output ends up
+// and can be escaped accordingly: as HTML text, attribute value, URL,
+// JavaScript or CSS. Output positions that can't be escaped safely (e.g.
+// tag or attribute names, comments) are rejected at compile time.
+//
+// The state is tracked in document order. The branches of {% if %} and
+// similar tags are compiled from the same context and have to end in the
+// same context, loop bodies in the context they start in. Every template
+// (including included ones and block overrides of child templates) starts in
+// HTML text. Tags inserting other templates ({% block %}, {% include %},
+// {% ssi %}) are therefore only allowed in HTML text, as are custom tags. The output of builtin tags like {% firstof %} or {% cycle %}
+// is escaped like the output of variables.
+
+// htmlParserState is the state of the HTML tokenizer.
+type htmlParserState uint8
+
+const (
+	htmlText            htmlParserState = iota // HTML text
+	htmlTagOpen                                // after '<'
+	htmlBang                                   // after '<!'
+	htmlBangDash                               // after '<!-'
+	htmlComment                                // in <!-- -->
+	htmlDeclaration                            // in <!doctype> and similar
+	htmlEndTagOpen                             // after '</'
+	htmlTagName                                // in a tag name
+	htmlTag                                    // in a tag, before an attribute name
+	htmlAttrName                               // in an attribute name
+	htmlAfterAttrName                          // after an attribute name
+	htmlBeforeAttrValue                        // after '='
+	htmlAttrValue                              // in an attribute value
+	htmlRawText                                // in the content of <script>, <style>, <textarea> or <title>
+)
+
+// attrType is the kind of content of an attribute value or raw text element.
+type attrType uint8
+
+const (
+	attrPlain attrType = iota
+	attrURL
+	attrJS
+	attrCSS
+)
+
+// urlPart is the part of a URL an output position is in.
+type urlPart uint8
+
+const (
+	urlStart urlPart = iota // at the start, the scheme may follow
+	urlPath                 // before the query
+	urlQuery                // in the query or fragment
+)
+
+// jsState is the state of the (simplified) JS or CSS tokenizer.
+type jsState uint8
+
+const (
+	jsExpr         jsState = iota // in an expression
+	jsDoubleQuoted                // in a "string"
+	jsSingleQuoted                // in a 'string'
+	jsTemplate                    // in a `template literal` (JS only)
+	jsLineComment                 // in a // comment (JS only)
+	jsBlockComment                // in a /* comment */
+)
+
+// urlAttributes are the attributes containing URLs.
+var urlAttributes = map[string]bool{
+	"action": true, "archive": true, "background": true, "cite": true,
+	"classid": true, "codebase": true, "data": true, "formaction": true,
+	"href": true, "icon": true, "longdesc": true, "manifest": true,
+	"ping": true, "poster": true, "profile": true, "src": true,
+	"srcset": true, "usemap": true, "xlink:href": true,
+}
+
+// htmlContext tracks where in an HTML document the parser currently is.
+type htmlContext struct {
+	state   htmlParserState
+	closing bool // the current tag is an end tag
+	tagName string
+	attr    string
+	quote   byte // quote of the current attribute value, 0 if unquoted
+
+	// The content type of the current attribute value or raw text element
+	typ      attrType
+	url      urlPart
+	js       jsState
+	jsEscape bool // the previous char was a backslash in a JS/CSS string
+	jsSlash  bool // the previous char was a '/' in a JS/CSS expression
+	jsStar   bool // the previous char was a '*' in a JS/CSS block comment
+	dashes   int  // number of '-' before the current char in a comment
+
+	// The end tag closing the current raw text element (e.g. "</script") and
+	// the number of its chars matched so far
+	rawEnd     string
+	rawMatched int
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// feed advances the context over the HTML s.
+func (hc *htmlContext) feed(s string) {
+	for i := 0; i < len(s); i++ {
+		hc.next(s[i])
+	}
+}
+
+func (hc *htmlContext) next(c byte) {
+	switch hc.state {
+	case htmlText:
+		if c == '<' {
+			hc.state = htmlTagOpen
+		}
+	case htmlTagOpen:
+		switch {
+		case c == '!':
+			hc.state = htmlBang
+		case c == '/':
+			hc.state = htmlEndTagOpen
+		case isASCIILetter(c):
+			hc.state, hc.closing, hc.tagName = htmlTagName, false, string(lowerASCII(c))
+		default:
+			hc.state = htmlText
+			hc.next(c)
+		}
+	case htmlBang:
+		if c == '-' {
+			hc.state = htmlBangDash
+		} else {
+			hc.state = htmlDeclaration
+			hc.next(c)
+		}
+	case htmlBangDash:
+		if c == '-' {
+			hc.state, hc.dashes = htmlComment, 0
+		} else {
+			hc.state = htmlDeclaration
+			hc.next(c)
+		}
+	case htmlComment:
+		switch {
+		case c == '-':
+			hc.dashes++
+		case c == '>' && hc.dashes >= 2:
+			hc.state = htmlText
+		default:
+			hc.dashes = 0
+		}
+	case htmlDeclaration:
+		if c == '>' {
+			hc.state = htmlText
+		}
+	case htmlEndTagOpen:
+		if isASCIILetter(c) {
+			hc.state, hc.closing, hc.tagName = htmlTagName, true, string(lowerASCII(c))
+		} else {
+			hc.state = htmlDeclaration
+			hc.next(c)
+		}
+	case htmlTagName:
+		switch {
+		case isHTMLSpace(c) || c == '/':
+			hc.state = htmlTag
+		case c == '>':
+			hc.endTag()
+		default:
+			hc.tagName += string(lowerASCII(c))
+		}
+	case htmlTag:
+		switch {
+		case isHTMLSpace(c) || c == '/':
+		case c == '>':
+			hc.endTag()
+		default:
+			hc.state, hc.attr = htmlAttrName, string(lowerASCII(c))
+		}
+	case htmlAttrName:
+		switch {
+		case isHTMLSpace(c):
+			hc.state = htmlAfterAttrName
+		case c == '=':
+			hc.state = htmlBeforeAttrValue
+		case c == '/':
+			hc.state = htmlTag
+		case c == '>':
+			hc.endTag()
+		default:
+			hc.attr += string(lowerASCII(c))
+		}
+	case htmlAfterAttrName:
+		switch {
+		case isHTMLSpace(c):
+		case c == '=':
+			hc.state = htmlBeforeAttrValue
+		case c == '/':
+			hc.state = htmlTag
+		case c == '>':
+			hc.endTag()
+		default:
+			hc.state, hc.attr = htmlAttrName, string(lowerASCII(c))
+		}
+	case htmlBeforeAttrValue:
+		switch {
+		case isHTMLSpace(c):
+		case c == '"' || c == '\'':
+			hc.startAttrValue(c)
+		case c == '>':
+			hc.endTag()
+		default:
+			hc.startAttrValue(0)
+			hc.next(c)
+		}
+	case htmlAttrValue:
+		switch {
+		case hc.quote != 0 && c == hc.quote, hc.quote == 0 && isHTMLSpace(c):
+			hc.state = htmlTag
+		case hc.quote == 0 && c == '>':
+			hc.endTag()
+		default:
+			hc.content(c)
+		}
+	case htmlRawText:
+		// The end tag closes the element no matter what the content is
+		if lowerASCII(c) == hc.rawEnd[hc.rawMatched] {
+			hc.rawMatched++
+			if hc.rawMatched == len(hc.rawEnd) {
+				hc.state, hc.closing, hc.tagName = htmlTagName, true, hc.rawEnd[2:]
+			}
+			return
+		}
+		hc.rawMatched = 0
+		if c == '<' {
+			hc.rawMatched = 1
+		}
+		hc.content(c)
+	}
+}
+
+// startAttrValue enters the value of the current attribute.
+func (hc *htmlContext) startAttrValue(quote byte) {
+	hc.state, hc.quote = htmlAttrValue, quote
+	hc.typ, hc.url, hc.js = attrPlain, urlStart, jsExpr
+	hc.jsEscape, hc.jsSlash, hc.jsStar = false, false, false
+	hc.typ = attrValueType(hc.attr)
+}
+
+// attrValueType returns the content type of the value of the attribute.
+func attrValueType(attr string) attrType {
+	switch {
+	case strings.HasPrefix(attr, "on"):
+		return attrJS
+	case attr == "style":
+		return attrCSS
+	case urlAttributes[attr] || strings.Contains(attr, "url") || strings.Contains(attr, "uri"):
+		return attrURL
+	}
+	return attrPlain
+}
+
+// endTag handles the '>' of a tag.
+func (hc *htmlContext) endTag() {
+	hc.state = htmlText
+	if hc.closing {
+		return
+	}
+	switch hc.tagName {
+	case "script", "style", "textarea", "title":
+		hc.state = htmlRawText
+		hc.rawEnd, hc.rawMatched = "</"+hc.tagName, 0
+		hc.js, hc.jsEscape, hc.jsSlash, hc.jsStar = jsExpr, false, false, false
+		hc.typ = attrPlain
+		switch hc.tagName {
+		case "script":
+			hc.typ = attrJS
+		case "style":
+			hc.typ = attrCSS
+		}
+	}
+}
+
+// content advances the state of the URL, JS or CSS tokenizer.
+func (hc *htmlContext) content(c byte) {
+	switch hc.typ {
+	case attrURL:
+		if c == '?' || c == '#' {
+			hc.url = urlQuery
+		} else if hc.url == urlStart {
+			hc.url = urlPath
+		}
+	case attrJS, attrCSS:
+		hc.scriptContent(c)
+	}
+}
+
+func (hc *htmlContext) scriptContent(c byte) {
+	slash := hc.jsSlash
+	hc.jsSlash = false
+	switch hc.js {
+	case jsExpr:
+		switch {
+		case c == '"':
+			hc.js = jsDoubleQuoted
+		case c == '\'':
+			hc.js = jsSingleQuoted
+		case c == '`' && hc.typ == attrJS:
+			hc.js = jsTemplate
+		case c == '*' && slash:
+			hc.js, hc.jsStar = jsBlockComment, false
+		case c == '/' && slash && hc.typ == attrJS:
+			hc.js = jsLineComment
+		case c == '/':
+			hc.jsSlash = true
+		}
+	case jsDoubleQuoted, jsSingleQuoted, jsTemplate:
+		switch {
+		case hc.jsEscape:
+			hc.jsEscape = false
+		case c == '\\':
+			hc.jsEscape = true
+		case c == '"' && hc.js == jsDoubleQuoted, c == '\'' && hc.js == jsSingleQuoted, c == '`' && hc.js == jsTemplate:
+			hc.js = jsExpr
+		}
+	case jsLineComment:
+		if c == '\n' || c == '\r' {
+			hc.js = jsExpr
+		}
+	case jsBlockComment:
+		if c == '/' && hc.jsStar {
+			hc.js = jsExpr
+		}
+		hc.jsStar = c == '*'
+	}
+}
+
+// escapeMode is the escaper used for an output position.
+type escapeMode uint8
+
+const (
+	escapeHTML      escapeMode = iota // HTML text (uses Options.AutoescapeFilter)
+	escapeRCDATA                      // content of <textarea> and <title>
+	escapeAttr                        // attribute value
+	escapeURL                         // start of a URL
+	escapeURLPart                     // rest of a URL before the query
+	escapeURLQuery                    // URL query or fragment
+	escapeJSValue                     // JS expression
+	escapeJSString                    // JS string literal
+	escapeCSSValue                    // CSS outside of strings
+	escapeCSSString                   // CSS string
+)
+
+// contextEscaper escapes the output of a {{ variable }} for its position in
+// the HTML document.
+type contextEscaper struct {
+	mode escapeMode
+
+	// attr is set if the output is in an attribute value (which is escaped
+	// after mode's escaping), unquoted is set for unquoted attribute values
+	attr     bool
+	unquoted bool
+}
+
+// outputEscaper returns the escaper for output at the current position, or
+// an error if output isn't allowed here. It also advances the state over
+// the output (which is unknown at compile time).
+func (hc *htmlContext) outputEscaper() (*contextEscaper, error) {
+	switch hc.state {
+	case htmlText:
+		return &contextEscaper{mode: escapeHTML}, nil
+	case htmlTagOpen, htmlEndTagOpen, htmlTagName:
+		return nil, errors.New("a tag name")
+	case htmlTag, htmlAttrName, htmlAfterAttrName:
+		return nil, errors.New("an attribute name (or a list of attributes)")
+	case htmlBang, htmlBangDash, htmlComment, htmlDeclaration:
+		return nil, errors.New("an HTML comment or declaration")
+	case htmlBeforeAttrValue:
+		hc.startAttrValue(0)
+	}
+
+	esc := &contextEscaper{
+		attr:     hc.state == htmlAttrValue,
+		unquoted: hc.state == htmlAttrValue && hc.quote == 0,
+	}
+	switch hc.typ {
+	case attrPlain:
+		esc.mode = escapeAttr
+		if hc.state == htmlRawText {
+			esc.mode = escapeRCDATA
+		}
+	case attrURL:
+		esc.mode = map[urlPart]escapeMode{urlStart: escapeURL, urlPath: escapeURLPart, urlQuery: escapeURLQuery}[hc.url]
+		if hc.url == urlStart {
+			hc.url = urlPath
+		}
+	case attrJS, attrCSS:
+		hc.jsSlash = false
+		switch hc.js {
+		case jsExpr:
+			esc.mode = escapeJSValue
+			if hc.typ == attrCSS {
+				esc.mode = escapeCSSValue
+			}
+		case jsDoubleQuoted, jsSingleQuoted:
+			esc.mode = escapeJSString
+			if hc.typ == attrCSS {
+				esc.mode = escapeCSSString
+			}
+		case jsTemplate:
+			return nil, errors.New("a JavaScript template literal")
+		default:
+			return nil, errors.New("a JavaScript or CSS comment")
+		}
+	}
+	return esc, nil
+}
+
+// significant returns the context with only the fields set which affect how
+// the rest of the document is tracked in its state, so that equal positions
+// compare equal.
+func (hc htmlContext) significant() htmlContext {
+	sc := htmlContext{state: hc.state}
+	switch hc.state {
+	case htmlComment:
+		sc.dashes = hc.dashes
+	case htmlTagName, htmlTag:
+		sc.closing, sc.tagName = hc.closing, hc.tagName
+	case htmlAttrName, htmlAfterAttrName, htmlBeforeAttrValue:
+		sc.closing, sc.tagName, sc.attr = hc.closing, hc.tagName, hc.attr
+	case htmlAttrValue, htmlRawText:
+		sc.closing, sc.tagName, sc.quote = hc.closing, hc.tagName, hc.quote
+		sc.typ, sc.url, sc.js = hc.typ, hc.url, hc.js
+		sc.jsEscape, sc.jsSlash, sc.jsStar = hc.jsEscape, hc.jsSlash, hc.jsStar
+		sc.rawEnd, sc.rawMatched = hc.rawEnd, hc.rawMatched
+	}
+	return sc
+}
+
+// joinHTMLContexts returns the context after two alternative parts of the
+// document ending in a and b, or false if they end in different contexts.
+// A part adding a plain attribute without value (as in
+// `<input {% if c %}disabled{% endif %}>`) joins one ending before the
+// attribute: the document goes on in the tag, where output isn't allowed.
+func joinHTMLContexts(a, b htmlContext) (htmlContext, bool) {
+	a, b = a.significant(), b.significant()
+	if a == b {
+		return a, true
+	}
+	for _, pair := range [][2]htmlContext{{a, b}, {b, a}} {
+		attr, tag := pair[0], pair[1]
+		if (attr.state == htmlAttrName || attr.state == htmlAfterAttrName) && attrValueType(attr.attr) == attrPlain {
+			attr.state, attr.attr = htmlTag, ""
+			if attr == tag {
+				return tag, true
+			}
+		}
+	}
+	return htmlContext{}, false
+}
+
+// allowsTemplates reports whether tags inserting other templates (block,
+// include, ssi) may be used at the current position.
+func (hc *htmlContext) allowsTemplates() bool {
+	return hc.state == htmlText
+}
+
+// tagOutput describes how a tag produces its output.
+type tagOutput uint8
+
+const (
+	tagOutputUnknown tagOutput = iota // custom tags, only allowed in HTML text
+	tagOutputBody                     // output comes from the tag's body only
+	tagOutputDirect                   // the tag writes output itself
+)
+
+// contextTagOutput returns how the builtin tag node produces its output.
+// The bodies of tagOutputBody tags are compiled with the HTML context like
+// the rest of the template; the output of tagOutputDirect tags is escaped
+// for its position like a {{ variable }}. Other tags (including custom ones,
+// and builtin tags replaced by the set) are only allowed in HTML text.
+func contextTagOutput(node INodeTag) tagOutput {
+	switch node.(type) {
+	case *tagAutoescapeNode, *tagCommentNode, *tagExtendsNode, *tagFilterNode, *tagFlushNode,
+		*tagForNode, *tagIfNode, *tagIfchangedNode, *tagIfEqualNode, *tagIfNotEqualNode,
+		*tagImportNode, *tagMacroNode, *tagSetNode, *tagSpacelessNode, *tagWithNode:
+		return tagOutputBody
+	case *tagCycleNode, *tagFirstofNode, *tagLoremNode, *tagNowNode, *tagTemplateTagNode,
+		*tagTranslateNode, *tagWidthratioNode:
+		return tagOutputDirect
+	}
+	return tagOutputUnknown
+}
+
+// htmlBranches checks the branches of a conditional or loop tag (if, for,
+// ifchanged, ifequal, ifnotequal): each branch is compiled from the HTML
+// context the tag starts in and all of them have to end in the same context,
+// as it's only known at execution time which one is rendered.
+type htmlBranches struct {
+	p     *Parser
+	tag   *Token
+	start htmlContext
+	end   *htmlContext // joined end context of the branches parsed so far
+}
+
+// htmlBranches starts checking the branches of the tag starting at tag. It
+// returns nil (on which all methods are no-ops) without contextual autoescape.
+func (p *Parser) htmlBranches(tag *Token) *htmlBranches {
+	if p.html == nil {
+		return nil
+	}
+	return &htmlBranches{p: p, tag: tag, start: *p.html}
+}
+
+// branch is called after each branch has been parsed. It compares the end
+// context with the one of the previous branches and resets the context for
+// the next branch.
+func (b *htmlBranches) branch() error {
+	if b == nil {
+		return nil
+	}
+	end := *b.p.html
+	*b.p.html = b.start
+	if b.end != nil {
+		joined, ok := joinHTMLContexts(*b.end, end)
+		if !ok {
+			return b.p.Error(fmt.Sprintf("Contextual autoescape: the branches of tag '%s' end in different HTML contexts.", b.tag.Val), b.tag)
+		}
+		end = joined
+	}
+	b.end = &end
+	return nil
+}
+
+// loop is called after the body of a loop has been parsed, which has to end
+// in the context it starts in (for the next iteration).
+func (b *htmlBranches) loop() error {
+	if b == nil {
+		return nil
+	}
+	if _, ok := joinHTMLContexts(b.start, *b.p.html); ok {
+		return nil
+	}
+	return b.p.Error(fmt.Sprintf("Contextual autoescape: the body of tag '%s' must end in the HTML context it starts in.", b.tag.Val), b.tag)
+}
+
+// done is called after the last branch. Unless the branches are exhaustive
+// (there's an else branch), none of them may be rendered, so they have to end
+// in the context the tag starts in. The tag ends in the branches' context.
+func (b *htmlBranches) done(exhaustive bool) error {
+	if b == nil {
+		return nil
+	}
+	if !exhaustive {
+		if err := b.branch(); err != nil {
+			return err
+		}
+	}
+	*b.p.html = *b.end
+	return nil
+}
+
+// escapeFailed replaces values which are unsafe in URL and CSS contexts.
+const escapeFailed = "ZpongoZ"
+
+// escape escapes value for the output position. explicitSafe is set if the
+// |safe filter was applied, which disables escaping in all contexts; values
+// marked as safe otherwise (e.g. by filters) are only trusted in HTML text
+// and attribute values.
+func (esc *contextEscaper) escape(ctx *ExecutionContext, value *Value, explicitSafe bool) (*Value, error) {
+	if explicitSafe {
+		return value, nil
+	}
+
+	switch esc.mode {
+	case escapeHTML:
+		// Same as without contextual autoescaping
+		if value.isSafe() || !value.IsString() {
+			return value, nil
+		}
+		if escapeFn := ctx.template.set.filters[ctx.AutoescapeFilter]; escapeFn != nil {
+			return escapeFn(value, nil)
+		}
+		return value, nil
+	case escapeRCDATA, escapeAttr:
+		if value.isSafe() && !esc.unquoted {
+			return value, nil
+		}
+	}
+
+	var s string
+	switch esc.mode {
+	case escapeRCDATA, escapeAttr:
+		s = value.String()
+	case escapeURL:
+		s = normalizeURL(filterURLScheme(value.String()))
+	case escapeURLPart:
+		s = normalizeURL(value.String())
+	case escapeURLQuery:
+		s = url.QueryEscape(value.String())
+	case escapeJSValue:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("contextual autoescape: %w", err)
+		}
+		// Prevent the value from being parsed as a (regex) literal suffix
+		s = " " + escapeJSLineTerminators(string(b)) + " "
+	case escapeJSString:
+		s = escapeJSStringContent(value.String())
+	case escapeCSSValue:
+		s = filterCSSValue(value.String())
+	case escapeCSSString:
+		s = escapeCSSStringContent(value.String())
+	}
+
+	// In <script> and <style>, the escapers above already took care of '<'
+	switch {
+	case esc.unquoted:
+		s = escapeUnquotedAttr(s)
+	case esc.attr || esc.mode == escapeRCDATA:
+		s = htmlEscapeReplacer.Replace(s)
+	}
+	return AsSafeValue(s), nil
+}
+
+// filterURLScheme replaces URLs with schemes other than http, https and
+// mailto (like javascript:) by "#ZpongoZ".
+func filterURLScheme(s string) string {
+	if i := strings.IndexAny(s, ":/?#"); i > 0 && s[i] == ':' {
+		switch strings.ToLower(strings.TrimSpace(s[:i])) {
+		case "http", "https", "mailto":
+		default:
+			return "#" + escapeFailed
+		}
+	}
+	return s
+}
+
+// normalizeURL percent-encodes the chars not allowed in URLs, keeping
+// reserved chars and existing percent-encodings as they are.
+func normalizeURL(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b.WriteByte(c)
+		case strings.IndexByte("-._~:/?#[]@!$&*+,;=", c) >= 0:
+			b.WriteByte(c)
+		case c == '%' && i+2 < len(s) && isHexDigit(s[i+1]) && isHexDigit(s[i+2]):
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// escapeJSLineTerminators escapes U+2028 and U+2029, which end lines in JS.
+func escapeJSLineTerminators(s string) string {
+	return strings.NewReplacer("\u2028", `\u2028`, "\u2029", `\u2029`).Replace(s)
+}
+
+// escapeJSStringContent escapes s for a JS string literal. All ASCII chars
+// other than letters, digits and spaces are escaped, so the result is safe
+// in single and double quoted strings as well as in HTML.
+func escapeJSStringContent(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			b.WriteString(`\uFFFD`)
+		case r < utf8.RuneSelf && (isASCIILetter(byte(r)) || (r >= '0' && r <= '9') || r == ' '):
+			b.WriteRune(r)
+		case r < utf8.RuneSelf, r == '\u2028', r == '\u2029':
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// filterCSSValue allows simple CSS values like "12px", "#fff", "bold" or
+// "1px solid red" and replaces everything else with "ZpongoZ".
+func filterCSSValue(s string) string {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isASCIILetter(c) && !(c >= '0' && c <= '9') && strings.IndexByte(" #%,.-_", c) < 0 {
+			return escapeFailed
+		}
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "expression") || strings.Contains(lower, "javascript") {
+		return escapeFailed
+	}
+	return s
+}
+
+// escapeCSSStringContent escapes s for a CSS string. ASCII chars other than
+// letters, digits and spaces are replaced by CSS escapes.
+func escapeCSSStringContent(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < utf8.RuneSelf && !isASCIILetter(byte(r)) && !(r >= '0' && r <= '9') && r != ' ' {
+			fmt.Fprintf(&b, `\%X `, r)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escapeUnquotedAttr escapes s for an unquoted attribute value, which ends
+// at whitespace and can't contain quotes, '=', '<', '>' and '`'.
+func escapeUnquotedAttr(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isHTMLSpace(c) || strings.IndexByte("&<>\"'=`", c) >= 0 || c == 0 {
+			fmt.Fprintf(&b, "&#%d;", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
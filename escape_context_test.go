@@ -0,0 +1,148 @@
+package pongo2
+
+import (
+	"strings"
+	"testing"
+)
+
+// shoutNode is a custom tag writing raw output.
+type shoutNode struct{}
+
+func (shoutNode) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
+	_, err := writer.WriteString("javascript:alert(1)")
+	return err
+}
+
+func TestContextualAutoescape(t *testing.T) {
+	set := NewSet("test-contextual-autoescape", &DummyLoader{})
+	set.Options.ContextualAutoescape = true
+
+	ctx := Context{
+		"s":     `a"b'c<d>&e`,
+		"js":    `alert('x')</script>`,
+		"url":   "javascript:alert(1)",
+		"link":  "https://example.com/a b?x=1",
+		"query": "a&b=c d",
+		"color": "red",
+		"evil":  "red;background:url(x)",
+		"num":   42,
+		"list":  []any{1, "two"},
+		"html":  "<b>bold</b>",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		output   string
+	}{
+		{"text", `<p>{{ s }}</p>`, `<p>a&quot;b&#39;c&lt;d&gt;&amp;e</p>`},
+		{"quoted attribute", `<p title="{{ s }}">`, `<p title="a&quot;b&#39;c&lt;d&gt;&amp;e">`},
+		{"single quoted attribute", `<p title='{{ s }}'>`, `<p title='a&quot;b&#39;c&lt;d&gt;&amp;e'>`},
+		{"unquoted attribute", `<p title={{ color }}x{{ s }}>`, `<p title=redxa&#34;b&#39;c&#60;d&#62;&#38;e>`},
+		{"url scheme", `<a href="{{ url }}">`, `<a href="#ZpongoZ">`},
+		{"url", `<a href="{{ link }}">`, `<a href="https://example.com/a%20b?x=1">`},
+		{"url query", `<a href="/search?q={{ query }}">`, `<a href="/search?q=a%26b%3Dc+d">`},
+		{"url path", `<img src="/img/{{ url }}">`, `<img src="/img/javascript:alert%281%29">`},
+		{"event handler", `<a onclick="f({{ s }})">`, `<a onclick="f( &quot;a\&quot;b&#39;c\u003cd\u003e\u0026e&quot; )">`},
+		{"event handler string", `<a onclick="f('{{ s }}')">`, `<a onclick="f('a\u0022b\u0027c\u003Cd\u003E\u0026e')">`},
+		{"script value", `<script>var x = {{ list }};</script>`, `<script>var x =  [1,"two"] ;</script>`},
+		{"script number", `<script>var x = {{ num }};</script>`, `<script>var x =  42 ;</script>`},
+		{"script string", `<script>var x = "{{ js }}";</script>`, `<script>var x = "alert\u0028\u0027x\u0027\u0029\u003C\u002Fscript\u003E";</script>`},
+		{"script value escapes end tag", `<script>var x = {{ js }};</script>`, `<script>var x =  "alert('x')\u003c/script\u003e" ;</script>`},
+		{"after script", `<script>x</script>{{ s }}`, `<script>x</script>a&quot;b&#39;c&lt;d&gt;&amp;e`},
+		{"style", `<p style="color: {{ color }}">`, `<p style="color: red">`},
+		{"style filtered", `<p style="color: {{ evil }}">`, `<p style="color: ZpongoZ">`},
+		{"style string", `<style>p:after { content: "{{ s }}" }</style>`, `<style>p:after { content: "a\22 b\27 c\3C d\3E \26 e" }</style>`},
+		{"textarea", `<textarea>{{ html }}</textarea>`, `<textarea>&lt;b&gt;bold&lt;/b&gt;</textarea>`},
+		{"safe in text", `<p>{{ html|safe }}</p>`, `<p><b>bold</b></p>`},
+		{"safe in script", `<script>{{ html|safe }}</script>`, `<script><b>bold</b></script>`},
+		{"escaped value in script", `<script>var x = {{ html|escape }};</script>`, `<script>var x =  "\u0026lt;b\u0026gt;bold\u0026lt;/b\u0026gt;" ;</script>`},
+		{"comment tag", `<a {% comment %}"{% endcomment %}title="{{ s }}">`, `<a title="a&quot;b&#39;c&lt;d&gt;&amp;e">`},
+		{"autoescape off", `{% autoescape off %}<a onclick="{{ s }}">{% endautoescape %}`, `<a onclick="a"b'c<d>&e">`},
+		{"firstof in text", `<p>{% firstof s %}</p>`, `<p>a&quot;b&#39;c&lt;d&gt;&amp;e</p>`},
+		{"firstof in url", `<a href="{% firstof url %}">`, `<a href="#ZpongoZ">`},
+		{"firstof in attribute", `<p title="{% firstof s %}">`, `<p title="a&quot;b&#39;c&lt;d&gt;&amp;e">`},
+		{"firstof in event handler", `<a onclick="{% firstof js %}">`, `<a onclick=" &quot;alert(&#39;x&#39;)\u003c/script\u003e&quot; ">`},
+		{"firstof in script string", `<script>var a = "{% firstof js %}";</script>`, `<script>var a = "alert\u0028\u0027x\u0027\u0029\u003C\u002Fscript\u003E";</script>`},
+		{"firstof without output", `<a href="{% firstof missing %}">`, `<a href="">`},
+		{"cycle in url", `{% for i in list %}<a href="{% cycle url "b" %}">{% endfor %}`, `<a href="#ZpongoZ"><a href="b">`},
+		{"cycle in class", `{% for i in list %}<p class="{% cycle "odd" "even" %}">{% endfor %}`, `<p class="odd"><p class="even">`},
+		{"templatetag in script", `<script>var a = "{% templatetag openblock %}";</script>`, `<script>var a = "\u007B\u0025";</script>`},
+		{"now in attribute", `<p title="{% now "2006" fake %}">`, `<p title="2014">`},
+		{"widthratio in style", `<p style="width: {% widthratio 50 100 100 %}px">`, `<p style="width: 51px">`},
+		{"if in attribute list", `<p {% if num %}hidden{% endif %}>`, `<p hidden>`},
+		{"if else in url", `<a href="{% if num %}{{ url }}{% else %}/{{ s }}{% endif %}?q={{ query }}">`, `<a href="#ZpongoZ?q=a%26b%3Dc+d">`},
+		{"if elif in script", `<script>var a = {% if num %}{{ num }}{% elif s %}"{{ s }}"{% else %}0{% endif %};</script>`, `<script>var a =  42 ;</script>`},
+		{"branches closing the same tag", `{% if num %}<b>{% else %}<i>{% endif %}{{ s }}`, `<b>a&quot;b&#39;c&lt;d&gt;&amp;e`},
+		{"for in attribute", `<p class="{% for i in list %}c{{ i }} {% empty %}none{% endfor %}">`, `<p class="c1 ctwo ">`},
+		{"ifequal else in script", `<script>var a = {% ifequal num 42 %}"{{ s }}"{% else %}{{ num }}{% endifequal %};</script>`, `<script>var a = "a\u0022b\u0027c\u003Cd\u003E\u0026e";</script>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := set.RenderTemplateString(tt.template, ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.output {
+				t.Errorf("expected %q, got %q", tt.output, result)
+			}
+		})
+	}
+
+	rejected := []struct {
+		name     string
+		template string
+		err      string
+	}{
+		{"tag name", `<{{ s }}>`, "a tag name"},
+		{"attribute name", `<p {{ s }}="x">`, "an attribute name"},
+		{"attribute list", `<p class="a" {{ s }}>`, "an attribute name"},
+		{"html comment", `<!-- {{ s }} -->`, "an HTML comment"},
+		{"js comment", `<script>// {{ s }}</script>`, "a JavaScript or CSS comment"},
+		{"template literal", "<script>var x = `{{ s }}`;</script>", "a JavaScript template literal"},
+		{"include in attribute", `<p title="{% include "x.html" %}">`, "tag 'include' is only allowed in HTML text"},
+		{"block in script", `<script>{% block js %}{% endblock %}</script>`, "tag 'block' is only allowed in HTML text"},
+		{"firstof in attribute name", `<a {% firstof s %}>`, "an attribute name"},
+		{"cycle in tag name", `<{% cycle "a" "b" %}>`, "a tag name"},
+		{"lorem in js comment", `<script>/* {% lorem %} */</script>`, "a JavaScript or CSS comment"},
+		{"custom tag in attribute", `<a href="{% shout %}">`, "tag 'shout' is only allowed in HTML text"},
+		{"if else mismatch", `{% if num %}<script>var a = 1;{% else %}</script>{% endif %}{{ s }}`, "the branches of tag 'if' end in different HTML contexts"},
+		{"if without else", `{% if num %}<script>{% endif %}{{ s }}`, "the branches of tag 'if' end in different HTML contexts"},
+		{"elif mismatch", `<a {% if num %}x{% elif s %}title="{% else %}{% endif %}{{ s }}">`, "the branches of tag 'if' end in different HTML contexts"},
+		{"if opening a js attribute", `<a {% if num %}onclick{% endif %}="{{ s }}">`, "the branches of tag 'if' end in different HTML contexts"},
+		{"for body", `{% for i in list %}<script>{% endfor %}{{ s }}`, "the body of tag 'for' must end in the HTML context it starts in"},
+		{"for empty", `{% for i in list %}{% empty %}<a href="{% endfor %}{{ url }}">`, "the branches of tag 'for' end in different HTML contexts"},
+		{"ifchanged mismatch", `{% ifchanged num %}<style>{% else %}{% endifchanged %}`, "the branches of tag 'ifchanged' end in different HTML contexts"},
+		{"ifequal mismatch", `{% ifequal num 1 %}<p title="{% endifequal %}{{ s }}">`, "the branches of tag 'ifequal' end in different HTML contexts"},
+		{"ifnotequal mismatch", `{% ifnotequal num 1 %}{% else %}<!--{% endifnotequal %}`, "the branches of tag 'ifnotequal' end in different HTML contexts"},
+	}
+
+	if err := set.RegisterTag("shout", func(doc *Parser, start *Token, arguments *Parser) (INodeTag, error) {
+		return shoutNode{}, nil
+	}); err != nil {
+		t.Fatalf("RegisterTag failed: %v", err)
+	}
+
+	for _, tt := range rejected {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			_, err := set.FromString(tt.template)
+			if err == nil {
+				t.Fatal("expected a compile error")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got: %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		result, err := NewSet("test-no-contextual-autoescape", &DummyLoader{}).RenderTemplateString(`<a onclick="f('{{ s }}')">`, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := `<a onclick="f('a&quot;b&#39;c&lt;d&gt;&amp;e')">`; result != expected {
+			t.Errorf("expected %q, got %q", expected, result)
+		}
+	})
+}
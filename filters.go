@@ -197,8 +197,20 @@ func (fc *filterCall) Execute(v *Value, ctx *ExecutionContext) (*Value, error) {
 		filteredValue, err = fc.filterArgsFunc(v, args)
 	}
 	if err != nil {
+		if e, ok := err.(*Error); ok {
+			if e.Code == "" {
+				e.Code = ErrCodeFilter
+			}
+		} else if errorCodeOf(err) == "" {
+			err = withErrorCode(ErrCodeFilter, err)
+		}
 		return nil, updateErrorToken(err, ctx.template, fc.token)
 	}
+	if filteredValue != nil && filteredValue.undefined != "" {
+		// Filters passing an undefined value through don't output the
+		// Options.DebugUndefined placeholder
+		filteredValue = &Value{val: filteredValue.val, safe: filteredValue.safe}
+	}
 	return filteredValue, nil
 }
 
@@ -217,8 +229,8 @@ func (p *Parser) parseFilter() (*filterCall, error) {
 	}
 
 	// Check sandbox filter restriction
-	if _, isBanned := p.template.set.bannedFilters[identToken.Val]; isBanned {
-		return nil, p.Error(fmt.Sprintf("Usage of filter '%s' is not allowed (sandbox restriction active).", identToken.Val), identToken)
+	if !p.template.set.isFilterAllowed(identToken.Val) {
+		return nil, p.Error(fmt.Sprintf("Usage of filter '%s' is not allowed (sandbox restriction active).", identToken.Val), identToken).withCode(ErrCodeSandbox)
 	}
 
 	// Get the appropriate filter function and bind it
@@ -228,7 +240,7 @@ func (p *Parser) parseFilter() (*filterCall, error) {
 	} else {
 		filterArgsFn, exists := p.template.set.filterArgs[identToken.Val]
 		if !exists {
-			return nil, p.Error(fmt.Sprintf("Filter '%s' does not exist.", identToken.Val), identToken)
+			return nil, p.Error(fmt.Sprintf("Filter '%s' does not exist.", identToken.Val), identToken).withCode(ErrCodeUnknownFilter)
 		}
 		filter.filterArgsFunc = filterArgsFn
 	}
@@ -33,6 +33,12 @@ func mustRegisterFilter(name string, fn FilterFunction) {
 	}
 }
 
+func mustRegisterFilterArgs(name string, fn FilterArgsFunction) {
+	if err := registerFilterArgsBuiltin(name, fn); err != nil {
+		panic(err)
+	}
+}
+
 // htmlEscapeReplacer is a pre-compiled replacer for HTML escaping.
 // Using a single Replacer is more efficient than multiple strings.Replace calls
 // because it processes the string in a single pass.
@@ -78,6 +84,10 @@ func init() {
 	mustRegisterFilter("e", filterEscape) // alias of `escape`
 	mustRegisterFilter("safe", filterSafe)
 	mustRegisterFilter("escapejs", filterEscapejs)
+	mustRegisterFilter("escapexml", filterEscapexml)
+	mustRegisterFilter("escapejson", filterEscapejson)
+	mustRegisterFilter("escapelatex", filterEscapelatex)
+	mustRegisterFilter("escapeshell", filterEscapeshell)
 
 	mustRegisterFilter("add", filterAdd)
 	mustRegisterFilter("addslashes", filterAddslashes)
@@ -126,8 +136,8 @@ func init() {
 	mustRegisterFilter("yesno", filterYesno)
 	mustRegisterFilter("timesince", filterTimesince)
 	mustRegisterFilter("timeuntil", filterTimeuntil)
-	mustRegisterFilter("dictsort", filterDictsort)
-	mustRegisterFilter("dictsortreversed", filterDictsortReversed)
+	mustRegisterFilterArgs("dictsort", filterDictsort)
+	mustRegisterFilterArgs("dictsortreversed", filterDictsortReversed)
 	mustRegisterFilter("unordered_list", filterUnorderedList)
 	mustRegisterFilter("slugify", filterSlugify)
 	mustRegisterFilter("filesizeformat", filterFilesizeformat)
@@ -439,6 +449,91 @@ func filterEscape(in *Value, param *Value) (*Value, error) {
 	return checkModified(s, htmlEscapeReplacer.Replace(s), in)
 }
 
+// filterEscapexml escapes a string for XML text and attribute values. It
+// replaces the same characters as escape and removes the characters which are
+// not allowed in XML documents (control characters other than tab, newline
+// and carriage return, U+FFFE and U+FFFF). Invalid UTF-8 is replaced by U+FFFD.
+//
+// Usage:
+//
+//	{{ "<a & b>"|escapexml }}
+//
+// Output: "&lt;a &amp; b&gt;"
+func filterEscapexml(in *Value, param *Value) (*Value, error) {
+	s := in.String()
+	out := strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t', r == '\n', r == '\r':
+			return r
+		case r < 0x20, r == 0xFFFE, r == 0xFFFF:
+			return -1
+		}
+		return r
+	}, s)
+	return checkModified(s, htmlEscapeReplacer.Replace(out), in)
+}
+
+// filterEscapejson escapes a string for use inside a JSON string literal
+// (without adding the surrounding quotes). '<', '>' and '&' are escaped as
+// well, so the output can also be embedded in HTML.
+//
+// Usage:
+//
+//	{"name": "{{ `say "hi"`|escapejson }}"}
+//
+// Output: {"name": "say \"hi\""}
+func filterEscapejson(in *Value, param *Value) (*Value, error) {
+	s := in.String()
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:escapejson",
+			OrigError: err,
+		}
+	}
+	return checkModified(s, string(b[1:len(b)-1]), in)
+}
+
+// latexEscapeReplacer is a pre-compiled replacer for LaTeX special characters.
+var latexEscapeReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"$", `\$`,
+	"&", `\&`,
+	"#", `\#`,
+	"%", `\%`,
+	"_", `\_`,
+	"^", `\textasciicircum{}`,
+	"~", `\textasciitilde{}`,
+)
+
+// filterEscapelatex escapes LaTeX special characters (\ { } $ & # % _ ^ ~).
+//
+// Usage:
+//
+//	{{ "50% of $10"|escapelatex }}
+//
+// Output: "50\% of \$10"
+func filterEscapelatex(in *Value, param *Value) (*Value, error) {
+	s := in.String()
+	return checkModified(s, latexEscapeReplacer.Replace(s), in)
+}
+
+// filterEscapeshell quotes a string as a single argument for POSIX shells
+// by enclosing it in single quotes.
+//
+// Usage:
+//
+//	rm {{ "my file's.txt"|escapeshell }}
+//
+// Output:
+//
+//	rm 'my file'\''s.txt'
+func filterEscapeshell(in *Value, param *Value) (*Value, error) {
+	return AsValue("'" + strings.ReplaceAll(in.String(), "'", `'\''`) + "'"), nil
+}
+
 // filterSafe marks a string as safe, meaning it will not be HTML-escaped when
 // rendered. Use this filter when you know the content is safe and should be
 // rendered as-is (e.g., pre-sanitized HTML content).
@@ -2003,8 +2098,8 @@ func timeDiff(from, to time.Time) string {
 //
 // For a list of maps, this sorts by the value of the specified key.
 // For a list of structs, this sorts by the specified field name.
-func filterDictsort(in *Value, param *Value) (*Value, error) {
-	return dictsortHelper(in, param, false)
+func filterDictsort(in *Value, args *Args) (*Value, error) {
+	return dictsortHelper(args.ctx, in, args.First(), false)
 }
 
 // filterDictsortReversed sorts a list of maps or structs by the specified key in reverse order.
@@ -2012,8 +2107,8 @@ func filterDictsort(in *Value, param *Value) (*Value, error) {
 // Usage:
 //
 //	{{ items|dictsortreversed:"name" }}
-func filterDictsortReversed(in *Value, param *Value) (*Value, error) {
-	return dictsortHelper(in, param, true)
+func filterDictsortReversed(in *Value, args *Args) (*Value, error) {
+	return dictsortHelper(args.ctx, in, args.First(), true)
 }
 
 // dictsortItems implements sort.Interface for sorting by key
@@ -2026,7 +2121,9 @@ func (d dictsortItems) Len() int           { return len(d) }
 func (d dictsortItems) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 func (d dictsortItems) Less(i, j int) bool { return d[i].sortKey < d[j].sortKey }
 
-func dictsortHelper(in *Value, param *Value, reverse bool) (*Value, error) {
+// dictsortHelper sorts in by the item param. Struct fields and map keys denied
+// by the access policy of ctx (if not nil) are treated as missing.
+func dictsortHelper(ctx *ExecutionContext, in *Value, param *Value, reverse bool) (*Value, error) {
 	if !in.CanSlice() {
 		return in, nil
 	}
@@ -2048,7 +2145,7 @@ func dictsortHelper(in *Value, param *Value, reverse bool) (*Value, error) {
 		// Get the sort key value using Value methods
 		sortKeyVal := ""
 		if item.IsMap() || item.IsStruct() {
-			sortVal := item.GetItem(param)
+			sortVal := item.getItem(ctx, param)
 			if !sortVal.IsNil() {
 				sortKeyVal = sortVal.String()
 			}
@@ -474,7 +474,7 @@ func TestFilterDictsort(t *testing.T) {
 				param = AsValue(tt.key)
 			}
 
-			result, err := filterDictsort(AsValue(tt.input), param)
+			result, err := filterDictsort(AsValue(tt.input), NewArgs(nil, nil, param))
 			if tt.wantErr {
 				if err == nil {
 					t.Error("expected error but got none")
@@ -512,7 +512,7 @@ func TestFilterDictsort(t *testing.T) {
 func TestFilterDictsortNonMapInput(t *testing.T) {
 	// Test with integer - not sliceable
 	intInput := 42
-	result, err := filterDictsort(AsValue(intInput), AsValue("name"))
+	result, err := filterDictsort(AsValue(intInput), NewArgs(nil, nil, AsValue("name")))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -522,7 +522,7 @@ func TestFilterDictsortNonMapInput(t *testing.T) {
 	}
 
 	// Test with nil
-	result, err = filterDictsort(AsValue(nil), AsValue("name"))
+	result, err = filterDictsort(AsValue(nil), NewArgs(nil, nil, AsValue("name")))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -562,7 +562,7 @@ func TestFilterDictsortReversed(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := filterDictsortReversed(AsValue(tt.input), AsValue(tt.key))
+			result, err := filterDictsortReversed(AsValue(tt.input), NewArgs(nil, nil, AsValue(tt.key)))
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -603,7 +603,7 @@ func TestFilterDictsortWithStructs(t *testing.T) {
 	}
 
 	t.Run("sort structs by Name field", func(t *testing.T) {
-		result, err := filterDictsort(AsValue(input), AsValue("Name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("Name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -626,7 +626,7 @@ func TestFilterDictsortWithStructs(t *testing.T) {
 	})
 
 	t.Run("sort structs by Age field", func(t *testing.T) {
-		result, err := filterDictsort(AsValue(input), AsValue("Age"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("Age")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -649,7 +649,7 @@ func TestFilterDictsortWithStructs(t *testing.T) {
 	})
 
 	t.Run("sort structs by non-existent field", func(t *testing.T) {
-		result, err := filterDictsort(AsValue(input), AsValue("NonExistent"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("NonExistent")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -674,7 +674,7 @@ func TestFilterDictsortWithPointers(t *testing.T) {
 		{Name: "Bob", Age: 20},
 	}
 
-	result, err := filterDictsort(AsValue(input), AsValue("Name"))
+	result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("Name")))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -1113,7 +1113,7 @@ func TestDictsortItemsSortInterface(t *testing.T) {
 func TestDictsortHelperEdgeCases(t *testing.T) {
 	t.Run("string input (sliceable but not map/struct items)", func(t *testing.T) {
 		// Strings are sliceable, but individual characters are not maps/structs
-		result, err := filterDictsort(AsValue("hello"), AsValue("key"))
+		result, err := filterDictsort(AsValue("hello"), NewArgs(nil, nil, AsValue("key")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1125,7 +1125,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 
 	t.Run("slice of integers (not map/struct)", func(t *testing.T) {
 		input := []int{3, 1, 2}
-		result, err := filterDictsort(AsValue(input), AsValue("key"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("key")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1138,7 +1138,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 
 	t.Run("slice of strings (not map/struct)", func(t *testing.T) {
 		input := []string{"charlie", "alice", "bob"}
-		result, err := filterDictsort(AsValue(input), AsValue("key"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("key")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1154,7 +1154,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 			"not a map",
 			map[string]any{"name": "Bob"},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1170,7 +1170,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 			{"name": "Alice", "value": "string"},
 			{"name": "Bob", "value": true},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1197,7 +1197,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 		m3 := map[string]any{"name": "Bob"}
 		input := []*map[string]any{&m1, &m2, &m3}
 
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1224,7 +1224,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 			{"name": "Same", "id": 2},
 			{"name": "Same", "id": 3},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1245,7 +1245,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 
 	t.Run("bool value", func(t *testing.T) {
 		// Bool is not sliceable, should return unchanged
-		result, err := filterDictsort(AsValue(true), AsValue("key"))
+		result, err := filterDictsort(AsValue(true), NewArgs(nil, nil, AsValue("key")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1265,7 +1265,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 			{Name: "Bob"},
 		}
 
-		result, err := filterDictsort(AsValue(input), AsValue("Name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("Name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1291,7 +1291,7 @@ func TestDictsortHelperEdgeCases(t *testing.T) {
 func TestDictsortReversedEdgeCases(t *testing.T) {
 	t.Run("nil key parameter", func(t *testing.T) {
 		input := []map[string]any{{"name": "Alice"}}
-		_, err := filterDictsortReversed(AsValue(input), AsValue(nil))
+		_, err := filterDictsortReversed(AsValue(input), NewArgs(nil, nil, AsValue(nil)))
 		if err == nil {
 			t.Error("expected error for nil key parameter")
 		}
@@ -1299,7 +1299,7 @@ func TestDictsortReversedEdgeCases(t *testing.T) {
 
 	t.Run("empty slice", func(t *testing.T) {
 		input := []map[string]any{}
-		result, err := filterDictsortReversed(AsValue(input), AsValue("name"))
+		result, err := filterDictsortReversed(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1310,7 +1310,7 @@ func TestDictsortReversedEdgeCases(t *testing.T) {
 
 	t.Run("single element", func(t *testing.T) {
 		input := []map[string]any{{"name": "Only"}}
-		result, err := filterDictsortReversed(AsValue(input), AsValue("name"))
+		result, err := filterDictsortReversed(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1334,7 +1334,7 @@ func TestDictsortReversedEdgeCases(t *testing.T) {
 			{"name": "Bob"},
 			{"name": "Charlie"},
 		}
-		result, err := filterDictsortReversed(AsValue(input), AsValue("name"))
+		result, err := filterDictsortReversed(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1356,7 +1356,7 @@ func TestDictsortReversedEdgeCases(t *testing.T) {
 	})
 
 	t.Run("non-sliceable input", func(t *testing.T) {
-		result, err := filterDictsortReversed(AsValue(42), AsValue("key"))
+		result, err := filterDictsortReversed(AsValue(42), NewArgs(nil, nil, AsValue("key")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1374,7 +1374,7 @@ func TestDictsortWithSpecialCharKeys(t *testing.T) {
 			{"name": "中国"},
 			{"name": "한국"},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1391,7 +1391,7 @@ func TestDictsortWithSpecialCharKeys(t *testing.T) {
 			{"name": "Bob"},
 			{"name": ""},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1416,7 +1416,7 @@ func TestDictsortWithSpecialCharKeys(t *testing.T) {
 			{"name": "a"},
 			{"name": " b"},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1449,7 +1449,7 @@ func TestDictsortWithNestedValues(t *testing.T) {
 			{"name": "Alice", "info": map[string]any{"age": 30}},
 			{"name": "Bob", "info": map[string]any{"age": 20}},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1479,7 +1479,7 @@ func TestDictsortInterfaceSlice(t *testing.T) {
 			map[string]any{"name": "Alice"},
 			map[string]any{"name": "Bob"},
 		}
-		result, err := filterDictsort(AsValue(input), AsValue("name"))
+		result, err := filterDictsort(AsValue(input), NewArgs(nil, nil, AsValue("name")))
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
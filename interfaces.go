@@ -0,0 +1,58 @@
+package pongo2
+
+import "iter"
+
+// The following interfaces let types control how templates see them. They're
+// consulted before pongo2 falls back to reflection, which is useful for
+// types like ORM models whose Go internals don't match what templates should
+// see (e.g. lazily loaded relations).
+
+// PongoStringer is implemented by types that provide their own string form
+// for templates ({{ obj }}, filters working on strings). It takes precedence
+// over fmt.Stringer. The string is escaped by autoescape like any string.
+type PongoStringer interface {
+	PongoString() string
+}
+
+// PongoGetter is implemented by types that resolve attributes dynamically.
+// Get is called for `obj.name` and `obj["name"]`; if it returns false, the
+// attribute is resolved by reflection (fields, methods, map keys) instead.
+// The set's AccessPolicy is not consulted for attributes returned by Get.
+type PongoGetter interface {
+	Get(name string) (any, bool)
+}
+
+// PongoIndexer is implemented by types that support subscripts: Index is
+// called for `obj.0` (with an int) and `obj[key]` (with the evaluated key).
+// If it returns false, the subscript is resolved by reflection instead.
+type PongoIndexer interface {
+	Index(key any) (any, bool)
+}
+
+// PongoIterable is implemented by types that can be looped over with
+// {% for %}. The items are streamed like the ones of an iter.Seq.
+type PongoIterable interface {
+	Iter() iter.Seq[any]
+}
+
+// PongoTruth is implemented by types that decide whether they're true in
+// conditions like {% if obj %} (see Value.IsTrue).
+type PongoTruth interface {
+	Truth() bool
+}
+
+// PongoSafeHTML is implemented by types that render as HTML which is safe
+// to output without escaping. SafeHTML is used instead of the string form
+// and isn't escaped by autoescape.
+type PongoSafeHTML interface {
+	SafeHTML() string
+}
+
+// isSafe reports whether the value must not be escaped by autoescape.
+func (v *Value) isSafe() bool {
+	if v.safe {
+		return true
+	}
+	_, ok := TypeAssert[PongoSafeHTML](v)
+	return ok
+}
@@ -0,0 +1,157 @@
+package pongo2
+
+import (
+	"iter"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// Iterator is a pull-style iterator which can be used as the source of a
+// {% for %} loop (besides iter.Seq and iter.Seq2 functions), e.g. to stream
+// rows of a database cursor without loading all of them into memory:
+//
+//	{% for row in rows %}{{ row.Name }};{{ row.Email }}
+//	{% endfor %}
+//
+// Next returns the next item, or false if the iteration is done. If the
+// iterator also has an `Err() error` method, it's called after the last item
+// and a non-nil error aborts the template execution.
+type Iterator interface {
+	Next() (item any, ok bool)
+}
+
+// sequence returns a push function for v if it's an iterator (an Iterator,
+// a PongoIterable, an iter.Seq or an iter.Seq2), which yields the items as (item, nil) or
+// (key, value) and returns the error reported by the iterator, if any.
+func (v *Value) sequence() (func(yield func(key, value *Value) bool) error, bool) {
+	if !v.val.IsValid() || !v.val.CanInterface() {
+		return nil, false
+	}
+
+	switch it := v.val.Interface().(type) {
+	case Iterator:
+		return func(yield func(key, value *Value) bool) error {
+			for {
+				item, ok := it.Next()
+				if !ok || !yield(AsValue(item), nil) {
+					break
+				}
+			}
+			if e, ok := it.(interface{ Err() error }); ok {
+				return e.Err()
+			}
+			return nil
+		}, true
+	case PongoIterable:
+		return func(yield func(key, value *Value) bool) error {
+			for item := range it.Iter() {
+				if !yield(AsValue(item), nil) {
+					break
+				}
+			}
+			return nil
+		}, true
+	case iter.Seq[any]:
+		return func(yield func(key, value *Value) bool) error {
+			for item := range it {
+				if !yield(AsValue(item), nil) {
+					break
+				}
+			}
+			return nil
+		}, true
+	case iter.Seq2[any, any]:
+		return func(yield func(key, value *Value) bool) error {
+			for key, value := range it {
+				if !yield(AsValue(key), AsValue(value)) {
+					break
+				}
+			}
+			return nil
+		}, true
+	}
+
+	rv := v.getResolvedValue()
+	if rv.Kind() != reflect.Func || rv.IsNil() || !isSequenceFunc(rv.Type()) {
+		return nil, false
+	}
+	yieldType := rv.Type().In(0)
+	return func(yield func(key, value *Value) bool) error {
+		done := false
+		yieldFn := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			// The iterator is supposed to stop after yield returned false,
+			// but don't rely on it.
+			if !done {
+				var value *Value
+				if len(args) == 2 {
+					value = AsValue(args[1].Interface())
+				}
+				done = !yield(AsValue(args[0].Interface()), value)
+			}
+			return []reflect.Value{reflect.ValueOf(!done).Convert(yieldType.Out(0))}
+		})
+		rv.Call([]reflect.Value{yieldFn})
+		return nil
+	}, true
+}
+
+// isSequenceFunc reports whether typ has the signature of an iter.Seq or an
+// iter.Seq2, i.e. func(yield func(V) bool) or func(yield func(K, V) bool).
+func isSequenceFunc(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Func || typ.NumIn() != 1 || typ.NumOut() != 0 || typ.IsVariadic() {
+		return false
+	}
+	yield := typ.In(0)
+	return yield.Kind() == reflect.Func &&
+		(yield.NumIn() == 1 || yield.NumIn() == 2) &&
+		!yield.IsVariadic() &&
+		yield.NumOut() == 1 &&
+		yield.Out(0).Kind() == reflect.Bool
+}
+
+// collectSequence materializes the items of an iterator (see sequence) for
+// Value.IterateOrder. Items are returned as keys (and values for iter.Seq2).
+// An error of the iterator ends the collection.
+func collectSequence(seq func(yield func(key, value *Value) bool) error) (keys, values []*Value) {
+	err := seq(func(key, value *Value) bool {
+		keys = append(keys, key)
+		values = append(values, value)
+		return true
+	})
+	if err != nil {
+		logf("Value.Iterate() stopped by iterator error: %v\n", err)
+	}
+	return keys, values
+}
+
+// iterateSequence implements Value.IterateOrder for iterators by collecting
+// their items first.
+func (v *Value) iterateSequence(seq func(yield func(key, value *Value) bool) error,
+	fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
+	keys, values := collectSequence(seq)
+	count := len(keys)
+	if count == 0 {
+		empty()
+		return
+	}
+
+	order := make([]int, count)
+	for i := range order {
+		order[i] = i
+	}
+	if sorted {
+		sort.SliceStable(order, func(a, b int) bool {
+			return valuesList(keys).Less(order[a], order[b])
+		})
+	}
+	if reverse {
+		slices.Reverse(order)
+	}
+
+	for idx, i := range order {
+		if !fn(idx, count, keys[i], values[i]) {
+			return
+		}
+	}
+}
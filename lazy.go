@@ -0,0 +1,87 @@
+package pongo2
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// LazyValue is a context value which is computed on first access, see Lazy.
+type LazyValue struct {
+	fn func() (any, error)
+}
+
+// Lazy wraps fn into a context value which is evaluated when a template
+// accesses it for the first time. The result (or error) is memoized for the
+// rest of the render, including all included templates, macros and blocks,
+// so fn is called at most once per render no matter how often the value is
+// referenced:
+//
+//	ctx := pongo2.Context{
+//		"orders": pongo2.Lazy(func() (any, error) { return db.LoadOrders(userID) }),
+//	}
+//
+//	{% if orders %}{{ orders|length }} orders{% endif %}
+//
+// If the same context is used for several renders, fn is called (at most)
+// once per render. fn must be safe for concurrent use if it's referenced by
+// parallel includes; concurrent accesses wait for the first call to finish.
+// If fn returns an error, every access to the value fails with it.
+func Lazy(fn func() (any, error)) *LazyValue {
+	return &LazyValue{fn: fn}
+}
+
+// lazyCache holds the memoized results of the lazy values accessed during
+// one render. It's shared by all execution contexts of the render.
+type lazyCache struct {
+	mu      sync.Mutex
+	results map[*LazyValue]*lazyResult
+}
+
+type lazyResult struct {
+	once sync.Once
+	val  any
+	err  error
+}
+
+// get returns the (memoized) result of lv.
+func (c *lazyCache) get(lv *LazyValue) (any, error) {
+	c.mu.Lock()
+	if c.results == nil {
+		c.results = make(map[*LazyValue]*lazyResult)
+	}
+	res, ok := c.results[lv]
+	if !ok {
+		res = &lazyResult{}
+		c.results[lv] = res
+	}
+	c.mu.Unlock()
+
+	res.once.Do(func() {
+		res.val, res.err = lv.fn()
+	})
+	return res.val, res.err
+}
+
+// resolveLazy evaluates current if it's a *LazyValue. *Value and interface
+// results are unpacked (a lazy value may return another lazy value).
+func (vr *variableResolver) resolveLazy(ctx *ExecutionContext, current reflect.Value, isSafe bool) (reflect.Value, bool, error) {
+	for current.IsValid() {
+		lv, ok := reflect.TypeAssert[*LazyValue](current)
+		if !ok {
+			break
+		}
+		if lv == nil || lv.fn == nil {
+			return reflect.Value{}, isSafe, nil
+		}
+		val, err := ctx.lazies.get(lv)
+		if err != nil {
+			return reflect.Value{}, isSafe, fmt.Errorf("lazy value '%s': %w", vr.String(), err)
+		}
+		current, isSafe = vr.unpackValue(reflect.ValueOf(val), isSafe)
+		if current.Kind() == reflect.Interface {
+			current = reflect.ValueOf(current.Interface())
+		}
+	}
+	return current, isSafe, nil
+}
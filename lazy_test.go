@@ -0,0 +1,95 @@
+package pongo2
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	templates := map[string]string{
+		"page.html": `{{ orders|length }}|{% include "orders.html" %}|{% include "orders.html" parallel %}|` +
+			`{% macro count() %}{{ orders|length }}{% endmacro %}{{ count() }}|{% for o in orders %}{{ o }}{% endfor %}`,
+		"orders.html": `{% if orders %}{{ orders.0 }}{% endif %}`,
+		"user.html":   `{{ user.name }} {{ user.name|upper }}`,
+		"error.html":  `a{{ broken }}b`,
+	}
+	set := NewSet("test-lazy", NewMapLoader(templates))
+
+	t.Run("evaluated once per render", func(t *testing.T) {
+		var calls atomic.Int32
+		ctx := Context{
+			"orders": Lazy(func() (any, error) {
+				calls.Add(1)
+				return []string{"x", "y"}, nil
+			}),
+		}
+
+		for i := 1; i <= 2; i++ {
+			result, err := set.RenderTemplateFile("page.html", ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != "2|x|x|2|xy" {
+				t.Errorf("got %q", result)
+			}
+			if calls.Load() != int32(i) {
+				t.Errorf("after %d renders, the loader was called %d times", i, calls.Load())
+			}
+		}
+	})
+
+	t.Run("nested access", func(t *testing.T) {
+		ctx := Context{
+			"user": Lazy(func() (any, error) {
+				return Lazy(func() (any, error) {
+					return map[string]string{"name": "ann"}, nil
+				}), nil
+			}),
+		}
+		result, err := set.RenderTemplateFile("user.html", ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "ann ANN" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("not accessed", func(t *testing.T) {
+		ctx := Context{
+			"orders": Lazy(func() (any, error) {
+				t.Error("lazy value must not be evaluated")
+				return nil, nil
+			}),
+		}
+		if _, err := set.RenderTemplateFile("user.html", ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		errLoad := errors.New("load failed")
+		ctx := Context{
+			"broken": Lazy(func() (any, error) { return nil, errLoad }),
+		}
+		_, err := set.RenderTemplateFile("error.html", ctx)
+		if !errors.Is(err, errLoad) {
+			t.Fatalf("expected errLoad, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "lazy value 'broken'") {
+			t.Errorf("expected the variable name in the error, got %v", err)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		result, err := set.RenderTemplateFile("error.html", Context{"broken": (*LazyValue)(nil)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "ab" {
+			t.Errorf("got %q", result)
+		}
+	})
+}
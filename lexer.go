@@ -144,6 +144,15 @@ type Token struct {
 	// Col is the 1-based column number where this token starts.
 	Col int
 
+	// Offset is the byte offset where this token starts.
+	Offset int
+
+	// EndOffset, EndLine and EndCol are the position right after the token
+	// (for strings: after the closing quote).
+	EndOffset int
+	EndLine   int
+	EndCol    int
+
 	// TrimWhitespaces is true for whitespace-trimming delimiters ({{-, -}}, {%-, -%}).
 	// When true, adjacent whitespace in HTML content should be stripped.
 	TrimWhitespaces bool
@@ -267,7 +276,9 @@ func lex(name string, input string) ([]*Token, error) {
 			Filename:  name,
 			Line:      errtoken.Line,
 			Column:    errtoken.Col,
+			Token:     errtoken,
 			Sender:    "lexer",
+			Code:      ErrCodeSyntax,
 			OrigError: errors.New(errtoken.Val),
 		}
 	}
@@ -295,16 +306,25 @@ func (l *lexer) length() int {
 //     TrimWhitespaces set to true and the "-" is removed from Val
 func (l *lexer) emit(t TokenType) {
 	tok := &Token{
-		Filename: l.name,
-		Typ:      t,
-		Val:      l.value(),
-		Line:     l.startline,
-		Col:      l.startcol,
+		Filename:  l.name,
+		Typ:       t,
+		Val:       l.value(),
+		Line:      l.startline,
+		Col:       l.startcol,
+		Offset:    l.start,
+		EndOffset: l.pos,
+		EndLine:   l.line,
+		EndCol:    l.col,
 	}
 
 	if t == TokenString {
 		// Escape sequences in strings
 		tok.Val = stringEscapeReplacer.Replace(tok.Val)
+
+		// Include the quotation marks in the range
+		tok.Offset--
+		tok.EndOffset++
+		tok.EndCol++
 	}
 
 	if t == TokenSymbol && len(tok.Val) == 3 && (strings.HasSuffix(tok.Val, "-") || strings.HasPrefix(tok.Val, "-")) {
@@ -385,11 +405,15 @@ func (l *lexer) acceptRun(what string) {
 // Always returns nil to signal that lexing should stop.
 func (l *lexer) errorf(format string, args ...any) lexerStateFn {
 	t := &Token{
-		Filename: l.name,
-		Typ:      TokenError,
-		Val:      fmt.Sprintf(format, args...),
-		Line:     l.startline,
-		Col:      l.startcol,
+		Filename:  l.name,
+		Typ:       TokenError,
+		Val:       fmt.Sprintf(format, args...),
+		Line:      l.startline,
+		Col:       l.startcol,
+		Offset:    l.start,
+		EndOffset: l.pos,
+		EndLine:   l.line,
+		EndCol:    l.col,
 	}
 	l.tokens = append(l.tokens, t)
 	l.errored = true
@@ -0,0 +1,291 @@
+// Package lint checks pongo2 templates for common mistakes: unused
+// variables, unsafe output, deprecated tags, blocks a parent template doesn't
+// define, templates that can't be loaded and misspelled filter names.
+//
+// A Linter compiles templates with its TemplateSet (so the set's tags,
+// filters, tests and loaders apply) and runs its rules on them:
+//
+//	linter := lint.New(set)
+//	findings, err := linter.LintTemplates("")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, f := range findings {
+//		fmt.Println(f)
+//	}
+//
+// Compile errors are findings as well. A template is compiled up to its first
+// error, unless the set's Options.CollectErrors is enabled.
+package lint
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/flosch/pongo2/v7"
+)
+
+// Severity is the severity of a Finding.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity as its name (for JSON output).
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding is a problem found in a template.
+type Finding struct {
+	// Rule is the name of the rule which reported the finding (or "compile"
+	// for compile errors no rule reported).
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+
+	// Position of the problem; Line and Column are 0 if unknown.
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+
+	// Suggestions are possible replacements, e.g. the names of the filters
+	// similar to a misspelled one.
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// String formats the finding like a compiler message:
+//
+//	page.html:3:7: warning: tag 'ifequal' is deprecated, use {% if a == b %} (deprecated-tag)
+func (f Finding) String() string {
+	msg := f.Message
+	if len(f.Suggestions) > 0 {
+		msg += fmt.Sprintf(" (did you mean %s?)", quoteList(f.Suggestions))
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", f.Filename, f.Line, f.Column, f.Severity, msg, f.Rule)
+}
+
+// quoteList formats names as 'a', 'b' or 'c'.
+func quoteList(names []string) string {
+	s := ""
+	for i, name := range names {
+		switch {
+		case i == 0:
+		case i == len(names)-1:
+			s += " or "
+		default:
+			s += ", "
+		}
+		s += "'" + name + "'"
+	}
+	return s
+}
+
+// A Rule checks a template. Rules report their findings through the Pass.
+type Rule interface {
+	// Name returns the rule's name, like "unused-set".
+	Name() string
+
+	// Check checks the template of the pass.
+	Check(pass *Pass)
+}
+
+// Linter checks templates of a TemplateSet.
+type Linter struct {
+	// Set is the set templates are compiled with.
+	Set *pongo2.TemplateSet
+
+	// Rules are the rules to run (DefaultRules by New).
+	Rules []Rule
+
+	// Severities override the severities of rules by rule name.
+	Severities map[string]Severity
+}
+
+// New returns a Linter for templates of set running the DefaultRules.
+func New(set *pongo2.TemplateSet) *Linter {
+	return &Linter{Set: set, Rules: DefaultRules()}
+}
+
+// DefaultRules returns a new instance of every rule of this package (with
+// their default configuration).
+func DefaultRules() []Rule {
+	return []Rule{
+		&UnusedSet{},
+		&SafeUserInput{},
+		&DeprecatedTags{},
+		&UnknownBlock{},
+		&UnresolvedTemplate{},
+		&UnknownFilter{},
+	}
+}
+
+// LintFile compiles and checks the template named filename.
+func (l *Linter) LintFile(filename string) []Finding {
+	tpl, err := l.Set.FromFile(filename)
+	return l.lint(filename, tpl, err)
+}
+
+// LintString compiles and checks the template source src; name is used as
+// the filename of findings without a position.
+func (l *Linter) LintString(name, src string) []Finding {
+	tpl, err := l.Set.FromString(src)
+	return l.lint(name, tpl, err)
+}
+
+// LintTemplate checks a compiled template.
+func (l *Linter) LintTemplate(tpl *pongo2.Template) []Finding {
+	return l.lint(tpl.Name(), tpl, nil)
+}
+
+// LintTemplates checks all templates of the set's loaders whose names start
+// with prefix (see TemplateSet.ListTemplates).
+func (l *Linter) LintTemplates(prefix string) ([]Finding, error) {
+	names, err := l.Set.ListTemplates(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, name := range names {
+		findings = append(findings, l.LintFile(name)...)
+	}
+	return findings, nil
+}
+
+// lint runs the rules on a template (or its compile error).
+func (l *Linter) lint(filename string, tpl *pongo2.Template, err error) []Finding {
+	pass := &Pass{Set: l.Set, Filename: filename, Template: tpl}
+	if tpl != nil {
+		pass.AST = tpl.AST()
+		pass.Analysis = tpl.Analyze()
+	}
+	if err != nil {
+		pass.Errors = compileErrors(err)
+		pass.reported = make([]bool, len(pass.Errors))
+	}
+
+	for _, rule := range l.Rules {
+		pass.rule = rule
+		rule.Check(pass)
+	}
+
+	for i, e := range pass.Errors {
+		if !pass.reported[i] {
+			msg := e.Error()
+			if e.OrigError != nil {
+				msg = e.OrigError.Error()
+			}
+			pass.rule = nil
+			pass.ReportError(e, SeverityError, msg)
+		}
+	}
+
+	findings := pass.findings
+	for i := range findings {
+		if s, ok := l.Severities[findings[i].Rule]; ok {
+			findings[i].Severity = s
+		}
+	}
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(cmp.Compare(a.Filename, b.Filename), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return findings
+}
+
+// compileErrors returns the *pongo2.Errors of a compile error.
+func compileErrors(err error) []*pongo2.Error {
+	var list *pongo2.ErrorList
+	if errors.As(err, &list) {
+		return list.Errors
+	}
+	var e *pongo2.Error
+	if errors.As(err, &e) {
+		return []*pongo2.Error{e}
+	}
+	return []*pongo2.Error{{Sender: "lint", OrigError: err}}
+}
+
+// Pass is a template being checked by a rule.
+type Pass struct {
+	// Set is the set the template has been compiled with.
+	Set *pongo2.TemplateSet
+
+	// Filename is the name of the template.
+	Filename string
+
+	// Template is the compiled template, its syntax tree and its analysis.
+	// They're nil if the template couldn't be compiled.
+	Template *pongo2.Template
+	AST      *pongo2.Node
+	Analysis *pongo2.TemplateAnalysis
+
+	// Errors are the compile errors of the template. Errors no rule reports
+	// (through ReportError) are reported as "compile" findings.
+	Errors []*pongo2.Error
+
+	rule     Rule
+	reported []bool
+	findings []Finding
+}
+
+// Report reports a finding of the current rule at a position of the
+// template.
+func (p *Pass) Report(severity Severity, line, column int, msg string, suggestions ...string) {
+	p.report(Finding{
+		Severity:    severity,
+		Message:     msg,
+		Filename:    p.Filename,
+		Line:        line,
+		Column:      column,
+		Suggestions: suggestions,
+	})
+}
+
+// ReportError reports a finding of the current rule for a compile error
+// (one of Errors), at the position of the error.
+func (p *Pass) ReportError(err *pongo2.Error, severity Severity, msg string, suggestions ...string) {
+	if i := slices.Index(p.Errors, err); i >= 0 {
+		p.reported[i] = true
+	}
+	f := Finding{
+		Severity:    severity,
+		Message:     msg,
+		Filename:    err.Filename,
+		Line:        err.Line,
+		Column:      err.Column,
+		Suggestions: suggestions,
+	}
+	if err.Token != nil && err.Token.Filename != "" {
+		// The error's filename may be the template that couldn't be loaded
+		f.Filename = err.Token.Filename
+	}
+	if f.Filename == "" || f.Filename == "<string>" {
+		f.Filename = p.Filename
+	}
+	p.report(f)
+}
+
+func (p *Pass) report(f Finding) {
+	f.Rule = "compile"
+	if p.rule != nil {
+		f.Rule = p.rule.Name()
+	}
+	p.findings = append(p.findings, f)
+}
@@ -0,0 +1,171 @@
+package lint
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/flosch/pongo2/v7"
+)
+
+func newTestSet() *pongo2.TemplateSet {
+	return pongo2.NewSet("lint", pongo2.NewMapLoader(map[string]string{
+		"base.html": `{% block content %}{% endblock %}{% block footer %}{% endblock %}`,
+		"child.html": `{% extends "base.html" %}{% block content %}{% set unused = 1 %}{% set used = 2 %}{{ used }}` +
+			`{% block inner %}{% endblock %}{% endblock %}{% block sidebar %}{% endblock %}`,
+		"page.html": `{% ifequal a b %}x{% endifequal %}{{ request.comment|safe }}{{ request.comment|escape|safe }}` +
+			`{{ title|safe }}{% filter lowr %}x{% endfilter %}`,
+		"typo.html":      `{{ name|uper }}`,
+		"missing.html":   `{% include "nope.html" %}`,
+		"included.html":  `{% set x = 1 %}{% include "uses_x.html" %}`,
+		"uses_x.html":    `{{ x }}`,
+		"dynamic.html":   `{% set y = 1 %}{% include name %}`,
+		"syntax.html":    `{{ a }`,
+		"deprecated.txt": `{% ifnotequal a b %}{% endifnotequal %}`,
+	}))
+}
+
+func findingStrings(findings []Finding) []string {
+	var s []string
+	for _, f := range findings {
+		s = append(s, f.String())
+	}
+	return s
+}
+
+func TestLintFile(t *testing.T) {
+	linter := New(newTestSet())
+	linter.Rules[1] = &SafeUserInput{UserInput: []string{"request"}}
+
+	tests := []struct {
+		filename string
+		want     []string
+	}{
+		{"base.html", nil},
+		{"child.html", []string{
+			"child.html:1:48: warning: variable 'unused' is set but never used (unused-set)",
+			"child.html:1:141: warning: block 'sidebar' is not defined by the parent template 'base.html' and is never rendered (unknown-block)",
+		}},
+		{"page.html", []string{
+			"page.html:1:4: warning: tag 'ifequal' is deprecated, use {% if a == b %} instead (deprecated-tag)",
+			"page.html:1:54: error: filter 'safe' applied to user input 'request.comment' (safe-user-input)",
+			"page.html:1:120: error: filter 'lowr' does not exist (did you mean 'lower'?) (unknown-filter)",
+		}},
+		{"typo.html", []string{
+			"typo.html:1:9: error: filter 'uper' does not exist (did you mean 'upper'?) (unknown-filter)",
+		}},
+		{"missing.html", []string{
+			"missing.html:1:12: error: template 'nope.html' can't be loaded (unresolved-template)",
+		}},
+		{"included.html", nil},
+		{"dynamic.html", nil},
+		{"syntax.html", []string{
+			"syntax.html:1:6: error: '}}' expected (compile)",
+		}},
+		{"nonexistent.html", []string{
+			"nonexistent.html:0:0: error: template 'nonexistent.html' can't be loaded (unresolved-template)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := findingStrings(linter.LintFile(tt.filename)); !slices.Equal(got, tt.want) {
+				t.Errorf("LintFile() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintConfiguration(t *testing.T) {
+	set := newTestSet()
+
+	linter := &Linter{
+		Set:        set,
+		Rules:      []Rule{&DeprecatedTags{Tags: map[string]string{"ifnotequal": ""}}},
+		Severities: map[string]Severity{"deprecated-tag": SeverityError},
+	}
+	findings, err := linter.LintTemplates("deprecated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"deprecated.txt:1:4: error: tag 'ifnotequal' is deprecated (deprecated-tag)"}
+	if got := findingStrings(findings); !slices.Equal(got, want) {
+		t.Errorf("LintTemplates() = %q, want %q", got, want)
+	}
+
+	b, err := json.Marshal(findings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"rule":"deprecated-tag","severity":"error","message":"tag 'ifnotequal' is deprecated","filename":"deprecated.txt","line":1,"column":4}`; string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	// Without rules, compile errors are findings anyway
+	linter = &Linter{Set: set}
+	want = []string{"typo.html:1:9: error: Filter 'uper' does not exist. (compile)"}
+	if got := findingStrings(linter.LintFile("typo.html")); !slices.Equal(got, want) {
+		t.Errorf("LintFile() = %q, want %q", got, want)
+	}
+
+	// All compile errors with CollectErrors
+	set.Options.CollectErrors = true
+	linter = New(set)
+	want = []string{
+		"<string>:1:6: error: filter 'uper' does not exist (did you mean 'upper'?) (unknown-filter)",
+		"<string>:1:18: error: filter 'lowwer' does not exist (did you mean 'lower'?) (unknown-filter)",
+	}
+	if got := findingStrings(linter.LintString("<string>", `{{ a|uper }}{{ b|lowwer }}`)); !slices.Equal(got, want) {
+		t.Errorf("LintString() = %q, want %q", got, want)
+	}
+}
+
+func TestUnusedSet(t *testing.T) {
+	linter := &Linter{Set: newTestSet(), Rules: []Rule{&UnusedSet{}}}
+
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`{% set x = 1 %}{{ x }}`, nil},
+		{`{% set x = x|add:1 %}`, []string{"<string>:1:4: warning: variable 'x' is set but never used (unused-set)"}},
+		{`{{ x }}{% set x = 1 %}`, []string{"<string>:1:11: warning: variable 'x' is set but never used (unused-set)"}},
+		{`{% set x = 1 %}{% set x = x|add:1 %}{{ x }}`, nil},
+		{`{% if a %}{% set x = 1 %}{% else %}{% set x = 2 %}{% endif %}{{ x }}`, nil},
+		{`{% for i in items %}{% set x = i %}{% endfor %}{{ x }}`, []string{"<string>:1:24: warning: variable 'x' is set but never used (unused-set)"}},
+		{`{% for i in items %}{{ x }}{% set x = i %}{% endfor %}`, nil},
+		{`{% set x = 1 %}{% for x in items %}{{ x }}{% endfor %}`, []string{"<string>:1:4: warning: variable 'x' is set but never used (unused-set)"}},
+		{`{% with y=x %}{% set x = 2 %}{% endwith %}{% set x = 1 %}`, []string{
+			"<string>:1:18: warning: variable 'x' is set but never used (unused-set)",
+			"<string>:1:46: warning: variable 'x' is set but never used (unused-set)",
+		}},
+		{`{% macro m() %}{{ x }}{% endmacro %}{% set x = 1 %}{{ m() }}`, nil},
+		{`{% macro m() %}{% set y = y|add:1 %}{% endmacro %}{{ m() }}`, []string{"<string>:1:19: warning: variable 'y' is set but never used (unused-set)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if got := findingStrings(linter.LintString("<string>", tt.src)); !slices.Equal(got, tt.want) {
+				t.Errorf("LintString() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"upper", "upper", 0},
+		{"uper", "upper", 1},
+		{"lowr", "lower", 1},
+		{"date", "data", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
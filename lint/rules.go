@@ -0,0 +1,448 @@
+package lint
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/flosch/pongo2/v7"
+)
+
+// UnusedSet reports {% set %} variables which are never read after they're
+// set, neither by the template nor by the templates it extends, includes or
+// imports. Templates including templates with a dynamic filename aren't
+// checked.
+type UnusedSet struct{}
+
+func (r *UnusedSet) Name() string { return "unused-set" }
+
+func (r *UnusedSet) Check(pass *Pass) {
+	if pass.AST == nil {
+		return
+	}
+
+	w := &setWalker{read: make(map[string]bool)}
+	if !dependencyReads(pass.Analysis, w.read, make(map[*pongo2.Template]bool)) {
+		return
+	}
+	w.scoped(&setScope{}, pass.AST.Children)
+
+	for _, v := range w.vars {
+		if !v.used && (v.inMacro || !w.read[v.node.Idents[0]]) {
+			pass.Report(SeverityWarning, v.node.Line, v.node.Column, fmt.Sprintf("variable '%s' is set but never used", v.node.Idents[0]))
+		}
+	}
+}
+
+// setVar is a {% set %} variable.
+type setVar struct {
+	node    *pongo2.Node
+	inMacro bool
+	used    bool
+}
+
+// setScope is a scope of an execution context, like the body of a loop.
+type setScope struct {
+	// vars are the variables set in the scope by name.
+	vars map[string][]*setVar
+
+	// bound are the names set by the tag opening the scope, like the loop
+	// variables of {% for %}. They hide the variables of outer scopes.
+	bound map[string]bool
+
+	// loop scopes are executed repeatedly: reads of an iteration see the
+	// variables set by the previous one. reads are the names read in it.
+	loop  bool
+	reads map[string]bool
+
+	// macro scopes are executed when the macro is called, which may be after
+	// variables of outer scopes are set.
+	macro bool
+}
+
+// setWalker walks a syntax tree in execution order and marks the {% set %}
+// variables which are read after they're set.
+type setWalker struct {
+	scopes []*setScope
+	vars   []*setVar
+
+	// read are the names read at any time: by dependencies and by macros
+	// (outside of the macro).
+	read map[string]bool
+}
+
+// scoped walks nodes in a new scope.
+func (w *setWalker) scoped(scope *setScope, nodes []*pongo2.Node) {
+	scope.vars = make(map[string][]*setVar)
+	scope.reads = make(map[string]bool)
+	w.scopes = append(w.scopes, scope)
+	w.nodes(nodes)
+	w.scopes = w.scopes[:len(w.scopes)-1]
+
+	if scope.loop {
+		for name := range scope.reads {
+			for _, v := range scope.vars[name] {
+				v.used = true
+			}
+		}
+	}
+}
+
+func (w *setWalker) nodes(nodes []*pongo2.Node) {
+	for _, n := range nodes {
+		w.node(n)
+	}
+}
+
+func (w *setWalker) node(n *pongo2.Node) {
+	for _, e := range n.Expressions() {
+		pongo2.InspectExpr(e, func(e *pongo2.Expr) bool {
+			if e != nil && e.Kind == pongo2.ExprVariable {
+				w.readVar(e.Parts[0].Name)
+			}
+			return true
+		})
+	}
+	if n.Kind != pongo2.NodeTag {
+		return
+	}
+
+	switch n.Name {
+	case "set":
+		if len(n.Idents) > 0 {
+			w.set(n)
+		}
+	case "for":
+		w.scoped(&setScope{bound: boundNames(slices.Concat(n.Idents, []string{"forloop"})...), loop: true}, n.Children[0].Children)
+		if len(n.Children) > 1 {
+			w.scoped(&setScope{}, n.Children[1].Children)
+		}
+	case "with":
+		w.scoped(&setScope{bound: boundNames(n.Idents...)}, bodyNodes(n))
+	case "macro":
+		w.scoped(&setScope{bound: boundNames(n.Idents[1:]...), macro: true}, bodyNodes(n))
+	default:
+		w.nodes(bodyNodes(n))
+	}
+}
+
+// set adds the variable set by n to the innermost scope.
+func (w *setWalker) set(n *pongo2.Node) {
+	v := &setVar{node: n}
+	for _, scope := range w.scopes {
+		v.inMacro = v.inMacro || scope.macro
+	}
+	w.vars = append(w.vars, v)
+	scope := w.scopes[len(w.scopes)-1]
+	scope.vars[n.Idents[0]] = append(scope.vars[n.Idents[0]], v)
+}
+
+// readVar marks the variables named name which are visible at the current
+// position as used.
+func (w *setWalker) readVar(name string) {
+	for i := len(w.scopes) - 1; i >= 0; i-- {
+		scope := w.scopes[i]
+		for _, v := range scope.vars[name] {
+			v.used = true
+		}
+		if scope.loop {
+			scope.reads[name] = true
+		}
+		if scope.bound[name] {
+			return
+		}
+		if scope.macro {
+			w.read[name] = true
+			return
+		}
+	}
+}
+
+func boundNames(names ...string) map[string]bool {
+	bound := make(map[string]bool, len(names))
+	for _, name := range names {
+		bound[name] = true
+	}
+	return bound
+}
+
+// bodyNodes returns the nodes of all bodies of a tag.
+func bodyNodes(n *pongo2.Node) []*pongo2.Node {
+	var nodes []*pongo2.Node
+	for _, body := range n.Children {
+		nodes = append(nodes, body.Children...)
+	}
+	return nodes
+}
+
+// dependencyReads adds the variables read by the templates a template
+// depends on to read. It returns false if a dependency is dynamic.
+func dependencyReads(a *pongo2.TemplateAnalysis, read map[string]bool, visited map[*pongo2.Template]bool) bool {
+	for _, dep := range a.Dependencies {
+		if dep.Dynamic {
+			return false
+		}
+		if dep.Kind == "ssi" || dep.Template == nil || visited[dep.Template] {
+			continue
+		}
+		visited[dep.Template] = true
+		analysis := dep.Template.Analyze()
+		for _, name := range analysis.VariableNames() {
+			read[name] = true
+		}
+		if !dependencyReads(analysis, read, visited) {
+			return false
+		}
+	}
+	return true
+}
+
+// SafeUserInput reports the safe (and safeseq) filter applied to user input,
+// which disables the escaping of it. Filters escaping the value before (like
+// escape) make it safe.
+type SafeUserInput struct {
+	// UserInput are the paths of the variables holding user input, like
+	// "request" or "comment.body". A path matches the variable itself and
+	// its attributes; "*" matches any name (like "*.comment"). The rule
+	// reports nothing without paths.
+	UserInput []string
+}
+
+func (r *SafeUserInput) Name() string { return "safe-user-input" }
+
+func (r *SafeUserInput) Check(pass *Pass) {
+	if pass.AST == nil || len(r.UserInput) == 0 {
+		return
+	}
+	inspectExprs(pass.AST, func(e *pongo2.Expr) {
+		if e.Kind != pongo2.ExprFilter {
+			return
+		}
+		for _, fc := range e.Filters {
+			switch fc.Name {
+			case "escape", "force_escape", "escapejs":
+				return
+			case "safe", "safeseq":
+				if path, ok := r.userInput(e.Operands[0]); ok {
+					line, column := fc.Line, fc.Column
+					if line == 0 {
+						line, column = e.Line, e.Column
+					}
+					pass.Report(SeverityError, line, column, fmt.Sprintf("filter '%s' applied to user input '%s'", fc.Name, path))
+				}
+				return
+			}
+		}
+	})
+}
+
+// userInput returns the path of a variable in e holding user input.
+func (r *SafeUserInput) userInput(e *pongo2.Expr) (string, bool) {
+	var found string
+	pongo2.InspectExpr(e, func(e *pongo2.Expr) bool {
+		if found != "" || e == nil {
+			return false
+		}
+		if e.Kind == pongo2.ExprVariable {
+			path := e.Path()
+			for _, pattern := range r.UserInput {
+				if matchPath(pattern, path) {
+					found = path
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return found, found != ""
+}
+
+// matchPath reports whether path is pattern or an attribute of it.
+func matchPath(pattern, path string) bool {
+	patternParts, pathParts := strings.Split(pattern, "."), strings.Split(path, ".")
+	if len(pathParts) < len(patternParts) {
+		return false
+	}
+	for i, p := range patternParts {
+		if p != "*" && p != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DeprecatedTags reports deprecated tags.
+type DeprecatedTags struct {
+	// Tags maps the names of the deprecated tags to their replacement (which
+	// may be empty). Without tags, ifequal and ifnotequal are reported.
+	Tags map[string]string
+}
+
+var defaultDeprecatedTags = map[string]string{
+	"ifequal":    "{% if a == b %}",
+	"ifnotequal": "{% if a != b %}",
+}
+
+func (r *DeprecatedTags) Name() string { return "deprecated-tag" }
+
+func (r *DeprecatedTags) Check(pass *Pass) {
+	if pass.AST == nil {
+		return
+	}
+	tags := r.Tags
+	if tags == nil {
+		tags = defaultDeprecatedTags
+	}
+	pongo2.Inspect(pass.AST, func(n *pongo2.Node) bool {
+		if n == nil || n.Kind != pongo2.NodeTag {
+			return true
+		}
+		if replacement, deprecated := tags[n.Name]; deprecated {
+			msg := fmt.Sprintf("tag '%s' is deprecated", n.Name)
+			if replacement != "" {
+				msg += ", use " + replacement + " instead"
+			}
+			pass.Report(SeverityWarning, n.Line, n.Column, msg)
+		}
+		return true
+	})
+}
+
+// UnknownBlock reports blocks of a child template which aren't defined by
+// its parent templates. They're never rendered. Blocks nested in another
+// block aren't reported, they're rendered with the outer block.
+type UnknownBlock struct{}
+
+func (r *UnknownBlock) Name() string { return "unknown-block" }
+
+func (r *UnknownBlock) Check(pass *Pass) {
+	if pass.Analysis == nil {
+		return
+	}
+	idx := slices.IndexFunc(pass.Analysis.Dependencies, func(dep pongo2.TemplateDependency) bool {
+		return dep.Kind == "extends"
+	})
+	if idx < 0 {
+		return
+	}
+	parent := pass.Analysis.Dependencies[idx].Name
+
+	pongo2.Inspect(pass.AST, func(n *pongo2.Node) bool {
+		if n == nil || n.Kind != pongo2.NodeTag || n.Name != "block" {
+			return true
+		}
+		if name := n.Idents[0]; !slices.Contains(pass.Analysis.OverriddenBlocks, name) {
+			pass.Report(SeverityWarning, n.Line, n.Column, fmt.Sprintf("block '%s' is not defined by the parent template '%s' and is never rendered", name, parent))
+		}
+		return false
+	})
+}
+
+// UnresolvedTemplate reports include, extends, import and ssi targets which
+// can't be loaded by the set's loaders (except includes with if_exists).
+type UnresolvedTemplate struct{}
+
+func (r *UnresolvedTemplate) Name() string { return "unresolved-template" }
+
+func (r *UnresolvedTemplate) Check(pass *Pass) {
+	for _, e := range pass.Errors {
+		if e.Code == pongo2.ErrCodeTemplateNotFound {
+			pass.ReportError(e, SeverityError, fmt.Sprintf("template '%s' can't be loaded", e.Filename))
+		}
+	}
+}
+
+// UnknownFilter reports filters which don't exist in the set, suggesting the
+// most similar filter names.
+type UnknownFilter struct {
+	// MaxDistance is the maximum number of edits (insertions, deletions,
+	// substitutions) of the suggested names (2 if 0).
+	MaxDistance int
+}
+
+func (r *UnknownFilter) Name() string { return "unknown-filter" }
+
+func (r *UnknownFilter) Check(pass *Pass) {
+	for _, e := range pass.Errors {
+		if e.Code == pongo2.ErrCodeUnknownFilter && e.Token != nil {
+			pass.ReportError(e, SeverityError, fmt.Sprintf("filter '%s' does not exist", e.Token.Val), r.suggest(pass, e.Token.Val)...)
+		}
+	}
+
+	// Filters of {% filter %} are looked up when the template is executed
+	if pass.AST == nil {
+		return
+	}
+	pongo2.Inspect(pass.AST, func(n *pongo2.Node) bool {
+		if n == nil {
+			return true
+		}
+		for _, fc := range n.Filters {
+			if !pass.Set.FilterExists(fc.Name) {
+				pass.Report(SeverityError, fc.Line, fc.Column, fmt.Sprintf("filter '%s' does not exist", fc.Name), r.suggest(pass, fc.Name)...)
+			}
+		}
+		return true
+	})
+}
+
+// suggest returns up to 3 filter names similar to name (most similar first).
+func (r *UnknownFilter) suggest(pass *Pass, name string) []string {
+	maxDistance := cmp.Or(r.MaxDistance, 2)
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, filter := range pass.Set.FilterNames() {
+		if d := editDistance(name, filter); d <= maxDistance && d < len(name) {
+			candidates = append(candidates, candidate{filter, d})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return cmp.Compare(a.distance, b.distance) })
+
+	var names []string
+	for _, c := range candidates[:min(len(candidates), 3)] {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// inspectExprs calls fn for every expression (and subexpression) of the tree.
+func inspectExprs(root *pongo2.Node, fn func(e *pongo2.Expr)) {
+	pongo2.Inspect(root, func(n *pongo2.Node) bool {
+		if n == nil {
+			return true
+		}
+		for _, e := range n.Expressions() {
+			pongo2.InspectExpr(e, func(e *pongo2.Expr) bool {
+				if e != nil {
+					fn(e)
+				}
+				return true
+			})
+		}
+		return true
+	})
+}
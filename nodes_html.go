@@ -1,23 +1,14 @@
 package pongo2
 
-import (
-	"strings"
-)
-
+// nodeHTML outputs the HTML (text) between tags. The whitespace control
+// (TrimBlocks, LStripBlocks, {{- and -}}) is applied when the template is
+// compiled, so text is written as is.
 type nodeHTML struct {
-	token     *Token
-	trimLeft  bool
-	trimRight bool
+	token *Token
+	text  string
 }
 
 func (n *nodeHTML) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
-	res := n.token.Val
-	if n.trimLeft {
-		res = strings.TrimLeft(res, tokenSpaceChars)
-	}
-	if n.trimRight {
-		res = strings.TrimRight(res, tokenSpaceChars)
-	}
-	_, err := writer.WriteString(res)
+	_, err := writer.WriteString(n.text)
 	return err
 }
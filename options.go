@@ -28,6 +28,43 @@ type Options struct {
 	// If this is set to true, struct fields, map keys, and variable names will be treated as case-insensitive.
 	IgnoreVariableCase bool
 
+	// If this is set to true, struct fields can also be accessed by the name
+	// given in their `pongo2` struct tag (or `json` tag if there's no `pongo2`
+	// tag), e.g. {{ user.first_name }}. Fields tagged with "-" are hidden.
+	// The `in` operator and the dictsort filter honor the tag names, too.
+	StructTags bool
+
+	// If this is set to true, using an undefined variable, a missing map key
+	// or struct field or an index out of range is an execution error (with
+	// code ErrCodeUndefinedVariable) instead of an empty value. The defined
+	// and undefined tests and the default filter work on undefined variables.
+	StrictUndefined bool
+
+	// If this is set to true, undefined variables (see StrictUndefined) output
+	// by {{ }} without filters are rendered as a placeholder like
+	// {{ user.nmae }}. They're still false and empty in expressions and
+	// filters. StrictUndefined takes precedence.
+	DebugUndefined bool
+
+	// If this is set to true, all {% include %}s are rendered concurrently,
+	// not only the ones marked as "parallel". See the include tag.
+	ParallelIncludes bool
+
+	// Limits the number of includes rendered concurrently during one template
+	// execution. Defaults to 0 (no limit).
+	ParallelIncludesLimit int
+
+	// If this is set to true, the HTML context of every {{ variable }} is
+	// determined at compile time and its output is escaped for it (HTML text,
+	// attribute value, URL, JavaScript or CSS). Output in contexts which
+	// can't be escaped safely is rejected when compiling the template.
+	ContextualAutoescape bool
+
+	// If this is set to true, compiling a template doesn't stop at the first
+	// error: the parser skips to the next tag or variable and goes on. All
+	// errors are returned as an *ErrorList.
+	CollectErrors bool
+
 	// Assigns a translation function to be used for the translate tag.
 	Translator TranslateFunc
 
@@ -35,6 +72,22 @@ type Options struct {
 	EnableTranslatorShorthand bool
 }
 
+// compileOptions are the options which are applied when a template is
+// compiled. Templates are recompiled if they change (see Template.recompile).
+type compileOptions struct {
+	trimBlocks           bool
+	lstripBlocks         bool
+	contextualAutoescape bool
+}
+
+func (opt *Options) compileOptions() compileOptions {
+	return compileOptions{
+		trimBlocks:           opt.TrimBlocks,
+		lstripBlocks:         opt.LStripBlocks,
+		contextualAutoescape: opt.ContextualAutoescape,
+	}
+}
+
 func newOptions() *Options {
 	return &Options{
 		TrimBlocks:              false,
@@ -44,6 +97,7 @@ func newOptions() *Options {
 		DisableContextFunctions: false,
 		DisableNestedFunctions:  false,
 		IgnoreVariableCase:      false,
+		StructTags:              false,
 	}
 }
 
@@ -56,6 +110,13 @@ func (opt *Options) Update(other *Options) *Options {
 	opt.DisableContextFunctions = other.DisableContextFunctions
 	opt.DisableNestedFunctions = other.DisableNestedFunctions
 	opt.IgnoreVariableCase = other.IgnoreVariableCase
+	opt.StructTags = other.StructTags
+	opt.StrictUndefined = other.StrictUndefined
+	opt.DebugUndefined = other.DebugUndefined
+	opt.ParallelIncludes = other.ParallelIncludes
+	opt.ParallelIncludesLimit = other.ParallelIncludesLimit
+	opt.ContextualAutoescape = other.ContextualAutoescape
+	opt.CollectErrors = other.CollectErrors
 	opt.Translator = other.Translator
 
 	return opt
@@ -0,0 +1,155 @@
+package pongo2
+
+import (
+	"bytes"
+	"sync"
+)
+
+// parallelWriter is the TemplateWriter used to execute templates containing
+// parallel includes. It records the output as a list of segments: static
+// segments hold the output written by the template itself, async segments
+// are filled by an include rendered in its own goroutine. Once the template
+// has been executed, finish waits for all includes and stitches the
+// segments back together in document order.
+type parallelWriter struct {
+	// out receives the stitched output.
+	out TemplateWriter
+
+	// all is true if all includes are rendered in parallel
+	// (Options.ParallelIncludes), not only the ones marked as "parallel".
+	all bool
+
+	// sem limits the number of includes rendered at the same time; nil
+	// means no limit.
+	sem chan struct{}
+
+	segments []*parallelSegment
+	current  *bytes.Buffer
+	wg       sync.WaitGroup
+}
+
+// parallelSegment is a part of the output of a parallelWriter.
+type parallelSegment struct {
+	buf bytes.Buffer
+
+	// Only set for async segments after the include finished.
+	err      error
+	panicked bool
+	panicVal any
+}
+
+// parallelBuffer is the writer a parallel include is rendered into. Includes
+// nested inside a parallel include are rendered sequentially into the same
+// buffer (this keeps the concurrency limit intact and can't deadlock).
+type parallelBuffer struct {
+	*bytes.Buffer
+}
+
+func newParallelWriter(out TemplateWriter, all bool, limit int) *parallelWriter {
+	pw := &parallelWriter{out: out, all: all}
+	if limit > 0 {
+		pw.sem = make(chan struct{}, limit)
+	}
+	return pw
+}
+
+// Write implements io.Writer by appending to the current static segment.
+func (pw *parallelWriter) Write(p []byte) (int, error) {
+	return pw.static().Write(p)
+}
+
+// WriteString appends s to the current static segment.
+func (pw *parallelWriter) WriteString(s string) (int, error) {
+	return pw.static().WriteString(s)
+}
+
+func (pw *parallelWriter) static() *bytes.Buffer {
+	if pw.current == nil {
+		seg := &parallelSegment{}
+		pw.segments = append(pw.segments, seg)
+		pw.current = &seg.buf
+	}
+	return pw.current
+}
+
+// spawn renders an include into a new async segment. It blocks while the
+// concurrency limit is reached. Panics of render are recovered and re-raised
+// by finish.
+func (pw *parallelWriter) spawn(render func(writer TemplateWriter) error) {
+	seg := &parallelSegment{}
+	pw.segments = append(pw.segments, seg)
+	pw.current = nil
+
+	if pw.sem != nil {
+		pw.sem <- struct{}{}
+	}
+	pw.wg.Add(1)
+	go func() {
+		defer pw.wg.Done()
+		defer func() {
+			if pw.sem != nil {
+				<-pw.sem
+			}
+			if r := recover(); r != nil {
+				seg.panicked = true
+				seg.panicVal = r
+			}
+		}()
+		seg.err = render(parallelBuffer{&seg.buf})
+	}()
+}
+
+// finish waits for all includes and writes the stitched output to out.
+// execErr is the error the template execution itself returned. If any
+// include failed, the error (or panic) that comes first in document order
+// is returned (or re-raised) and nothing is written; execErr counts as
+// coming after all includes spawned before it.
+func (pw *parallelWriter) finish(execErr error) error {
+	if err := pw.wait(); err != nil {
+		return err
+	}
+	if execErr != nil {
+		return execErr
+	}
+	return pw.writeSegments()
+}
+
+// flush waits for the includes spawned so far, writes the output up to
+// here to out and flushes it (see flushWriter). The template execution
+// continues with a new static segment.
+func (pw *parallelWriter) flush() error {
+	if err := pw.wait(); err != nil {
+		return err
+	}
+	if err := pw.writeSegments(); err != nil {
+		return err
+	}
+	pw.segments = pw.segments[:0]
+	pw.current = nil
+	return flushWriter(pw.out)
+}
+
+// wait waits for all spawned includes and returns the first error in
+// document order (or re-raises the first panic).
+func (pw *parallelWriter) wait() error {
+	pw.wg.Wait()
+
+	for _, seg := range pw.segments {
+		if seg.panicked {
+			panic(seg.panicVal)
+		}
+		if seg.err != nil {
+			return seg.err
+		}
+	}
+	return nil
+}
+
+func (pw *parallelWriter) writeSegments() error {
+	for _, seg := range pw.segments {
+		if _, err := seg.buf.WriteTo(pw.out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
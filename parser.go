@@ -40,6 +40,18 @@ type Parser struct {
 	// if the parser parses a template document, here will be
 	// a reference to it (needed to access the template through Tags)
 	template *Template
+
+	// tracks the HTML context of the document (see Options.ContextualAutoescape)
+	html *htmlContext
+
+	// collects the errors of the document and the block tags which failed to
+	// parse, whose bodies are parsed on their own (see Options.CollectErrors)
+	errors     *ErrorList
+	failedTags []string
+
+	// the end tags the enclosing WrapUntilTag calls are waiting for,
+	// innermost last (only tracked with Options.CollectErrors)
+	openTags [][]string
 }
 
 // Creates a new parser to parse tokens.
@@ -204,6 +216,7 @@ func (p *Parser) Error(msg string, token *Token) *Error {
 		Template:  p.template,
 		Filename:  p.name,
 		Sender:    "parser",
+		Code:      ErrCodeSyntax,
 		Line:      line,
 		Column:    col,
 		Token:     token,
@@ -219,6 +232,11 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, error) {
 
 	var tagArgs []*Token
 
+	if p.errors != nil {
+		p.openTags = append(p.openTags, names)
+		defer func() { p.openTags = p.openTags[:len(p.openTags)-1] }()
+	}
+
 	for p.Remaining() > 0 {
 		// New tag, check whether we have to stop wrapping here
 		if p.Peek(TokenSymbol, "{%") != nil {
@@ -229,6 +247,13 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, error) {
 
 				found := slices.Contains(names, tagIdent.Val)
 
+				if !found && p.closesOuterTag(tagIdent.Val) {
+					// Leave the end tag to the enclosing block tag, this
+					// one is unclosed
+					return nil, nil, p.Error(fmt.Sprintf("Unexpected tag '%s', expected tag %s.",
+						tagIdent.Val, strings.Join(names, " or ")), tagIdent)
+				}
+
 				// We only process the tag if we've found an end tag
 				if found {
 					// Okay, endtag found.
@@ -257,13 +282,26 @@ func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, error) {
 		if err != nil {
 			return nil, nil, err
 		}
-		wrapper.nodes = append(wrapper.nodes, node)
+		if node != nil {
+			wrapper.nodes = append(wrapper.nodes, node)
+		}
 	}
 
 	return nil, nil, p.Error(fmt.Sprintf("Unexpected EOF, expected tag %s.", strings.Join(names, " or ")),
 		p.lastToken)
 }
 
+// closesOuterTag reports whether name is one of the tags an enclosing
+// WrapUntilTag call (but not the innermost one) is waiting for.
+func (p *Parser) closesOuterTag(name string) bool {
+	for i := len(p.openTags) - 2; i >= 0; i-- {
+		if slices.Contains(p.openTags[i], name) {
+			return true
+		}
+	}
+	return false
+}
+
 // Skips all nodes between starting tag and "{% endtag %}"
 func (p *Parser) SkipUntilTag(names ...string) error {
 	for p.Remaining() > 0 {
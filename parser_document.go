@@ -1,16 +1,33 @@
 package pongo2
 
+import (
+	"slices"
+	"strings"
+)
+
 // Doc = { ( Filter | Tag | HTML ) }
+//
+// With Options.CollectErrors, errors are recorded and the element is skipped
+// (returning a nil node).
 func (p *Parser) parseDocElement() (INode, error) {
+	start := p.idx
+	node, err := p.parseDocElementStrict()
+	if err != nil && p.errors != nil {
+		p.recover(start, err)
+		return nil, nil
+	}
+	return node, err
+}
+
+func (p *Parser) parseDocElementStrict() (INode, error) {
 	t := p.Current()
 
 	switch t.Typ {
 	case TokenHTML:
-		n := &nodeHTML{token: t}
-		left := p.PeekTypeN(-1, TokenSymbol)
-		right := p.PeekTypeN(1, TokenSymbol)
-		n.trimLeft = left != nil && left.TrimWhitespaces
-		n.trimRight = right != nil && right.TrimWhitespaces
+		n := &nodeHTML{token: t, text: p.trimHTML(t)}
+		if p.html != nil {
+			p.html.feed(n.text)
+		}
 		p.Consume() // consume HTML element
 		return n, nil
 	case TokenSymbol:
@@ -34,12 +51,134 @@ func (p *Parser) parseDocElement() (INode, error) {
 	return nil, p.Error("Unexpected token (only HTML/tags/filters in templates allowed)", t)
 }
 
+// trimHTML applies the whitespace control to the current HTML token t:
+// TrimBlocks removes the first newline after a tag, LStripBlocks strips
+// spaces and tabs before a tag and {{-, -}}, {%- and -%} strip all
+// whitespace next to them.
+func (p *Parser) trimHTML(t *Token) string {
+	text := t.Val
+	left := p.PeekTypeN(-1, TokenSymbol)
+	right := p.PeekTypeN(1, TokenSymbol)
+
+	// Issue #94 https://github.com/flosch/pongo2/issues/94
+	// If an application configures pongo2 template to trim_blocks,
+	// the first newline after a template tag is removed automatically (like in PHP).
+	if p.template.Options.TrimBlocks && left != nil && left.Val == "%}" {
+		text = strings.TrimPrefix(text, "\n")
+	}
+	if p.template.Options.LStripBlocks && right != nil && right.Val == "{%" {
+		text = strings.TrimRight(text, "\t ")
+	}
+
+	if left != nil && left.TrimWhitespaces {
+		text = strings.TrimLeft(text, tokenSpaceChars)
+	}
+	if right != nil && right.TrimWhitespaces {
+		text = strings.TrimRight(text, tokenSpaceChars)
+	}
+	return text
+}
+
+// recover records err of the element starting at start and skips to the
+// next tag or variable.
+//
+// If a block tag failed to parse before its body was consumed, its body is
+// parsed as if it was outside of the tag. Its end tag and its intermediate
+// tags (like else, see intermediateTags) are then skipped silently; other
+// unknown tags are reported.
+func (p *Parser) recover(start int, err error) {
+	var tagName string
+	if t := p.Get(start); t != nil && t.Typ == TokenSymbol && t.Val == "{%" {
+		if ident := p.Get(start + 1); ident != nil && ident.Typ == TokenIdentifier {
+			tagName = ident.Val
+		}
+	}
+	_, known := p.template.set.tags[tagName]
+
+	switch {
+	case tagName != "" && !known && len(p.failedTags) > 0 && belongsToTag(tagName, p.failedTags[len(p.failedTags)-1]):
+		if tagName == "end"+p.failedTags[len(p.failedTags)-1] {
+			p.failedTags = p.failedTags[:len(p.failedTags)-1]
+		}
+	default:
+		p.errors.add(err)
+		if tagName != "" && known && !p.bodyConsumed(start) && p.hasEndTag(tagName) {
+			p.failedTags = append(p.failedTags, tagName)
+		}
+	}
+
+	// Resync at the next tag or variable
+	p.idx = max(p.idx, start+1)
+	for p.Remaining() > 0 && p.Peek(TokenSymbol, "{%") == nil && p.Peek(TokenSymbol, "{{") == nil {
+		p.Consume()
+	}
+}
+
+// intermediateTags are the tags between the start and end tag of the builtin
+// block tags.
+var intermediateTags = map[string][]string{
+	"if":         {"elif", "elseif", "else"},
+	"for":        {"empty"},
+	"ifchanged":  {"else"},
+	"ifequal":    {"else"},
+	"ifnotequal": {"else"},
+}
+
+// belongsToTag reports whether name is the end tag or an intermediate tag of
+// the block tag.
+func belongsToTag(name, tag string) bool {
+	return name == "end"+tag || slices.Contains(intermediateTags[tag], name)
+}
+
+// bodyConsumed reports whether the parser went past the end of the tag
+// starting at start.
+func (p *Parser) bodyConsumed(start int) bool {
+	for i := start; i < p.idx; i++ {
+		if t := p.Get(i); t.Typ == TokenSymbol && t.Val == "%}" {
+			return p.idx > i+1
+		}
+	}
+	return false
+}
+
+// hasEndTag reports whether the end tag of the block tag name follows.
+func (p *Parser) hasEndTag(name string) bool {
+	depth := 0
+	for i := p.idx; i+1 < len(p.tokens); i++ {
+		if t := p.tokens[i]; t.Typ != TokenSymbol || t.Val != "{%" {
+			continue
+		}
+		if ident := p.tokens[i+1]; ident.Typ == TokenIdentifier {
+			switch ident.Val {
+			case name:
+				depth++
+			case "end" + name:
+				if depth == 0 {
+					return true
+				}
+				depth--
+			}
+		}
+	}
+	return false
+}
+
 func (tpl *Template) parse() error {
+	tpl.compiledWith = tpl.Options.compileOptions()
 	parser := newParser(tpl.name, tpl.tokens, tpl)
+	if tpl.Options.ContextualAutoescape {
+		parser.html = &htmlContext{}
+	}
+	if tpl.Options.CollectErrors {
+		parser.errors = &ErrorList{}
+	}
 	doc, err := parser.parseDocument()
 	if err != nil {
 		return err
 	}
+	if parser.errors != nil && len(parser.errors.Errors) > 0 {
+		return parser.errors
+	}
 	tpl.root = doc
 	return nil
 }
@@ -52,7 +191,9 @@ func (p *Parser) parseDocument() (*nodeDocument, error) {
 		if err != nil {
 			return nil, err
 		}
-		doc.Nodes = append(doc.Nodes, node)
+		if node != nil {
+			doc.Nodes = append(doc.Nodes, node)
+		}
 	}
 
 	return doc, nil
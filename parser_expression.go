@@ -1,6 +1,7 @@
 package pongo2
 
 import (
+	"errors"
 	"fmt"
 	"math"
 )
@@ -205,7 +206,7 @@ func (expr *relationalExpression) Evaluate(ctx *ExecutionContext) (*Value, error
 		case "!=", "<>":
 			return AsValue(!v1.EqualValueTo(v2)), nil
 		case "in":
-			return AsValue(v2.Contains(v1)), nil
+			return AsValue(v2.contains(ctx, v1)), nil
 		default:
 			return nil, ctx.Error(fmt.Sprintf("unimplemented: %s", expr.opToken.Val), expr.opToken)
 		}
@@ -299,21 +300,21 @@ func (expr *term) Evaluate(ctx *ExecutionContext) (*Value, error) {
 				// Result will be float
 				divisor := f2.Float()
 				if divisor == 0 {
-					return nil, ctx.Error("float divide by zero", expr.factor2.GetPositionToken())
+					return nil, ctx.OrigError(withErrorCode(ErrCodeDivideByZero, errors.New("float divide by zero")), expr.factor2.GetPositionToken())
 				}
 				return AsValue(f1.Float() / divisor), nil
 			}
 			// Result will be int
 			divisor := f2.Integer()
 			if divisor == 0 {
-				return nil, ctx.Error("integer divide by zero", expr.factor2.GetPositionToken())
+				return nil, ctx.OrigError(withErrorCode(ErrCodeDivideByZero, errors.New("integer divide by zero")), expr.factor2.GetPositionToken())
 			}
 			return AsValue(f1.Integer() / divisor), nil
 		case "%":
 			// Result will be int
 			divisor := f2.Integer()
 			if divisor == 0 {
-				return nil, ctx.Error("integer divide by zero", expr.factor2.GetPositionToken())
+				return nil, ctx.OrigError(withErrorCode(ErrCodeDivideByZero, errors.New("integer divide by zero")), expr.factor2.GetPositionToken())
 			}
 			return AsValue(f1.Integer() % divisor), nil
 		default:
@@ -0,0 +1,168 @@
+package pongo2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// StreamOptions configures Template.ExecuteStream.
+type StreamOptions struct {
+	// FlushBytes flushes the output as soon as at least this many bytes have
+	// been rendered since the last flush. Defaults to 0 (disabled).
+	FlushBytes int
+
+	// FlushBlocks flushes the output after each top-level {% block %} of the
+	// rendered (base) template.
+	FlushBlocks bool
+
+	// Flush is called after the output has been written to the writer at a
+	// flush point. If nil, the writer's Flush method is used if it has one
+	// (like http.Flusher or *bufio.Writer). For http.ResponseWriters wrapped by
+	// middleware, http.NewResponseController(w).Flush can be passed.
+	Flush func() error
+}
+
+// StreamError is returned by ExecuteStream if the execution failed after
+// parts of the output have already been flushed to the writer.
+type StreamError struct {
+	// Err is the error that stopped the execution.
+	Err error
+
+	// Flushed is the number of bytes which were written to the writer
+	// before the error occurred.
+	Flushed int64
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("%v (after %d bytes of output were flushed)", e.Err, e.Flushed)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// streamWriter buffers the output of ExecuteStream between flush points.
+type streamWriter struct {
+	w           io.Writer
+	buf         bytes.Buffer
+	flushBytes  int
+	flushBlocks bool
+	flushFn     func() error
+	flushed     int64
+}
+
+func newStreamWriter(w io.Writer, opts StreamOptions) *streamWriter {
+	sw := &streamWriter{
+		w:           w,
+		flushBytes:  opts.FlushBytes,
+		flushBlocks: opts.FlushBlocks,
+		flushFn:     opts.Flush,
+	}
+	if sw.flushFn == nil {
+		switch f := w.(type) {
+		case interface{ Flush() error }:
+			sw.flushFn = f.Flush
+		case interface{ Flush() }:
+			sw.flushFn = func() error {
+				f.Flush()
+				return nil
+			}
+		}
+	}
+	return sw
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	n, _ := sw.buf.Write(p)
+	return n, sw.flushIfFull()
+}
+
+func (sw *streamWriter) WriteString(s string) (int, error) {
+	n, _ := sw.buf.WriteString(s)
+	return n, sw.flushIfFull()
+}
+
+func (sw *streamWriter) flushIfFull() error {
+	if sw.flushBytes > 0 && sw.buf.Len() >= sw.flushBytes {
+		return sw.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered output to the writer and flushes it. It's a
+// no-op if nothing has been rendered since the last flush.
+func (sw *streamWriter) flush() error {
+	if sw.buf.Len() == 0 {
+		return nil
+	}
+	n, err := sw.buf.WriteTo(sw.w)
+	sw.flushed += n
+	if err != nil {
+		return err
+	}
+	if sw.flushFn != nil {
+		return sw.flushFn()
+	}
+	return nil
+}
+
+// flushWriter flushes the output written to writer so far if the template is
+// executed by ExecuteStream. For other writers, it's a no-op.
+func flushWriter(writer TemplateWriter) error {
+	switch w := writer.(type) {
+	case *streamWriter:
+		return w.flush()
+	case *parallelWriter:
+		return w.flush()
+	}
+	return nil
+}
+
+// executeDocument runs the nodes of doc. If the template is executed by
+// ExecuteStream with StreamOptions.FlushBlocks, the output is flushed after
+// each block.
+func executeDocument(doc *nodeDocument, ctx *ExecutionContext, writer TemplateWriter, flushBlocks bool) error {
+	if !flushBlocks {
+		return doc.Execute(ctx, writer)
+	}
+	for _, n := range doc.Nodes {
+		if err := n.Execute(ctx, writer); err != nil {
+			return err
+		}
+		if tag, isTag := n.(*nodeTag); isTag {
+			if _, isBlock := tag.node.(*tagBlockNode); isBlock {
+				if err := flushWriter(writer); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ExecuteStream executes the template and streams the output to writer,
+// flushing it at the flush points configured by opts and at {% flush %} tags.
+// This lets e.g. browsers display the beginning of large pages before slow
+// sections have been rendered.
+//
+// The output between two flush points is buffered. If an error occurs, the
+// buffered output is discarded:
+//   - If nothing has been flushed yet, nothing is written to writer and the
+//     error is returned as is (like ExecuteWriter).
+//   - Otherwise the output up to the last flush point has already been
+//     written and the error is returned wrapped in a *StreamError. For HTTP
+//     responses, the status code can't be changed anymore at this point.
+//
+// On success, the remaining output is written and flushed.
+func (tpl *Template) ExecuteStream(context Context, writer io.Writer, opts StreamOptions) error {
+	sw := newStreamWriter(writer, opts)
+	err := tpl.execute(context, sw)
+	if err == nil {
+		err = sw.flush()
+	}
+	if err != nil && sw.flushed > 0 {
+		return &StreamError{Err: err, Flushed: sw.flushed}
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package pongo2
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// flushRecorder records the output available at every Flush call.
+type flushRecorder struct {
+	bytes.Buffer
+	flushes []string
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes = append(r.flushes, r.String())
+}
+
+func TestExecuteStream(t *testing.T) {
+	templates := map[string]string{
+		"base.html":  `<{% block head %}H{% endblock %}|{% block body %}{% endblock %}>`,
+		"page.html":  `{% extends "base.html" %}{% block body %}a{% flush %}b{% include "part.html" %}c{% endblock %}`,
+		"part.html":  `[{% flush %}]`,
+		"flush.html": `a{% flush %}{% filter upper %}b{% flush %}c{% endfilter %}{% flush %}{% flush %}d`,
+		"fail.html":  `a{% flush %}b{{ fail() }}c`,
+		"early.html": `a{{ fail() }}{% flush %}`,
+		"para.html":  `a{% include "part.html" parallel %}{% flush %}b{% include "part.html" parallel %}`,
+	}
+	set := NewSet("test-stream", NewMapLoader(templates))
+
+	errFail := errors.New("fail")
+	ctx := Context{"fail": func() (string, error) { return "", errFail }}
+
+	tests := []struct {
+		name     string
+		template string
+		opts     StreamOptions
+		output   string
+		flushes  []string
+	}{
+		{
+			name:     "flush tags",
+			template: "flush.html",
+			output:   "aBCd",
+			flushes:  []string{"a", "aBC", "aBCd"},
+		},
+		{
+			name:     "blocks and includes",
+			template: "page.html",
+			opts:     StreamOptions{FlushBlocks: true},
+			output:   "<H|ab[]c>",
+			flushes:  []string{"<H", "<H|a", "<H|ab[", "<H|ab[]c", "<H|ab[]c>"},
+		},
+		{
+			name:     "bytes",
+			template: "base.html",
+			opts:     StreamOptions{FlushBytes: 2},
+			output:   "<H|>",
+			flushes:  []string{"<H", "<H|>"},
+		},
+		{
+			name:     "parallel includes",
+			template: "para.html",
+			output:   "a[]b[]",
+			flushes:  []string{"a[]", "a[]b[]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := set.FromFile(tt.template)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+			var rec flushRecorder
+			if err := tpl.ExecuteStream(ctx, &rec, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rec.String() != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, rec.String())
+			}
+			if strings.Join(rec.flushes, ",") != strings.Join(tt.flushes, ",") {
+				t.Errorf("expected flushes %q, got %q", tt.flushes, rec.flushes)
+			}
+		})
+	}
+
+	t.Run("error after flush", func(t *testing.T) {
+		tpl := Must(set.FromFile("fail.html"))
+		var rec flushRecorder
+		err := tpl.ExecuteStream(ctx, &rec, StreamOptions{})
+		var streamErr *StreamError
+		if !errors.As(err, &streamErr) || streamErr.Flushed != 1 {
+			t.Fatalf("expected a StreamError after 1 byte, got %v", err)
+		}
+		if !errors.Is(err, errFail) {
+			t.Errorf("expected the error to wrap errFail, got %v", err)
+		}
+		if rec.String() != "a" {
+			t.Errorf("expected only the flushed output, got %q", rec.String())
+		}
+	})
+
+	t.Run("error before flush", func(t *testing.T) {
+		tpl := Must(set.FromFile("early.html"))
+		var rec flushRecorder
+		err := tpl.ExecuteStream(ctx, &rec, StreamOptions{})
+		var streamErr *StreamError
+		if err == nil || errors.As(err, &streamErr) {
+			t.Fatalf("expected a plain error, got %v", err)
+		}
+		if rec.Len() != 0 {
+			t.Errorf("expected no output, got %q", rec.String())
+		}
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		tpl := Must(set.FromFile("flush.html"))
+		var out bytes.Buffer
+		calls := 0
+		opts := StreamOptions{Flush: func() error {
+			calls++
+			return nil
+		}}
+		if err := tpl.ExecuteStream(nil, &out, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 || out.String() != "aBCd" {
+			t.Errorf("got %d flushes and output %q", calls, out.String())
+		}
+	})
+
+	t.Run("no-op elsewhere", func(t *testing.T) {
+		result, err := set.RenderTemplateFile("flush.html", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "aBCd" {
+			t.Errorf("got %q", result)
+		}
+	})
+}
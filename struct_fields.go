@@ -0,0 +1,108 @@
+package pongo2
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structFieldIndex maps the struct tag names of a struct type's fields to the
+// fields. It's built once per type and cached in structFieldIndexes.
+type structFieldIndex struct {
+	byName  map[string]reflect.StructField
+	byLower map[string]reflect.StructField
+	// hidden contains the index paths (see indexKey) of fields tagged with "-"
+	hidden map[string]bool
+}
+
+var structFieldIndexes sync.Map // reflect.Type -> *structFieldIndex
+
+// getStructFieldIndex returns the cached field index of the struct type typ.
+func getStructFieldIndex(typ reflect.Type) *structFieldIndex {
+	if idx, ok := structFieldIndexes.Load(typ); ok {
+		return idx.(*structFieldIndex)
+	}
+	idx, _ := structFieldIndexes.LoadOrStore(typ, newStructFieldIndex(typ))
+	return idx.(*structFieldIndex)
+}
+
+func newStructFieldIndex(typ reflect.Type) *structFieldIndex {
+	idx := &structFieldIndex{
+		byName:  make(map[string]reflect.StructField),
+		byLower: make(map[string]reflect.StructField),
+		hidden:  make(map[string]bool),
+	}
+	// VisibleFields includes promoted fields of embedded structs; shallower
+	// fields win in case two fields share the same tag name.
+	for _, sf := range reflect.VisibleFields(typ) {
+		name, ok := structTagName(sf)
+		if !ok {
+			continue
+		}
+		if name == "-" {
+			idx.hidden[indexKey(sf.Index)] = true
+			continue
+		}
+		if prev, exists := idx.byName[name]; !exists || len(sf.Index) < len(prev.Index) {
+			idx.byName[name] = sf
+		}
+		lower := strings.ToLower(name)
+		if prev, exists := idx.byLower[lower]; !exists || len(sf.Index) < len(prev.Index) {
+			idx.byLower[lower] = sf
+		}
+	}
+	return idx
+}
+
+// structTagName returns the name given to the field by its `pongo2` struct
+// tag, or its `json` tag if there's no `pongo2` tag. Options after the name
+// (like ",omitempty") are ignored.
+func structTagName(sf reflect.StructField) (string, bool) {
+	tag, ok := sf.Tag.Lookup("pongo2")
+	if !ok {
+		tag, ok = sf.Tag.Lookup("json")
+	}
+	if !ok {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, name != ""
+}
+
+// indexKey returns a map key for a field index path.
+func indexKey(index []int) string {
+	return fmt.Sprint(index)
+}
+
+// lookup returns the field tagged with name.
+func (idx *structFieldIndex) lookup(name string, ignoreCase bool) (reflect.StructField, bool) {
+	sf, ok := idx.byName[name]
+	if !ok && ignoreCase {
+		sf, ok = idx.byLower[strings.ToLower(name)]
+	}
+	return sf, ok
+}
+
+// isHidden returns true if the field is tagged with "-".
+func (idx *structFieldIndex) isHidden(sf reflect.StructField) bool {
+	return len(idx.hidden) > 0 && idx.hidden[indexKey(sf.Index)]
+}
+
+// structFieldByName returns the field of the struct type typ with the given
+// name. With structTags, tag names take precedence over Go names and fields
+// tagged with "-" aren't found.
+func structFieldByName(typ reflect.Type, name string, structTags bool) (reflect.StructField, bool) {
+	if !structTags {
+		return typ.FieldByName(name)
+	}
+	tagged := getStructFieldIndex(typ)
+	sf, found := tagged.lookup(name, false)
+	if !found {
+		sf, found = typ.FieldByName(name)
+	}
+	if found && tagged.isHidden(sf) {
+		return reflect.StructField{}, false
+	}
+	return sf, found
+}
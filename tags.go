@@ -20,6 +20,7 @@ package pongo2
 */
 
 import (
+	"bytes"
 	"fmt"
 	"maps"
 )
@@ -96,15 +97,15 @@ func (p *Parser) parseTagElement() (INodeTag, error) {
 	}
 
 	// Check sandbox tag restriction
-	if _, isBanned := p.template.set.bannedTags[tokenName.Val]; isBanned {
-		return nil, p.Error(fmt.Sprintf("Usage of tag '%s' is not allowed (sandbox restriction active).", tokenName.Val), tokenName)
+	if !p.template.set.isTagAllowed(tokenName.Val) {
+		return nil, p.Error(fmt.Sprintf("Usage of tag '%s' is not allowed (sandbox restriction active).", tokenName.Val), tokenName).withCode(ErrCodeSandbox)
 	}
 
 	// Check for the existing tag
 	tag, exists := p.template.set.tags[tokenName.Val]
 	if !exists {
 		// Does not exists
-		return nil, p.Error(fmt.Sprintf("Tag '%s' not found (or beginning tag not provided)", tokenName.Val), tokenName)
+		return nil, p.Error(fmt.Sprintf("Tag '%s' not found (or beginning tag not provided)", tokenName.Val), tokenName).withCode(ErrCodeUnknownTag)
 	}
 
 	var argsToken []*Token
@@ -121,13 +122,82 @@ func (p *Parser) parseTagElement() (INodeTag, error) {
 
 	p.Match(TokenSymbol, "%}")
 
+	if p.html != nil {
+		switch tokenName.Val {
+		case "block", "include", "ssi":
+			if !p.html.allowsTemplates() {
+				return nil, p.Error(fmt.Sprintf("Contextual autoescape: tag '%s' is only allowed in HTML text.", tokenName.Val), tokenName)
+			}
+		case "macro":
+			// Macros are called from {{ }} in HTML text, so their body
+			// starts there as well
+			outer := *p.html
+			*p.html = htmlContext{}
+			defer func() { *p.html = outer }()
+		}
+	}
+
 	argParser := newParser(p.name, argsToken, p.template)
 	if len(argsToken) == 0 {
 		// This is done to have nice EOF error messages
 		argParser.lastToken = tokenName
 	}
 
+	inText := p.html == nil || p.html.allowsTemplates()
+
 	p.template.level++
 	defer func() { p.template.level-- }()
-	return tag.parser(p, tokenName, argParser)
+	node, err := tag.parser(p, tokenName, argParser)
+	if err != nil || node == nil {
+		return node, err
+	}
+	tagNode := &nodeTag{name: tokenName.Val, token: tokenName, node: node}
+
+	if !inText {
+		switch contextTagOutput(node) {
+		case tagOutputBody:
+		case tagOutputDirect:
+			escaper, err := p.html.outputEscaper()
+			if err != nil {
+				return nil, p.Error(fmt.Sprintf("Contextual autoescape: output not allowed in %s.", err), tokenName)
+			}
+			tagNode.escaper = escaper
+		default:
+			return nil, p.Error(fmt.Sprintf("Contextual autoescape: tag '%s' is only allowed in HTML text.", tokenName.Val), tokenName)
+		}
+	}
+	return tagNode, nil
+}
+
+// nodeTag is a tag in the document: the node created by the tag's parser
+// along with the tag's name and position (used by the template analysis).
+type nodeTag struct {
+	name  string
+	token *Token
+	node  INodeTag
+
+	// escaper is set for tags writing output outside of HTML text
+	// (Options.ContextualAutoescape)
+	escaper *contextEscaper
+}
+
+func (n *nodeTag) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
+	if n.escaper == nil || !ctx.Autoescape {
+		return n.node.Execute(ctx, writer)
+	}
+
+	// Render the tag's output unescaped and escape it for its position
+	var b bytes.Buffer
+	ctx.Autoescape = false
+	err := n.node.Execute(ctx, &b)
+	ctx.Autoescape = true
+	if err != nil || b.Len() == 0 {
+		return err
+	}
+	value, err := n.escaper.escape(ctx, AsValue(b.String()), false)
+	if err != nil {
+		return err
+	}
+	_, err = writer.WriteString(value.String())
+	return err
 }
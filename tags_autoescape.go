@@ -1,5 +1,7 @@
 package pongo2
 
+import "fmt"
+
 // tagAutoescapeNode represents the {% autoescape %} tag.
 //
 // The autoescape tag controls automatic HTML escaping for a block of template content.
@@ -23,30 +25,41 @@ package pongo2
 //	{% endautoescape %}
 //
 // Output: "&lt;script&gt;alert('XSS')&lt;/script&gt;"
+//
+// Instead of "on", an autoescape mode can be given as a string to escape the
+// block for another output format (see TemplateSet.SetAutoescapeMode):
+//
+//	{"name": "{% autoescape "json" %}{{ name }}{% endautoescape %}"}
 type tagAutoescapeNode struct {
 	wrapper    *NodeWrapper
 	autoescape bool
+	mode       bool   // a mode was given, filter replaces the current one
+	filter     string // the escape filter of the mode
 }
 
 // Execute renders the block content with the configured autoescape setting.
 // It temporarily changes the autoescape state in the context, executes the
 // wrapped content, and restores the original autoescape state afterward.
 func (node *tagAutoescapeNode) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
-	old := ctx.Autoescape
+	old, oldFilter := ctx.Autoescape, ctx.AutoescapeFilter
 	ctx.Autoescape = node.autoescape
+	if node.mode {
+		ctx.AutoescapeFilter = node.filter
+	}
 
 	err := node.wrapper.Execute(ctx, writer)
 	if err != nil {
 		return err
 	}
 
-	ctx.Autoescape = old
+	ctx.Autoescape, ctx.AutoescapeFilter = old, oldFilter
 
 	return nil
 }
 
 // tagAutoescapeParser parses the {% autoescape %} tag.
-// It expects a single argument "on" or "off" to control HTML escaping.
+// It expects a single argument "on" or "off" to control HTML escaping, or
+// the name of an autoescape mode as a string.
 func tagAutoescapeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error) {
 	autoescapeNode := &tagAutoescapeNode{}
 
@@ -56,6 +69,20 @@ func tagAutoescapeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag
 	}
 	autoescapeNode.wrapper = wrapper
 
+	if modeToken := arguments.MatchType(TokenString); modeToken != nil {
+		filter, has := doc.template.set.autoescapeModes[modeToken.Val]
+		if !has {
+			return nil, arguments.Error(fmt.Sprintf("Autoescape mode '%s' not found.", modeToken.Val), modeToken)
+		}
+		autoescapeNode.autoescape = true
+		autoescapeNode.mode = true
+		autoescapeNode.filter = filter
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Malformed autoescape-tag arguments.", nil)
+		}
+		return autoescapeNode, nil
+	}
+
 	modeToken := arguments.MatchType(TokenIdentifier)
 	if modeToken == nil {
 		return nil, arguments.Error("A mode is required for autoescape-tag.", nil)
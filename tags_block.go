@@ -41,7 +41,8 @@ import (
 //
 //	{% block sidebar %}...{% endblock sidebar %}
 type tagBlockNode struct {
-	name string
+	position *Token
+	name     string
 }
 
 // getBlockWrappers collects all block wrappers with the same name from the
@@ -85,6 +86,10 @@ func (node *tagBlockNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 	}
 	err := blockWrapper.Execute(ctx, writer)
 	if err != nil {
+		if lenBlockWrappers > 1 {
+			// The block is overridden by a child template
+			return addErrorFrame(err, newErrorFrame("block", node.name, tpl, node.position))
+		}
 		return err
 	}
 
@@ -168,7 +173,7 @@ func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, err
 		return nil, arguments.Error(fmt.Sprintf("Block named '%s' already defined", nameToken.Val), nil)
 	}
 
-	return &tagBlockNode{name: nameToken.Val}, nil
+	return &tagBlockNode{position: start, name: nameToken.Val}, nil
 }
 
 func init() {
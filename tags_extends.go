@@ -73,6 +73,7 @@ func tagExtendsParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, e
 		// Keep track of things
 		parentTemplate.child = doc.template
 		doc.template.parent = parentTemplate
+		doc.template.addDependency(parentTemplate)
 		extendsNode.filename = parentFilename
 	} else {
 		return nil, arguments.Error("Tag 'extends' requires a template filename as string.", nil)
@@ -6,6 +6,7 @@ import (
 
 // nodeFilterCall represents a single filter call with its name and optional parameter.
 type nodeFilterCall struct {
+	token     *Token
 	name      string
 	paramExpr IEvaluator
 }
@@ -105,13 +106,11 @@ func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, er
 	}
 
 	for arguments.Remaining() > 0 {
-		filterCall := &nodeFilterCall{}
-
 		nameToken := arguments.MatchType(TokenIdentifier)
 		if nameToken == nil {
 			return nil, arguments.Error("Expected a filter name (identifier).", nil)
 		}
-		filterCall.name = nameToken.Val
+		filterCall := &nodeFilterCall{token: nameToken, name: nameToken.Val}
 
 		if arguments.MatchOne(TokenSymbol, ":") != nil {
 			// Filter parameter
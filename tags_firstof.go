@@ -39,8 +39,8 @@ func (node *tagFirstofNode) Execute(ctx *ExecutionContext, writer TemplateWriter
 		}
 
 		if val.IsTrue() {
-			if ctx.Autoescape && !arg.FilterApplied("safe") {
-				val, err = ctx.template.set.ApplyFilter(ctx.template.Options.AutoescapeFilter, val, nil)
+			if ctx.Autoescape && ctx.AutoescapeFilter != "" && !arg.FilterApplied("safe") {
+				val, err = ctx.template.set.ApplyFilter(ctx.AutoescapeFilter, val, nil)
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,31 @@
+package pongo2
+
+// tagFlushNode represents the {% flush %} tag.
+//
+// The flush tag marks a point at which the output rendered so far is sent to
+// the client if the template is executed by Template.ExecuteStream:
+//
+//	{% include "header.html" %}
+//	{% flush %}
+//	{% for row in slow_report_rows %}...{% endfor %}
+//
+// With other Execute* methods, and inside tags which capture their content
+// (like filter, spaceless or macros), the tag is a no-op.
+type tagFlushNode struct{}
+
+// Execute flushes the output (see flushWriter).
+func (node *tagFlushNode) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
+	return flushWriter(writer)
+}
+
+// tagFlushParser parses the {% flush %} tag, which takes no arguments.
+func tagFlushParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error) {
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Tag 'flush' does not take any argument.", nil)
+	}
+	return &tagFlushNode{}, nil
+}
+
+func init() {
+	mustRegisterTag("flush", tagFlushParser)
+}
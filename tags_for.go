@@ -2,7 +2,8 @@ package pongo2
 
 // tagForNode represents the {% for %} tag.
 //
-// The for tag loops over each item in a sequence (slice, array, map, or string).
+// The for tag loops over each item in a sequence (slice, array, map, string
+// or iterator).
 // It provides loop variables through the special "forloop" object.
 //
 // Basic usage:
@@ -37,11 +38,14 @@ package pongo2
 //	    {{ key }}: {{ value }}
 //	{% endfor %}
 //
+// Iterators (iter.Seq, iter.Seq2 and Iterator) are streamed item by item
+// unless "reversed" or "sorted" is given.
+//
 // Loop variables available via forloop:
 //   - forloop.Counter: Current iteration (1-indexed)
 //   - forloop.Counter0: Current iteration (0-indexed)
-//   - forloop.Revcounter: Iterations remaining (1-indexed)
-//   - forloop.Revcounter0: Iterations remaining (0-indexed)
+//   - forloop.Revcounter: Iterations remaining (1-indexed, -1 for iterators)
+//   - forloop.Revcounter0: Iterations remaining (0-indexed, -1 for iterators)
 //   - forloop.First: True if this is the first iteration
 //   - forloop.Last: True if this is the last iteration
 //   - forloop.Parentloop: Access parent loop in nested loops
@@ -75,6 +79,16 @@ type tagForLoopInformation struct {
 	Parentloop  *tagForLoopInformation
 }
 
+// copy returns a deep copy of the loop information (including the enclosing
+// loops), e.g. for an include rendered in parallel while the loop goes on.
+func (info *tagForLoopInformation) copy() *tagForLoopInformation {
+	c := *info
+	if c.Parentloop != nil {
+		c.Parentloop = c.Parentloop.copy()
+	}
+	return &c
+}
+
 // Execute iterates over the object and renders the body for each item.
 // If the object is empty, it renders the empty wrapper (if present).
 func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (forError error) {
@@ -100,7 +114,12 @@ func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (f
 		return err
 	}
 
-	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
+	// Iterators are streamed unless they have to be reordered
+	if seq, ok := obj.sequence(); ok && !node.reversed && !node.sorted {
+		return node.executeSequence(forCtx, loopInfo, seq, writer)
+	}
+
+	obj.iterateOrder(forCtx, func(idx, count int, key, value *Value) bool {
 		// There's something to iterate over (correct type and at least 1 item)
 
 		// Update loop infos and public context
@@ -139,6 +158,61 @@ func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (f
 	return forError
 }
 
+// executeSequence renders the body for each item of an iterator without
+// collecting the items first. The iterator is read one item ahead to know
+// whether the current item is the last one; Revcounter and Revcounter0 are
+// -1, as the number of items is unknown.
+func (node *tagForNode) executeSequence(forCtx *ExecutionContext, loopInfo *tagForLoopInformation,
+	seq func(yield func(key, value *Value) bool) error, writer TemplateWriter) error {
+	var (
+		pendingKey, pendingValue *Value
+		pending                  bool
+		idx                      int
+		forError                 error
+	)
+
+	render := func(last bool) bool {
+		forCtx.Private[node.key] = pendingKey
+		if pendingValue != nil && node.value != "" {
+			forCtx.Private[node.value] = pendingValue
+		}
+		loopInfo.Counter = idx + 1
+		loopInfo.Counter0 = idx
+		loopInfo.First = idx == 0
+		loopInfo.Last = last
+		loopInfo.Revcounter = -1
+		loopInfo.Revcounter0 = -1
+		idx++
+
+		if err := node.bodyWrapper.Execute(forCtx, writer); err != nil {
+			forError = err
+			return false
+		}
+		return true
+	}
+
+	iterError := seq(func(key, value *Value) bool {
+		if pending && !render(false) {
+			return false
+		}
+		pendingKey, pendingValue, pending = key, value, true
+		return true
+	})
+	if forError != nil {
+		return forError
+	}
+	if iterError != nil {
+		return forCtx.OrigError(iterError, nil)
+	}
+
+	if pending {
+		render(true)
+	} else if node.emptyWrapper != nil {
+		forError = node.emptyWrapper.Execute(forCtx, writer)
+	}
+	return forError
+}
+
 // tagForParser parses the {% for %} tag. It supports key/value iteration,
 // "in" keyword, and optional "reversed" and "sorted" modifiers.
 func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error) {
@@ -186,11 +260,18 @@ func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error
 	}
 
 	// Body wrapping
+	branches := doc.htmlBranches(start)
 	wrapper, endargs, err := doc.WrapUntilTag("empty", "endfor")
 	if err != nil {
 		return nil, err
 	}
 	forNode.bodyWrapper = wrapper
+	if err := branches.loop(); err != nil {
+		return nil, err
+	}
+	if err := branches.branch(); err != nil {
+		return nil, err
+	}
 
 	if endargs.Count() > 0 {
 		return nil, endargs.Error("Arguments not allowed here.", nil)
@@ -203,12 +284,19 @@ func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error
 			return nil, err
 		}
 		forNode.emptyWrapper = wrapper
+		if err := branches.branch(); err != nil {
+			return nil, err
+		}
 
 		if endargs.Count() > 0 {
 			return nil, endargs.Error("Arguments not allowed here.", nil)
 		}
 	}
 
+	if err := branches.done(false); err != nil {
+		return nil, err
+	}
+
 	return forNode, nil
 }
 
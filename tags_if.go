@@ -91,12 +91,17 @@ func tagIfParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error)
 	}
 
 	// Check the rest
+	branches := doc.htmlBranches(start)
+	hasElse := false
 	for {
 		wrapper, tagArgs, err := doc.WrapUntilTag("elif", "elseif", "else", "endif")
 		if err != nil {
 			return nil, err
 		}
 		ifNode.wrappers = append(ifNode.wrappers, wrapper)
+		if err := branches.branch(); err != nil {
+			return nil, err
+		}
 
 		if wrapper.Endtag == "elif" || wrapper.Endtag == "elseif" {
 			// elif can take a condition
@@ -119,6 +124,11 @@ func tagIfParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error)
 		if wrapper.Endtag == "endif" {
 			break
 		}
+		hasElse = wrapper.Endtag == "else"
+	}
+
+	if err := branches.done(hasElse); err != nil {
+		return nil, err
 	}
 
 	return ifNode, nil
@@ -139,11 +139,15 @@ func tagIfchangedParser(doc *Parser, start *Token, arguments *Parser) (INodeTag,
 	}
 
 	// Wrap then/else-blocks
+	branches := doc.htmlBranches(start)
 	wrapper, endargs, err := doc.WrapUntilTag("else", "endifchanged")
 	if err != nil {
 		return nil, err
 	}
 	ifchangedNode.thenWrapper = wrapper
+	if err := branches.branch(); err != nil {
+		return nil, err
+	}
 
 	if endargs.Count() > 0 {
 		return nil, endargs.Error("Arguments not allowed here.", nil)
@@ -156,12 +160,19 @@ func tagIfchangedParser(doc *Parser, start *Token, arguments *Parser) (INodeTag,
 			return nil, err
 		}
 		ifchangedNode.elseWrapper = wrapper
+		if err := branches.branch(); err != nil {
+			return nil, err
+		}
 
 		if endargs.Count() > 0 {
 			return nil, endargs.Error("Arguments not allowed here.", nil)
 		}
 	}
 
+	if err := branches.done(ifchangedNode.elseWrapper != nil); err != nil {
+		return nil, err
+	}
+
 	return ifchangedNode, nil
 }
 
@@ -71,11 +71,15 @@ func tagIfEqualParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, e
 	}
 
 	// Wrap then/else-blocks
+	branches := doc.htmlBranches(start)
 	wrapper, endargs, err := doc.WrapUntilTag("else", "endifequal")
 	if err != nil {
 		return nil, err
 	}
 	ifequalNode.thenWrapper = wrapper
+	if err := branches.branch(); err != nil {
+		return nil, err
+	}
 
 	if endargs.Count() > 0 {
 		return nil, endargs.Error("Arguments not allowed here.", nil)
@@ -88,12 +92,19 @@ func tagIfEqualParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, e
 			return nil, err
 		}
 		ifequalNode.elseWrapper = wrapper
+		if err := branches.branch(); err != nil {
+			return nil, err
+		}
 
 		if endargs.Count() > 0 {
 			return nil, endargs.Error("Arguments not allowed here.", nil)
 		}
 	}
 
+	if err := branches.done(ifequalNode.elseWrapper != nil); err != nil {
+		return nil, err
+	}
+
 	return ifequalNode, nil
 }
 
@@ -71,11 +71,15 @@ func tagIfNotEqualParser(doc *Parser, start *Token, arguments *Parser) (INodeTag
 	}
 
 	// Wrap then/else-blocks
+	branches := doc.htmlBranches(start)
 	wrapper, endargs, err := doc.WrapUntilTag("else", "endifnotequal")
 	if err != nil {
 		return nil, err
 	}
 	ifnotequalNode.thenWrapper = wrapper
+	if err := branches.branch(); err != nil {
+		return nil, err
+	}
 
 	if endargs.Count() > 0 {
 		return nil, endargs.Error("Arguments not allowed here.", nil)
@@ -88,12 +92,19 @@ func tagIfNotEqualParser(doc *Parser, start *Token, arguments *Parser) (INodeTag
 			return nil, err
 		}
 		ifnotequalNode.elseWrapper = wrapper
+		if err := branches.branch(); err != nil {
+			return nil, err
+		}
 
 		if endargs.Count() > 0 {
 			return nil, endargs.Error("Arguments not allowed here.", nil)
 		}
 	}
 
+	if err := branches.done(ifnotequalNode.elseWrapper != nil); err != nil {
+		return nil, err
+	}
+
 	return ifnotequalNode, nil
 }
 
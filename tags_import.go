@@ -46,8 +46,8 @@ type tagImportNode struct {
 func (node *tagImportNode) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
 	for name, macro := range node.macros {
 		func(name string, macro *tagMacroNode) {
-			ctx.Private[name] = func(args ...*Value) (*Value, error) {
-				return macro.call(ctx, args...)
+			ctx.Private[name] = func(args *Args) (*Value, error) {
+				return macro.call(ctx, args)
 			}
 		}(name, macro)
 	}
@@ -78,6 +78,7 @@ func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, er
 	if err != nil {
 		return nil, updateErrorToken(err, doc.template, start)
 	}
+	doc.template.addDependency(tpl)
 
 	for arguments.Remaining() > 0 {
 		macroNameToken := arguments.MatchType(TokenIdentifier)
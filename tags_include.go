@@ -1,5 +1,7 @@
 package pongo2
 
+import "bytes"
+
 // tagIncludeNode represents the {% include %} tag.
 //
 // The include tag renders another template and inserts its output at the
@@ -32,9 +34,15 @@ package pongo2
 //
 //	{% include "card.html" with title="Hello" subtitle="World" only %}
 //
+// Rendering the included template concurrently with the rest of the page
+// (the "parallel" keyword must come before "with"):
+//
+//	{% include "widgets/weather.html" parallel with city=user.city %}
+//
 // Note: Static filenames (strings) are parsed at compile time for better
 // performance. Dynamic filenames are resolved at runtime.
 type tagIncludeNode struct {
+	position          *Token
 	tpl               *Template
 	filenameEvaluator IEvaluator
 	lazy              bool
@@ -42,6 +50,7 @@ type tagIncludeNode struct {
 	filename          string
 	withPairs         map[string]IEvaluator
 	ifExists          bool
+	parallel          bool
 }
 
 // Execute renders the included template with the appropriate context.
@@ -67,6 +76,7 @@ func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter
 	}
 
 	// Execute the template
+	tpl := node.tpl
 	if node.lazy {
 		// Evaluate the filename
 		filename, err := node.filenameEvaluator.Evaluate(ctx)
@@ -89,18 +99,45 @@ func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter
 			}
 			return err2
 		}
-		err2 = includedTpl.ExecuteWriter(includeCtx, writer)
-		if err2 != nil {
-			return err2
+		tpl = includedTpl
+	}
+
+	switch w := writer.(type) {
+	case *parallelWriter:
+		if node.parallel || w.all {
+			// Enclosing loops go on while the include is rendered, so it
+			// gets a snapshot of their loop information
+			for key, value := range includeCtx {
+				if info, ok := value.(*tagForLoopInformation); ok {
+					includeCtx[key] = info.copy()
+				}
+			}
+			w.spawn(func(writer TemplateWriter) error {
+				return node.frame(ctx, tpl, tpl.executeIn(ctx, includeCtx, writer))
+			})
+			return nil
 		}
-		return nil
+		return node.frame(ctx, tpl, tpl.executeIn(ctx, includeCtx, writer))
+	case parallelBuffer, *streamWriter:
+		// Streamed includes aren't buffered, so they can flush
+		return node.frame(ctx, tpl, tpl.executeIn(ctx, includeCtx, writer))
 	}
-	// Template is already parsed with static filename
-	err := node.tpl.ExecuteWriter(includeCtx, writer)
-	if err != nil {
-		return err
+
+	// Buffer the output, so nothing is written if the include fails
+	var buf bytes.Buffer
+	if err := tpl.executeIn(ctx, includeCtx, &buf); err != nil {
+		return node.frame(ctx, tpl, err)
 	}
-	return nil
+	_, err := buf.WriteTo(writer)
+	return err
+}
+
+// frame adds the include of tpl to the stack of err (if not nil).
+func (node *tagIncludeNode) frame(ctx *ExecutionContext, tpl *Template, err error) error {
+	if err == nil {
+		return nil
+	}
+	return addErrorFrame(err, newErrorFrame("include", tpl.name, ctx.template, node.position))
 }
 
 // tagIncludeEmptyNode is a placeholder node returned when a static include
@@ -113,9 +150,11 @@ func (node *tagIncludeEmptyNode) Execute(ctx *ExecutionContext, writer TemplateW
 }
 
 // tagIncludeParser parses the {% include %} tag. It supports static or dynamic
-// filenames, "if_exists" flag, "with" context pairs, and "only" isolation.
+// filenames, "if_exists" flag, "parallel" flag, "with" context pairs, and
+// "only" isolation.
 func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error) {
 	includeNode := &tagIncludeNode{
+		position:  start,
 		withPairs: make(map[string]IEvaluator),
 	}
 
@@ -139,6 +178,7 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, e
 			return nil, updateErrorToken(err, doc.template, filenameToken)
 		}
 		includeNode.tpl = includedTpl
+		doc.template.addDependency(includedTpl)
 	} else {
 		// No String, then the user wants to use lazy-evaluation (slower, but possible)
 		filenameEvaluator, err := arguments.ParseExpression()
@@ -150,6 +190,12 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, e
 		includeNode.ifExists = arguments.Match(TokenIdentifier, "if_exists") != nil // "if_exists" flag
 	}
 
+	// "parallel" flag
+	if arguments.Match(TokenIdentifier, "parallel") != nil {
+		includeNode.parallel = true
+		doc.template.parallelIncludes = true
+	}
+
 	// After having parsed the filename we're gonna parse the with+only options
 	if arguments.Match(TokenIdentifier, "with") != nil {
 		for arguments.Remaining() > 0 {
@@ -3,6 +3,8 @@ package pongo2
 import (
 	"bytes"
 	"fmt"
+	"maps"
+	"slices"
 )
 
 // maxMacroDepth limits the maximum depth of recursive macro calls.
@@ -67,27 +69,29 @@ type tagMacroNode struct {
 }
 
 // Execute registers the macro as a callable function in the private context.
-// The macro can then be called like {{ macro_name(args) }}.
+// The macro can then be called like {{ macro_name(args) }}; the function takes
+// *Args so that arguments can also be passed by keyword.
 func (node *tagMacroNode) Execute(ctx *ExecutionContext, writer TemplateWriter) error {
-	ctx.Private[node.name] = func(args ...*Value) (*Value, error) {
-		ctx.macroDepth++
-		defer func() {
-			ctx.macroDepth--
-		}()
+	ctx.Private[node.name] = func(args *Args) (*Value, error) {
+		depth := ctx.macroDepth.Add(1)
+		defer ctx.macroDepth.Add(-1)
 
-		if ctx.macroDepth > maxMacroDepth {
+		if depth > maxMacroDepth {
 			return nil, ctx.Error(fmt.Sprintf("maximum recursive macro call depth reached (max is %v)", maxMacroDepth), node.position)
 		}
 
-		return node.call(ctx, args...)
+		return node.call(ctx, args)
 	}
 
 	return nil
 }
 
-// call executes the macro body with the provided arguments and returns the
-// rendered output as a safe value. It creates an isolated context for execution.
-func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, error) {
+// call executes the macro body with the provided positional and keyword
+// arguments and returns the rendered output as a safe value. It creates an
+// isolated context for execution.
+func (node *tagMacroNode) call(ctx *ExecutionContext, arguments *Args) (*Value, error) {
+	args := arguments.Values()
+
 	argsCtx := make(Context)
 
 	for k, v := range node.args {
@@ -114,6 +118,16 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 		return AsSafeValue(""), err
 	}
 
+	for _, name := range slices.Sorted(maps.Keys(arguments.Map())) {
+		idx := slices.Index(node.argsOrder, name)
+		if idx < 0 {
+			return AsSafeValue(""), ctx.Error(fmt.Sprintf("Macro '%s' has no argument named '%s'.", node.name, name), node.position)
+		}
+		if idx < len(args) {
+			return AsSafeValue(""), ctx.Error(fmt.Sprintf("Macro '%s' got multiple values for argument '%s'.", node.name, name), node.position)
+		}
+	}
+
 	// Make a context for the macro execution
 	macroCtx := NewChildExecutionContext(ctx)
 
@@ -123,11 +137,15 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) (*Value, e
 	for idx, argValue := range args {
 		macroCtx.Private[node.argsOrder[idx]] = argValue.Interface()
 	}
+	for name, argValue := range arguments.Map() {
+		macroCtx.Private[name] = argValue.Interface()
+	}
 
 	var b bytes.Buffer
 	err := node.wrapper.Execute(macroCtx, &b)
 	if err != nil {
-		return AsSafeValue(""), updateErrorToken(err, ctx.template, node.position)
+		err = updateErrorToken(err, ctx.template, node.position)
+		return AsSafeValue(""), addErrorFrame(err, newErrorFrame("macro", node.name, ctx.template, node.position))
 	}
 
 	return AsSafeValue(b.String()), nil
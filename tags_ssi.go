@@ -1,7 +1,5 @@
 package pongo2
 
-import "io"
-
 // tagSSINode represents the {% ssi %} tag.
 //
 // DEPRECATED: This tag was removed from Django in version 1.10.
@@ -41,7 +39,7 @@ func (node *tagSSINode) Execute(ctx *ExecutionContext, writer TemplateWriter) er
 		includeCtx.Update(ctx.Public)
 		includeCtx.Update(ctx.Private)
 
-		err := node.template.execute(includeCtx, writer)
+		err := node.template.executeIn(ctx, includeCtx, writer)
 		if err != nil {
 			return err
 		}
@@ -68,28 +66,19 @@ func tagSSIParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, error
 				return nil, updateErrorToken(err, doc.template, fileToken)
 			}
 			SSINode.template = temporaryTpl
+			doc.template.addDependency(temporaryTpl)
 		} else {
 			// plaintext - use the template loader to support virtual filesystems
-			_, _, fd, err := doc.template.set.resolveTemplate(doc.template, fileToken.Val)
-			if err != nil {
-				return nil, updateErrorToken(&Error{
-					Sender:    "tag:ssi",
-					OrigError: err,
-				}, doc.template, fileToken)
-			}
-			buf, err := io.ReadAll(fd)
-			if closer, ok := fd.(io.Closer); ok {
-				if closeErr := closer.Close(); closeErr != nil && err == nil {
-					err = closeErr
-				}
-			}
+			src, buf, err := doc.template.set.loadSource(doc.template, fileToken.Val)
 			if err != nil {
 				return nil, updateErrorToken(&Error{
 					Sender:    "tag:ssi",
 					OrigError: err,
+					Code:      loadErrorCode(err),
 				}, doc.template, fileToken)
 			}
 			SSINode.content = string(buf)
+			doc.template.addFile(src)
 		}
 	} else {
 		return nil, arguments.Error("First argument must be a string.", nil)
@@ -1,8 +1,15 @@
 package pongo2
 
 import (
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 func TestReplaceTag(t *testing.T) {
@@ -669,6 +676,138 @@ func TestForLoopVariables(t *testing.T) {
 	}
 }
 
+// sliceIterator is a pull-style Iterator over a slice.
+type sliceIterator struct {
+	items []any
+	err   error
+}
+
+func (it *sliceIterator) Next() (any, bool) {
+	if len(it.items) == 0 {
+		return nil, false
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return item, true
+}
+
+func (it *sliceIterator) Err() error {
+	return it.err
+}
+
+func TestForLoopIterators(t *testing.T) {
+	seq := func(n int) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			for i := 1; i <= n; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+	seq2 := func(yield func(string, int) bool) {
+		_ = yield("a", 1) && yield("b", 2)
+	}
+	anySeq := iter.Seq[any](func(yield func(any) bool) {
+		_ = yield("x") && yield(2)
+	})
+
+	tests := []struct {
+		name     string
+		template string
+		context  Context
+		expected string
+		errMsg   string
+	}{
+		{
+			name:     "iter.Seq",
+			template: "{% for i in items %}{{ i }}{% endfor %}",
+			context:  Context{"items": seq(3)},
+			expected: "123",
+		},
+		{
+			name:     "iter.Seq[any]",
+			template: "{% for i in items %}{{ i }}{% endfor %}",
+			context:  Context{"items": anySeq},
+			expected: "x2",
+		},
+		{
+			name:     "iter.Seq2",
+			template: "{% for k, v in items %}{{ k }}={{ v }};{% endfor %}",
+			context:  Context{"items": iter.Seq2[string, int](seq2)},
+			expected: "a=1;b=2;",
+		},
+		{
+			name:     "forloop with lookahead",
+			template: "{% for i in items %}{{ forloop.Counter }}{% if forloop.First %}F{% endif %}{% if forloop.Last %}L{% endif %}{{ forloop.Revcounter }} {% endfor %}",
+			context:  Context{"items": seq(3)},
+			expected: "1F-1 2-1 3L-1 ",
+		},
+		{
+			name:     "empty",
+			template: "{% for i in items %}{{ i }}{% empty %}empty{% endfor %}",
+			context:  Context{"items": seq(0)},
+			expected: "empty",
+		},
+		{
+			name:     "reversed",
+			template: "{% for i in items reversed %}{{ i }}{{ forloop.Revcounter }} {% endfor %}",
+			context:  Context{"items": seq(3)},
+			expected: "33 22 11 ",
+		},
+		{
+			name:     "pull iterator",
+			template: "{% for i in items %}{{ i }}{% if not forloop.Last %},{% endif %}{% endfor %}",
+			context:  Context{"items": &sliceIterator{items: []any{"a", 1, true}}},
+			expected: "a,1,True",
+		},
+		{
+			name:     "pull iterator error",
+			template: "{% for i in items %}{{ i }}{% endfor %}",
+			context:  Context{"items": &sliceIterator{items: []any{"a"}, err: errors.New("cursor closed")}},
+			errMsg:   "cursor closed",
+		},
+		{
+			name:     "body error stops the iterator",
+			template: "{% for i in items %}{{ fail(i) }}{% endfor %}",
+			context: Context{
+				"items": seq(1000),
+				"fail": func(i int) (int, error) {
+					if i == 2 {
+						return 0, errors.New("failed at 2")
+					}
+					return i, nil
+				},
+			},
+			errMsg: "failed at 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := FromString(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to parse template: %v", err)
+			}
+
+			result, err := tpl.Execute(tt.context)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to execute template: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Got %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestComplexExpressions(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -820,3 +959,181 @@ func TestTagSSI(t *testing.T) {
 		}
 	})
 }
+
+func TestTagIncludeParallel(t *testing.T) {
+	templates := map[string]string{
+		"page.html":   `<{% include "widget.html" parallel with n=1 %}|{% include "widget.html" parallel with n=2 %}|{% include "widget.html" parallel with n=3 %}>`,
+		"all.html":    `<{% include "widget.html" with n=1 %}|{% include "widget.html" with n=2 %}|{% include "widget.html" with n=3 %}>`,
+		"widget.html": `[{{ work(n) }}]`,
+		"nested.html": `({% include "page.html" parallel %})`,
+		"filter.html": `{% filter upper %}{% include "plain.html" parallel %}{% endfilter %}`,
+		"plain.html":  `widget`,
+		"extends.html": `{% extends "base.html" %}{% block content %}` +
+			`{% include "widget.html" parallel with n=1 %}{% include "widget.html" parallel with n=2 %}{% endblock %}`,
+		"base.html": `<{% block content %}{% endblock %}>`,
+	}
+
+	// barrier returns a work function which blocks until n calls are
+	// running concurrently (or a timeout expired).
+	barrier := func(n int32) func(int) string {
+		var running atomic.Int32
+		return func(i int) string {
+			running.Add(1)
+			deadline := time.Now().Add(2 * time.Second)
+			for running.Load() < n {
+				if time.Now().After(deadline) {
+					return "timeout"
+				}
+				time.Sleep(time.Millisecond)
+			}
+			return strconv.Itoa(i)
+		}
+	}
+
+	t.Run("concurrent and in order", func(t *testing.T) {
+		set := NewSet("test-parallel", NewMapLoader(templates))
+		result, err := set.RenderTemplateFile("page.html", Context{"work": barrier(3)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "<[1]|[2]|[3]>" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("all includes", func(t *testing.T) {
+		set := NewSet("test-parallel-all", NewMapLoader(templates))
+		set.Options.ParallelIncludes = true
+		result, err := set.RenderTemplateFile("all.html", Context{"work": barrier(3)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "<[1]|[2]|[3]>" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("extends", func(t *testing.T) {
+		set := NewSet("test-parallel-extends", NewMapLoader(templates))
+		result, err := set.RenderTemplateFile("extends.html", Context{"work": barrier(2)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "<[1][2]>" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		set := NewSet("test-parallel-limit", NewMapLoader(templates))
+		set.Options.ParallelIncludesLimit = 2
+		var running, maxRunning atomic.Int32
+		work := func(i int) string {
+			cur := running.Add(1)
+			defer running.Add(-1)
+			for {
+				prev := maxRunning.Load()
+				if cur <= prev || maxRunning.CompareAndSwap(prev, cur) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return strconv.Itoa(i)
+		}
+		result, err := set.RenderTemplateFile("page.html", Context{"work": work})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "<[1]|[2]|[3]>" {
+			t.Errorf("got %q", result)
+		}
+		if maxRunning.Load() > 2 {
+			t.Errorf("%d includes rendered concurrently, limit is 2", maxRunning.Load())
+		}
+	})
+
+	t.Run("errors in document order", func(t *testing.T) {
+		set := NewSet("test-parallel-errors", NewMapLoader(templates))
+		work := func(i int) (string, error) {
+			// The first widget fails last
+			time.Sleep(time.Duration(4-i) * 10 * time.Millisecond)
+			if i == 3 {
+				return "", nil
+			}
+			return "", fmt.Errorf("widget %d failed", i)
+		}
+		_, err := set.RenderTemplateFile("page.html", Context{"work": work})
+		if err == nil || !strings.Contains(err.Error(), "widget 1 failed") {
+			t.Fatalf("expected error of widget 1, got %v", err)
+		}
+	})
+
+	t.Run("panics are re-raised", func(t *testing.T) {
+		set := NewSet("test-parallel-panic", NewMapLoader(templates))
+		work := func(i int) string {
+			if i == 2 {
+				panic("widget 2 panicked")
+			}
+			return strconv.Itoa(i)
+		}
+		defer func() {
+			if r := recover(); r != "widget 2 panicked" {
+				t.Errorf("expected panic of widget 2, got %v", r)
+			}
+		}()
+		_, _ = set.RenderTemplateFile("page.html", Context{"work": work})
+		t.Error("expected a panic")
+	})
+
+	t.Run("nested includes are sequential", func(t *testing.T) {
+		set := NewSet("test-parallel-nested", NewMapLoader(templates))
+		set.Options.ParallelIncludesLimit = 1
+		work := func(i int) string { return strconv.Itoa(i) }
+		result, err := set.RenderTemplateFile("nested.html", Context{"work": work})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "(<[1]|[2]|[3]>)" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("inside filter tag", func(t *testing.T) {
+		set := NewSet("test-parallel-filter", NewMapLoader(templates))
+		result, err := set.RenderTemplateFile("filter.html", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result != "WIDGET" {
+			t.Errorf("got %q", result)
+		}
+	})
+
+	t.Run("inside for loops", func(t *testing.T) {
+		// The loops go on while the includes are rendered (run with -race)
+		set := NewSet("test-parallel-loop", NewMapLoader(map[string]string{
+			"loop.html": `{% macro m() %}m{% endmacro %}{% for o in outer %}{% for i in inner %}` +
+				`{% include "item.html" parallel %}{{ m() }};{% endfor %}{% endfor %}`,
+			"item.html": `{{ wait() }}{{ forloop.Parentloop.Counter }}.{{ forloop.Counter }}:{{ forloop.Counter0 }}{{ m() }}`,
+		}))
+		wait := func() string {
+			time.Sleep(5 * time.Millisecond)
+			return ""
+		}
+		result, err := set.RenderTemplateFile("loop.html", Context{"outer": []int{1, 2}, "inner": []int{1, 2, 3}, "wait": wait})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := "1.1:0mm;1.2:1mm;1.3:2mm;2.1:0mm;2.2:1mm;2.3:2mm;"; result != expected {
+			t.Errorf("got %q, want %q", result, expected)
+		}
+	})
+
+	t.Run("parallel after with", func(t *testing.T) {
+		set := NewSet("test-parallel-syntax", NewMapLoader(templates))
+		_, err := set.FromString(`{% include "plain.html" with n=1 parallel %}`)
+		if err == nil {
+			t.Fatal("expected a parse error")
+		}
+	})
+}
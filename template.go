@@ -2,9 +2,12 @@ package pongo2
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"strings"
+	"sync"
 )
 
 // TemplateWriter is the interface used for writing template output.
@@ -64,6 +67,11 @@ type Template struct {
 	// output buffer sizes (templates typically expand ~30% during rendering).
 	size int
 
+	// code is the template source. It's kept for error reports (see
+	// Error.Excerpt), which work for string templates this way, too. The
+	// tokens refer to it anyway.
+	code string
+
 	// Template inheritance fields: These fields implement Django-style template
 	// inheritance. Child templates can extend parent templates and override
 	// specific blocks. The inheritance chain is resolved at execution time by
@@ -112,7 +120,61 @@ type Template struct {
 	// Options allow you to change the behavior of template-engine.
 	// You can change the options before calling the Execute method.
 	// Includes settings like TrimBlocks and LStripBlocks for whitespace control.
+	// Changing options which are applied at compile time (TrimBlocks,
+	// LStripBlocks, ContextualAutoescape) recompiles the template on its next
+	// execution. Options must not be changed while the template is executed.
 	Options *Options
+
+	// compiledWith are the compile-time options the template was compiled
+	// with, compileMu serializes the check for changes and the recompilation.
+	compiledWith compileOptions
+	compileMu    sync.Mutex
+
+	// --- Versioning fields (used for Version, Fingerprint and AutoReload) ---
+
+	// source describes where this template was loaded from and which version
+	// it had. For string templates, loader is nil and the version is a hash
+	// of the template's content.
+	source *templateSource
+
+	// dependencies are the templates this template was compiled with, i.e.
+	// its parent ({% extends %}), static {% include %}s, {% import %}s and
+	// parsed {% ssi %}s. Dynamic includes are resolved at execution time
+	// and therefore not part of this list.
+	dependencies []*Template
+
+	// files are plain (non-template) files read during compilation, like
+	// the ones of {% ssi %} without "parsed".
+	files []*templateSource
+
+	// parallelIncludes is true if this template or one of its dependencies
+	// contains an {% include ... parallel %}.
+	parallelIncludes bool
+}
+
+// templateSource records where a template (or a plain file) was loaded from.
+type templateSource struct {
+	loader  TemplateLoader
+	path    string
+	version string
+}
+
+// isStale returns true if the source's loader reports a different version
+// than the one recorded. Sources without versioning support are never stale.
+func (src *templateSource) isStale() bool {
+	versioner, ok := src.loader.(TemplateVersioner)
+	if !ok {
+		return false
+	}
+	version, err := versioner.Version(src.path)
+	return err != nil || version != src.version
+}
+
+// contentVersion returns a version string derived from the content itself.
+func contentVersion(buf []byte) string {
+	h := fnv.New128a()
+	h.Write(buf)
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // newTemplateString creates a new template from a byte slice containing template source.
@@ -135,6 +197,15 @@ func newTemplateString(set *TemplateSet, tpl []byte) (*Template, error) {
 //   - isTplString: true if created from string, false if from file
 //   - tpl: The raw template source bytes
 func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*Template, error) {
+	return newTemplateWithSource(set, name, isTplString, &templateSource{
+		path:    name,
+		version: contentVersion(tpl),
+	}, tpl)
+}
+
+// newTemplateWithSource works like newTemplate, but records the given source
+// (the loader, resolved path and version the template was loaded with).
+func newTemplateWithSource(set *TemplateSet, name string, isTplString bool, src *templateSource, tpl []byte) (*Template, error) {
 	strTpl := string(tpl)
 
 	// Mark that a template has been created (prevents further tag/filter banning)
@@ -149,16 +220,35 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 		isTplString:    isTplString,
 		name:           name,
 		size:           len(strTpl),
+		code:           strTpl,
 		blocks:         make(map[string]*NodeWrapper),
 		exportedMacros: make(map[string]*tagMacroNode),
 		Options:        newOptions(),
+		source:         src,
 	}
 	// Copy all settings from another Options.
 	t.Options.Update(set.Options)
+	if !isTplString {
+		if mode, ok := set.autoescapeModeFor(name, src); ok {
+			t.Options.AutoescapeFilter = set.autoescapeModes[mode]
+			// Contextual autoescaping only applies to HTML, i.e. modes
+			// escaping with the HTML escape filter
+			t.Options.ContextualAutoescape = t.Options.ContextualAutoescape && t.Options.AutoescapeFilter == "escape"
+		}
+	}
 
 	// Tokenize it
 	tokens, err := lex(name, strTpl)
 	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Template = t
+		}
+		if t.Options.CollectErrors {
+			// The lexer can't recover, but the error type is the same
+			errs := &ErrorList{}
+			errs.add(err)
+			return nil, errs
+		}
 		return nil, err
 	}
 	t.tokens = tokens
@@ -172,9 +262,146 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 	return t, nil
 }
 
+// recompile compiles the template again if the options applied at compile
+// time have been changed since it was compiled. If the recompilation fails,
+// the template stays as it was and the error is returned.
+func (tpl *Template) recompile() error {
+	tpl.compileMu.Lock()
+	defer tpl.compileMu.Unlock()
+
+	if tpl.compiledWith == tpl.Options.compileOptions() {
+		return nil
+	}
+
+	// Reset everything the parser sets up
+	compiledWith, level, parent, root := tpl.compiledWith, tpl.level, tpl.parent, tpl.root
+	blocks, exportedMacros := tpl.blocks, tpl.exportedMacros
+	dependencies, files, parallelIncludes := tpl.dependencies, tpl.files, tpl.parallelIncludes
+	tpl.level, tpl.parent, tpl.root = 0, nil, nil
+	tpl.blocks = make(map[string]*NodeWrapper)
+	tpl.exportedMacros = make(map[string]*tagMacroNode)
+	tpl.dependencies, tpl.files, tpl.parallelIncludes = nil, nil, false
+
+	if err := tpl.parse(); err != nil {
+		tpl.compiledWith, tpl.level, tpl.parent, tpl.root = compiledWith, level, parent, root
+		tpl.blocks, tpl.exportedMacros = blocks, exportedMacros
+		tpl.dependencies, tpl.files, tpl.parallelIncludes = dependencies, files, parallelIncludes
+		return err
+	}
+	return nil
+}
+
+// addDependency records a template this template has been compiled with.
+func (tpl *Template) addDependency(dep *Template) {
+	tpl.dependencies = append(tpl.dependencies, dep)
+	if dep.parallelIncludes {
+		tpl.parallelIncludes = true
+	}
+}
+
+// addFile records a plain file read during this template's compilation.
+func (tpl *Template) addFile(src *templateSource) {
+	tpl.files = append(tpl.files, src)
+}
+
+// sourceCode returns the source of the template named filename, which is
+// either this template or one of its dependencies.
+func (tpl *Template) sourceCode(filename string) (string, bool) {
+	visited := make(map[*Template]bool)
+	var find func(t *Template) (string, bool)
+	find = func(t *Template) (string, bool) {
+		if visited[t] {
+			return "", false
+		}
+		visited[t] = true
+		if t.name == filename {
+			return t.code, true
+		}
+		for _, dep := range t.dependencies {
+			if code, ok := find(dep); ok {
+				return code, true
+			}
+		}
+		return "", false
+	}
+	return find(tpl)
+}
+
+// walkSources calls fn for the sources of this template and all of its
+// dependencies (each one only once) until fn returns false.
+func (tpl *Template) walkSources(fn func(src *templateSource) bool) {
+	visited := make(map[*Template]bool)
+	var walk func(t *Template) bool
+	walk = func(t *Template) bool {
+		if visited[t] {
+			return true
+		}
+		visited[t] = true
+		if t.source != nil && !fn(t.source) {
+			return false
+		}
+		for _, f := range t.files {
+			if !fn(f) {
+				return false
+			}
+		}
+		for _, dep := range t.dependencies {
+			if !walk(dep) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(tpl)
+}
+
+// isStale returns true if this template or any of its dependencies changed
+// since it was compiled (as far as the loaders are able to tell).
+func (tpl *Template) isStale() bool {
+	stale := false
+	tpl.walkSources(func(src *templateSource) bool {
+		stale = src.isStale()
+		return !stale
+	})
+	return stale
+}
+
+// Name returns the name of the template: the (resolved) filename for
+// templates loaded from a file and "<string>" for string templates.
+func (tpl *Template) Name() string {
+	return tpl.name
+}
+
+// Version returns the version of this template's source as reported by its
+// loader (if it implements TemplateVersioner) or a hash of its content
+// otherwise. It does not cover parents or included templates, see Fingerprint.
+func (tpl *Template) Version() string {
+	if tpl.source == nil {
+		return ""
+	}
+	return tpl.source.version
+}
+
+// Fingerprint returns a hash combining the versions of this template and of
+// all templates it was compiled with (its inheritance chain, static includes,
+// imports and ssi files). It changes whenever any of them changes and is
+// suitable to build HTTP ETags from (together with a hash of the context data).
+// Templates included dynamically ({% include some_var %}) are not covered.
+func (tpl *Template) Fingerprint() string {
+	h := fnv.New128a()
+	tpl.walkSources(func(src *templateSource) bool {
+		h.Write([]byte(src.path))
+		h.Write([]byte{0})
+		h.Write([]byte(src.version))
+		h.Write([]byte{0})
+		return true
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // newContextForExecution prepares the template and context for execution.
 // It performs several tasks:
-//  1. Applies TrimBlocks/LStripBlocks whitespace options to tokens
+//  1. Recompiles the templates whose compile-time options changed
 //  2. Walks up the inheritance chain to find the root parent template
 //  3. Merges global variables with the provided context
 //  4. Validates context keys are valid identifiers
@@ -182,31 +409,10 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 //
 // Returns the root parent template to execute, the execution context, and any error.
 func (tpl *Template) newContextForExecution(context Context) (*Template, *ExecutionContext, error) {
-	if tpl.Options.TrimBlocks || tpl.Options.LStripBlocks {
-		// Issue #94 https://github.com/flosch/pongo2/issues/94
-		// If an application configures pongo2 template to trim_blocks,
-		// the first newline after a template tag is removed automatically (like in PHP).
-		prev := &Token{
-			Typ: TokenHTML,
-			Val: "\n",
-		}
-
-		for _, t := range tpl.tokens {
-			if tpl.Options.LStripBlocks {
-				if prev.Typ == TokenHTML && t.Typ != TokenHTML && t.Val == "{%" {
-					prev.Val = strings.TrimRight(prev.Val, "\t ")
-				}
-			}
-
-			if tpl.Options.TrimBlocks {
-				if prev.Typ != TokenHTML && t.Typ == TokenHTML && prev.Val == "%}" {
-					if len(t.Val) > 0 && t.Val[0] == '\n' {
-						t.Val = t.Val[1:len(t.Val)]
-					}
-				}
-			}
-
-			prev = t
+	// Recompile the template (and its parents) if options changed
+	for t := tpl; t != nil; t = t.parent {
+		if err := t.recompile(); err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -355,17 +561,41 @@ func (tpl *Template) Evaluate(context Context) (interface{}, error) {
 // It prepares the execution context and runs the root document node's Execute method.
 // This is the core execution path used by all public Execute* methods.
 func (tpl *Template) execute(context Context, writer TemplateWriter) error {
+	return tpl.executeIn(nil, context, writer)
+}
+
+// executeIn executes the template as part of the render outer belongs to
+// (e.g. for {% include %}), so render-wide state like memoized Lazy values is
+// shared. outer is nil for top-level executions.
+func (tpl *Template) executeIn(outer *ExecutionContext, context Context, writer TemplateWriter) error {
 	parent, ctx, err := tpl.newContextForExecution(context)
 	if err != nil {
 		return err
 	}
+	if outer != nil {
+		ctx.lazies = outer.lazies
+	}
 
-	// Run the selected document
-	if err := parent.root.Execute(ctx, writer); err != nil {
-		return err
+	// Top-level blocks are only flushed by the outermost execution
+	var flushBlocks bool
+	if sw, ok := writer.(*streamWriter); ok && outer == nil {
+		flushBlocks = sw.flushBlocks
 	}
 
-	return nil
+	// Includes may only be rendered in parallel by the outermost execution;
+	// templates included by it write into its parallelWriter (or, if they
+	// are rendered in parallel themselves, into a parallelBuffer).
+	switch writer.(type) {
+	case *parallelWriter, parallelBuffer:
+	default:
+		if tpl.Options.ParallelIncludes || tpl.parallelIncludes {
+			pw := newParallelWriter(writer, tpl.Options.ParallelIncludes, tpl.Options.ParallelIncludesLimit)
+			return pw.finish(executeDocument(parent.root, ctx, pw, flushBlocks))
+		}
+	}
+
+	// Run the selected document
+	return executeDocument(parent.root, ctx, writer, flushBlocks)
 }
 
 // newTemplateWriterAndExecute wraps an io.Writer in a templateWriter and executes.
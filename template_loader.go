@@ -2,17 +2,26 @@ package pongo2
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 )
 
 // FSLoader supports the fs.FS interface for loading templates
 type FSLoader struct {
 	fs fs.FS
+
+	// contentVersions caches the versions of files without modification
+	// times (path -> version)
+	contentVersions sync.Map
 }
 
 // NewFSLoader creates a new template loader that reads templates from an fs.FS
@@ -32,6 +41,89 @@ func (l *FSLoader) Get(path string) (io.Reader, error) {
 	return l.fs.Open(path)
 }
 
+// Version returns the template's modification time and size. File systems
+// without modification times (like embed.FS) are versioned by content; as
+// their files can't change, the content is only hashed once per file.
+func (l *FSLoader) Version(path string) (string, error) {
+	fi, err := fs.Stat(l.fs, path)
+	if err != nil {
+		return "", err
+	}
+	if fi.ModTime().IsZero() {
+		if version, ok := l.contentVersions.Load(path); ok {
+			return version.(string), nil
+		}
+		buf, err := fs.ReadFile(l.fs, path)
+		if err != nil {
+			return "", err
+		}
+		version := contentVersion(buf)
+		l.contentVersions.Store(path, version)
+		return version, nil
+	}
+	return fileInfoVersion(fi), nil
+}
+
+// List returns all files of the file system starting with prefix.
+func (l *FSLoader) List(prefix string) ([]string, error) {
+	return listFS(l.fs, prefix)
+}
+
+// listFS walks fsys and returns the names of all files starting with prefix.
+// Only the directory containing the prefix is walked. Symlinks are listed if
+// they point to a file that can be opened through fsys.
+func listFS(fsys fs.FS, prefix string) ([]string, error) {
+	root := "."
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		root = prefix[:i]
+	}
+
+	var names []string
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			fi, err := fs.Stat(fsys, name)
+			if err != nil || fi.IsDir() {
+				return nil
+			}
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// fileInfoVersion builds a version string from a file's modification time and size.
+func fileInfoVersion(fi fs.FileInfo) string {
+	return fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size())
+}
+
+// readerVersion reads r completely and returns a version derived from its
+// content. r is closed if it implements io.Closer.
+func readerVersion(r io.Reader) (string, error) {
+	buf, err := io.ReadAll(r)
+	if closer, ok := r.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return contentVersion(buf), nil
+}
+
 // LocalFilesystemLoader represents a local filesystem loader with basic
 // BaseDirectory capabilities. The access to the local filesystem is unrestricted.
 type LocalFilesystemLoader struct {
@@ -99,6 +191,30 @@ func (fs *LocalFilesystemLoader) Get(path string) (io.Reader, error) {
 	return bytes.NewReader(buf), nil
 }
 
+// Version returns the file's modification time and size.
+func (fs *LocalFilesystemLoader) Version(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fileInfoVersion(fi), nil
+}
+
+// List returns the names of all files within the base directory (or the
+// current working directory if none is set) starting with prefix. Names are
+// relative to that directory and use forward slashes.
+func (fs *LocalFilesystemLoader) List(prefix string) ([]string, error) {
+	dir := fs.baseDir
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return listFS(os.DirFS(dir), prefix)
+}
+
 // Abs resolves a filename relative to the base directory. Absolute paths are allowed.
 // When there's no base dir set, the absolute path to the filename
 // will be calculated based on either the provided base directory (which
@@ -126,3 +242,358 @@ func (fs *LocalFilesystemLoader) Abs(base, name string) string {
 
 	return filepath.Join(fs.baseDir, name)
 }
+
+// relativeName returns path relative to the base directory (or the current
+// working directory if none is set), or path itself if it's outside of it.
+func (fs *LocalFilesystemLoader) relativeName(path string) string {
+	dir := fs.baseDir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return path
+		}
+	}
+	return relativeToDir(dir, path)
+}
+
+// relativeToDir returns path relative to dir using forward slashes, or path
+// itself if it's outside of dir.
+func relativeToDir(dir, path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// JailedFilesystemLoader loads templates from the local filesystem, but
+// confines every access to its base directory. Absolute paths outside of
+// the base directory, relative paths escaping it using "..", and symlinks
+// pointing outside of it are rejected. This applies to all paths passed
+// through the loader, including the ones of dynamic {% include %} expressions
+// and the {% ssi %} tag.
+type JailedFilesystemLoader struct {
+	baseDir string
+}
+
+// MustNewJailedFileSystemLoader creates a new JailedFilesystemLoader instance
+// and panics if there's any error during instantiation. The parameters
+// are the same like NewJailedFileSystemLoader.
+func MustNewJailedFileSystemLoader(baseDir string) *JailedFilesystemLoader {
+	fs, err := NewJailedFileSystemLoader(baseDir)
+	if err != nil {
+		log.Panic(err)
+	}
+	return fs
+}
+
+// NewJailedFileSystemLoader creates a new JailedFilesystemLoader which only
+// allows templates within baseDir to be loaded. The base directory is
+// required and must exist.
+func NewJailedFileSystemLoader(baseDir string) (*JailedFilesystemLoader, error) {
+	if baseDir == "" {
+		return nil, errors.New("a base directory is required for the jailed filesystem loader")
+	}
+	abs, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("the given path '%s' is not a directory", abs)
+	}
+	return &JailedFilesystemLoader{baseDir: abs}, nil
+}
+
+// Abs resolves a filename relative to the base directory. The result is not
+// checked here; paths outside of the base directory are rejected by Get.
+func (fs *JailedFilesystemLoader) Abs(base, name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(fs.baseDir, name)
+}
+
+// Get reads the path's content from your local filesystem. It returns
+// ErrPathOutsideBaseDir if the path is not located within the base directory.
+// Relative symlinks are followed as long as they don't leave the base
+// directory; absolute symlinks are always rejected.
+func (fs *JailedFilesystemLoader) Get(path string) (io.Reader, error) {
+	rel, err := fs.relative(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenInRoot(fs.baseDir, rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// Version returns the file's modification time and size. The same
+// restrictions as for Get apply.
+func (fs *JailedFilesystemLoader) Version(path string) (string, error) {
+	rel, err := fs.relative(path)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenInRoot(fs.baseDir, rel)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return fileInfoVersion(fi), nil
+}
+
+// List returns the names of all files within the base directory starting
+// with prefix. Names are relative to the base directory and use forward
+// slashes. Symlinks pointing outside of the base directory are omitted.
+func (fs *JailedFilesystemLoader) List(prefix string) ([]string, error) {
+	root, err := os.OpenRoot(fs.baseDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return listFS(root.FS(), prefix)
+}
+
+// relative returns path relative to the base directory or an error if
+// the path lexically leaves the base directory.
+func (fs *JailedFilesystemLoader) relative(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(fs.baseDir, path)
+	}
+	rel, err := filepath.Rel(fs.baseDir, filepath.Clean(path))
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: '%s'", ErrPathOutsideBaseDir, path)
+	}
+	return rel, nil
+}
+
+// relativeName returns path relative to the base directory.
+func (fs *JailedFilesystemLoader) relativeName(path string) string {
+	return relativeToDir(fs.baseDir, path)
+}
+
+// ErrPathOutsideBaseDir is returned by JailedFilesystemLoader if a template
+// path points outside of the loader's base directory.
+var ErrPathOutsideBaseDir = errors.New("template path is outside of the base directory")
+
+// MapLoader is an in-memory template loader which maps template names to
+// their source. It's safe for concurrent use; templates can be added or
+// removed while the loader is in use. Note that templates already compiled
+// and cached by a TemplateSet are not affected by updates (see CleanCache).
+type MapLoader struct {
+	mu        sync.RWMutex
+	templates map[string]string
+}
+
+// NewMapLoader creates a new MapLoader which is initialized with a copy
+// of the given templates (name -> source). templates may be nil.
+func NewMapLoader(templates map[string]string) *MapLoader {
+	l := &MapLoader{
+		templates: make(map[string]string, len(templates)),
+	}
+	maps.Copy(l.templates, templates)
+	return l
+}
+
+// Set adds or replaces the template with the given name.
+func (l *MapLoader) Set(name, source string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.templates[name] = source
+}
+
+// Delete removes the template with the given name.
+func (l *MapLoader) Delete(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.templates, name)
+}
+
+// Abs returns the name unchanged; templates are identified by their name only.
+func (l *MapLoader) Abs(base, name string) string {
+	return name
+}
+
+// Get returns the source of the template with the given name.
+func (l *MapLoader) Get(path string) (io.Reader, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	src, has := l.templates[path]
+	if !has {
+		return nil, fmt.Errorf("%w: '%s'", fs.ErrNotExist, path)
+	}
+	return strings.NewReader(src), nil
+}
+
+// List returns the sorted names of all templates starting with prefix.
+func (l *MapLoader) List(prefix string) ([]string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var names []string
+	for name := range l.templates {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Version returns a hash of the template's source.
+func (l *MapLoader) Version(path string) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	src, has := l.templates[path]
+	if !has {
+		return "", fmt.Errorf("%w: '%s'", fs.ErrNotExist, path)
+	}
+	return contentVersion([]byte(src)), nil
+}
+
+// PrefixLoader dispatches template names to other loaders based on their
+// prefix. A name like "admin/index.html" is passed as "index.html" to the
+// loader registered for the "admin" prefix. Names without a known prefix
+// can't be loaded, which makes sure that templates of one namespace can't
+// shadow templates of another one:
+//
+//	loader := pongo2.NewPrefixLoader(map[string]pongo2.TemplateLoader{
+//		"system": pongo2.NewFSLoader(embeddedFS),
+//		"tenant": pongo2.NewMapLoader(customerTemplates),
+//	})
+//
+//	{# tenant/welcome.html #}
+//	{% extends "system/base.html" %}
+type PrefixLoader struct {
+	loaders   map[string]TemplateLoader
+	delimiter string
+}
+
+// NewPrefixLoader creates a new PrefixLoader using "/" as delimiter
+// between the prefix and the name passed to the respective loader.
+func NewPrefixLoader(loaders map[string]TemplateLoader) *PrefixLoader {
+	return NewPrefixLoaderWithDelimiter(loaders, "/")
+}
+
+// NewPrefixLoaderWithDelimiter creates a new PrefixLoader using a custom
+// delimiter between the prefix and the name (for example ":" for "mail:welcome.html").
+func NewPrefixLoaderWithDelimiter(loaders map[string]TemplateLoader, delimiter string) *PrefixLoader {
+	for prefix, loader := range loaders {
+		if loader == nil {
+			panic(fmt.Errorf("loader for prefix '%s' is nil", prefix))
+		}
+	}
+	return &PrefixLoader{
+		loaders:   maps.Clone(loaders),
+		delimiter: delimiter,
+	}
+}
+
+// split returns the prefix, the remaining name and the loader for the given
+// name. loader is nil if the name has no known prefix.
+func (l *PrefixLoader) split(name string) (prefix, rest string, loader TemplateLoader) {
+	prefix, rest, found := strings.Cut(name, l.delimiter)
+	if !found {
+		return "", name, nil
+	}
+	return prefix, rest, l.loaders[prefix]
+}
+
+// Abs resolves the name using the loader responsible for its prefix. The base
+// is only passed on to that loader if it belongs to the same prefix.
+func (l *PrefixLoader) Abs(base, name string) string {
+	prefix, rest, loader := l.split(name)
+	if loader == nil {
+		return name
+	}
+	basePrefix, baseRest, _ := l.split(base)
+	if basePrefix != prefix {
+		baseRest = ""
+	}
+	return prefix + l.delimiter + loader.Abs(baseRest, rest)
+}
+
+// relativeName returns the name of path relative to the base directory of
+// the loader responsible for its prefix, keeping the prefix.
+func (l *PrefixLoader) relativeName(path string) string {
+	prefix, rest, loader := l.split(path)
+	if namer, ok := loader.(relativeNamer); ok {
+		return prefix + l.delimiter + namer.relativeName(rest)
+	}
+	return path
+}
+
+// Get reads the template using the loader responsible for its prefix.
+func (l *PrefixLoader) Get(path string) (io.Reader, error) {
+	_, rest, loader := l.split(path)
+	if loader == nil {
+		return nil, fmt.Errorf("%w: no loader registered for the prefix of '%s'", fs.ErrNotExist, path)
+	}
+	return loader.Get(rest)
+}
+
+// List returns the names of all templates starting with prefix, collected from
+// all registered loaders implementing TemplateLister. The names include the
+// loaders' prefixes.
+func (l *PrefixLoader) List(prefix string) ([]string, error) {
+	var names []string
+	for _, loaderPrefix := range slices.Sorted(maps.Keys(l.loaders)) {
+		lister, ok := l.loaders[loaderPrefix].(TemplateLister)
+		if !ok {
+			continue
+		}
+		namespace := loaderPrefix + l.delimiter
+		var subPrefix string
+		switch {
+		case strings.HasPrefix(prefix, namespace):
+			subPrefix = prefix[len(namespace):]
+		case strings.HasPrefix(namespace, prefix):
+			subPrefix = ""
+		default:
+			continue
+		}
+		list, err := lister.List(subPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range list {
+			names = append(names, namespace+name)
+		}
+	}
+	return names, nil
+}
+
+// Version returns the version reported by the loader responsible for the
+// path's prefix. If that loader doesn't implement TemplateVersioner, the
+// template is read and versioned by its content.
+func (l *PrefixLoader) Version(path string) (string, error) {
+	_, rest, loader := l.split(path)
+	if loader == nil {
+		return "", fmt.Errorf("%w: no loader registered for the prefix of '%s'", fs.ErrNotExist, path)
+	}
+	if versioner, ok := loader.(TemplateVersioner); ok {
+		return versioner.Version(rest)
+	}
+	r, err := loader.Get(rest)
+	if err != nil {
+		return "", err
+	}
+	return readerVersion(r)
+}
@@ -1,8 +1,14 @@
 package pongo2
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 	"testing/fstest"
 )
@@ -45,6 +51,34 @@ func TestFSLoader(t *testing.T) {
 			t.Error("Get should fail for non-existent file")
 		}
 	})
+
+	t.Run("Version", func(t *testing.T) {
+		v1, err := loader.Version("templates/base.tpl")
+		if err != nil {
+			t.Fatalf("Version failed: %v", err)
+		}
+		v2, _ := loader.Version("templates/child.tpl")
+		if v1 == "" || v1 == v2 {
+			t.Errorf("Version = %q and %q, want distinct versions", v1, v2)
+		}
+		if _, err := loader.Version("nonexistent.tpl"); err == nil {
+			t.Error("Version should fail for non-existent file")
+		}
+	})
+
+	t.Run("Version without modification times is hashed once", func(t *testing.T) {
+		memFS := fstest.MapFS{"a.tpl": {Data: []byte("a")}}
+		loader := NewFSLoader(memFS)
+		v1, err := loader.Version("a.tpl")
+		if err != nil {
+			t.Fatalf("Version failed: %v", err)
+		}
+		// Files without modification times are assumed to be immutable
+		memFS["a.tpl"] = &fstest.MapFile{Data: []byte("b")}
+		if v2, _ := loader.Version("a.tpl"); v2 != v1 {
+			t.Errorf("Version = %q, want cached %q", v2, v1)
+		}
+	})
 }
 
 func TestMultipleLoaders(t *testing.T) {
@@ -175,3 +209,234 @@ func TestMultipleLoadersWithIncludes(t *testing.T) {
 		t.Errorf("got %q, want %q", out, expected)
 	}
 }
+
+func TestJailedFilesystemLoader(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	mustWrite := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	mustWrite(filepath.Join(root, "page.tpl"), "page")
+	mustWrite(filepath.Join(root, "partials", "box.tpl"), "box")
+	mustWrite(filepath.Join(root, "dynamic.tpl"), "{% include name %}")
+	mustWrite(filepath.Join(root, "ssi.tpl"), `{% ssi "../secret.txt" %}`)
+	mustWrite(filepath.Join(outside, "secret.txt"), "secret")
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "evil.tpl")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "page.tpl"), filepath.Join(root, "partials", "alias.tpl")); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+
+	loader := MustNewJailedFileSystemLoader(root)
+	set := NewSet("jailed", loader)
+
+	t.Run("inside base dir", func(t *testing.T) {
+		for _, name := range []string{"page.tpl", "partials/box.tpl", "partials/alias.tpl", filepath.Join(root, "page.tpl")} {
+			if _, err := set.FromFile(name); err != nil {
+				t.Errorf("FromFile(%q) failed: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("rejected paths", func(t *testing.T) {
+		secret := filepath.Join(outside, "secret.txt")
+		rel, err := filepath.Rel(root, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{secret, rel, "partials/../../secret.txt", "evil.tpl"} {
+			if _, err := loader.Get(loader.Abs("", name)); err == nil {
+				t.Errorf("Get(%q) should fail", name)
+			}
+		}
+		if _, err := loader.Get(secret); !errors.Is(err, ErrPathOutsideBaseDir) {
+			t.Errorf("Get(%q) = %v, want ErrPathOutsideBaseDir", secret, err)
+		}
+	})
+
+	t.Run("dynamic include", func(t *testing.T) {
+		tpl, err := set.FromFile("dynamic.tpl")
+		if err != nil {
+			t.Fatalf("FromFile failed: %v", err)
+		}
+		out, err := tpl.Execute(Context{"name": "partials/box.tpl"})
+		if err != nil || out != "box" {
+			t.Errorf("Execute = %q, %v; want %q", out, err, "box")
+		}
+		for _, name := range []string{"../" + filepath.Base(outside) + "/secret.txt", filepath.Join(outside, "secret.txt")} {
+			if _, err := tpl.Execute(Context{"name": name}); err == nil {
+				t.Errorf("include of %q should fail", name)
+			}
+		}
+	})
+
+	t.Run("ssi", func(t *testing.T) {
+		if _, err := set.FromFile("ssi.tpl"); err == nil {
+			t.Error("ssi outside of the base directory should fail")
+		}
+	})
+
+	t.Run("base dir required", func(t *testing.T) {
+		if _, err := NewJailedFileSystemLoader(""); err == nil {
+			t.Error("NewJailedFileSystemLoader should fail without base directory")
+		}
+		if _, err := NewJailedFileSystemLoader(filepath.Join(root, "page.tpl")); err == nil {
+			t.Error("NewJailedFileSystemLoader should fail for a file")
+		}
+	})
+}
+
+func TestMapLoader(t *testing.T) {
+	loader := NewMapLoader(map[string]string{
+		"base.tpl": "Hello {% block name %}{% endblock %}!",
+	})
+	loader.Set("child.tpl", `{% extends "base.tpl" %}{% block name %}{{ name }}{% endblock %}`)
+
+	set := NewSet("map", loader)
+	out, err := set.RenderTemplateFile("child.tpl", Context{"name": "World"})
+	if err != nil {
+		t.Fatalf("RenderTemplateFile failed: %v", err)
+	}
+	if out != "Hello World!" {
+		t.Errorf("got %q, want %q", out, "Hello World!")
+	}
+
+	loader.Delete("child.tpl")
+	if _, err := loader.Get("child.tpl"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get after Delete = %v, want fs.ErrNotExist", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("tpl%d.tpl", i)
+			loader.Set(name, name)
+			if _, err := loader.Get(name); err != nil {
+				t.Errorf("Get(%q) failed: %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPrefixLoader(t *testing.T) {
+	system := NewFSLoader(fstest.MapFS{
+		"base.tpl":    {Data: []byte("[system {% block content %}{% endblock %}]")},
+		"partial.tpl": {Data: []byte("system partial")},
+	})
+	tenant := NewMapLoader(map[string]string{
+		"base.tpl":    "[tenant base]",
+		"welcome.tpl": `{% extends "system/base.tpl" %}{% block content %}{% include "tenant/partial.tpl" %}{% endblock %}`,
+		"partial.tpl": "tenant partial",
+		"shadow.tpl":  `{% extends "base.tpl" %}`,
+	})
+	loader := NewPrefixLoader(map[string]TemplateLoader{
+		"system": system,
+		"tenant": tenant,
+	})
+	set := NewSet("prefix", loader)
+
+	out, err := set.RenderTemplateFile("tenant/welcome.tpl", nil)
+	if err != nil {
+		t.Fatalf("RenderTemplateFile failed: %v", err)
+	}
+	if out != "[system tenant partial]" {
+		t.Errorf("got %q, want %q", out, "[system tenant partial]")
+	}
+
+	for _, name := range []string{"base.tpl", "other/base.tpl", "tenant/shadow.tpl"} {
+		if _, err := set.FromFile(name); err == nil {
+			t.Errorf("FromFile(%q) should fail", name)
+		}
+	}
+
+	colon := NewPrefixLoaderWithDelimiter(map[string]TemplateLoader{"mail": tenant}, ":")
+	if got := colon.Abs("", "mail:partial.tpl"); got != "mail:partial.tpl" {
+		t.Errorf("Abs = %q, want %q", got, "mail:partial.tpl")
+	}
+	if _, err := colon.Get("mail:partial.tpl"); err != nil {
+		t.Errorf("Get failed: %v", err)
+	}
+}
+
+func TestTemplateListers(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"base.tpl", "emails/welcome.tpl", "emails/reset.tpl", "pages/index.tpl"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside := filepath.Join(t.TempDir(), "secret.tpl")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "emails", "secret.tpl")); err != nil {
+		t.Fatal(err)
+	}
+
+	mapFS := fstest.MapFS{
+		"base.tpl":           {Data: []byte("base")},
+		"emails/welcome.tpl": {Data: []byte("welcome")},
+		"emails/reset.tpl":   {Data: []byte("reset")},
+		"pages/index.tpl":    {Data: []byte("index")},
+	}
+
+	tests := []struct {
+		name   string
+		lister TemplateLister
+		prefix string
+		want   []string
+	}{
+		{"FSLoader all", NewFSLoader(mapFS), "", []string{"base.tpl", "emails/reset.tpl", "emails/welcome.tpl", "pages/index.tpl"}},
+		{"FSLoader dir", NewFSLoader(mapFS), "emails/", []string{"emails/reset.tpl", "emails/welcome.tpl"}},
+		{"FSLoader partial", NewFSLoader(mapFS), "emails/w", []string{"emails/welcome.tpl"}},
+		{"FSLoader missing dir", NewFSLoader(mapFS), "missing/", nil},
+		{"LocalFilesystemLoader", MustNewLocalFileSystemLoader(dir), "emails/", []string{"emails/reset.tpl", "emails/secret.tpl", "emails/welcome.tpl"}},
+		{"JailedFilesystemLoader", MustNewJailedFileSystemLoader(dir), "emails/", []string{"emails/reset.tpl", "emails/welcome.tpl"}},
+		{"MapLoader", NewMapLoader(map[string]string{"a.tpl": "", "b/c.tpl": "", "b/d.tpl": ""}), "b/", []string{"b/c.tpl", "b/d.tpl"}},
+		{
+			"PrefixLoader",
+			NewPrefixLoader(map[string]TemplateLoader{
+				"system": NewFSLoader(mapFS),
+				"tenant": NewMapLoader(map[string]string{"base.tpl": ""}),
+			}),
+			"", []string{"system/base.tpl", "system/emails/reset.tpl", "system/emails/welcome.tpl", "system/pages/index.tpl", "tenant/base.tpl"},
+		},
+		{
+			"PrefixLoader sub prefix",
+			NewPrefixLoader(map[string]TemplateLoader{
+				"system": NewFSLoader(mapFS),
+				"tenant": NewMapLoader(map[string]string{"base.tpl": ""}),
+			}),
+			"system/emails/", []string{"system/emails/reset.tpl", "system/emails/welcome.tpl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.lister.List(tt.prefix)
+			if err != nil {
+				t.Fatalf("List(%q) failed: %v", tt.prefix, err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("List(%q) = %q, want %q", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
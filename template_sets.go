@@ -4,8 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"maps"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -20,6 +26,33 @@ type TemplateLoader interface {
 	Get(path string) (io.Reader, error)
 }
 
+// relativeNamer is implemented by loaders resolving template names to
+// absolute paths (see TemplateLoader.Abs). relativeName maps a resolved path
+// back to the name relative to the loader's base directory, so that a
+// template gets the same name however it was loaded.
+type relativeNamer interface {
+	relativeName(path string) string
+}
+
+// TemplateVersioner is an optional interface a TemplateLoader can implement to
+// report the current version of a template (for example its modification time
+// or a hash of its content) without compiling it. The version is an opaque
+// string which must change whenever the template's content changes.
+//
+// Template.Version and Template.Fingerprint use it, and so does
+// TemplateSet.FromCache to detect stale templates when AutoReload is enabled.
+type TemplateVersioner interface {
+	Version(path string) (string, error)
+}
+
+// TemplateLister is an optional interface a TemplateLoader can implement to
+// list the templates it provides. List returns the names (as accepted by
+// TemplateSet.FromFile) of all templates starting with the given prefix.
+// See TemplateSet.ListTemplates.
+type TemplateLister interface {
+	List(prefix string) ([]string, error)
+}
+
 // TemplateSet allows you to create your own group of templates with their own
 // global context (which is shared among all members of the set) and their own
 // configuration.
@@ -38,29 +71,55 @@ type TemplateSet struct {
 	// variable during program execution (and template compilation/execution).
 	Debug bool
 
+	// If AutoReload is true (default false), FromCache() checks whether a cached
+	// template or any of the templates it was compiled with (parents, static
+	// includes, imports, ssi files) has changed and recompiles it if so. This
+	// requires the loaders to implement TemplateVersioner; templates from other
+	// loaders are never considered stale.
+	AutoReload bool
+
+	// AccessPolicy, if set, decides which struct fields, methods and map keys
+	// templates of this set can access. See AccessPolicy for details.
+	AccessPolicy AccessPolicy
+
 	// autoescape controls whether template output is automatically HTML-escaped.
 	// When true (default), string output will be escaped for safety.
 	autoescape bool
 
+	// Autoescape modes (mode name -> escape filter) and the rules assigning
+	// them to templates (see SetAutoescapeMode)
+	autoescapeModes map[string]string
+	autoescapeRules []autoescapeRule
+
 	// Options allow you to change the behavior of template-engine.
 	// You can change the options before calling the Execute method.
 	Options *Options
 
-	// Per-set tag and filter registries (lazily initialized via initOnce)
+	// Per-set tag, filter and test registries (lazily initialized via initOnce)
 	tags       map[string]*tag
 	filters    map[string]FilterFunction
 	filterArgs map[string]FilterArgsFunction
+	tests      map[string]TestFunction
 	initOnce   sync.Once
 
 	// Sandbox features
-	// - Disallow access to specific tags and/or filters (using BanTag() and BanFilter())
+	// - Disallow access to specific tags, filters and/or tests (using BanTag(),
+	//   BanFilter() and BanTest())
+	// - Allow only specific tags, filters and/or tests (using AllowTags(),
+	//   AllowFilters() and AllowTests()); a nil allowlist means everything
+	//   not banned is allowed
 	//
-	// For efficiency reasons you can ban tags/filters only *before* you have
-	// added your first template to the set (restrictions are statically checked).
-	// After you added one, it's not possible anymore (for your personal security).
+	// For efficiency reasons you can ban/allow tags/filters/tests only *before*
+	// you have added your first template to the set (restrictions are statically
+	// checked). After you added one, it's not possible anymore (for your
+	// personal security).
 	firstTemplateCreated atomic.Bool
 	bannedTags           map[string]bool
 	bannedFilters        map[string]bool
+	bannedTests          map[string]bool
+	allowedTags          map[string]bool
+	allowedFilters       map[string]bool
+	allowedTests         map[string]bool
 
 	// Template cache (for FromCache())
 	templateCache      map[string]*Template
@@ -88,6 +147,7 @@ func NewSet(name string, loaders ...TemplateLoader) *TemplateSet {
 		// tags and filters are lazily initialized via initOnce
 		bannedTags:    make(map[string]bool),
 		bannedFilters: make(map[string]bool),
+		bannedTests:   make(map[string]bool),
 		templateCache: make(map[string]*Template),
 		Options:       newOptions(),
 	}
@@ -99,11 +159,15 @@ func (set *TemplateSet) AddLoader(loaders ...TemplateLoader) {
 
 // initBuiltins copies the builtin tags and filters into this template set.
 // This is called lazily via initOnce to ensure builtinTags and builtinFilters
-// have been populated by init() functions before copying.
+// have been populated by init() functions before copying. The set's tests only
+// hold tests registered for the set; other names are resolved against the
+// global tests (see test).
 func (set *TemplateSet) initBuiltins() {
 	set.tags = copyTags(builtinTags)
 	set.filters = copyFilters(builtinFilters)
 	set.filterArgs = copyFilterArgs(builtinFilterArgs)
+	set.tests = make(map[string]TestFunction)
+	set.autoescapeModes = maps.Clone(builtinAutoescapeModes)
 }
 
 func (set *TemplateSet) resolveFilename(tpl *Template, path string) string {
@@ -163,6 +227,122 @@ func (set *TemplateSet) BanFilter(name string) error {
 	return nil
 }
 
+// BanTest bans a specific test (as in `{% if x is odd %}`) for this template
+// set. See more in the documentation for TemplateSet.
+func (set *TemplateSet) BanTest(name string) error {
+	set.initOnce.Do(set.initBuiltins)
+	_, has := set.test(name)
+	if !has {
+		return fmt.Errorf("test '%s' not found", name)
+	}
+	if set.firstTemplateCreated.Load() {
+		return errors.New("you cannot ban any tests after you've added your first template to your template set")
+	}
+	_, has = set.bannedTests[name]
+	if has {
+		return fmt.Errorf("test '%s' is already banned", name)
+	}
+	set.bannedTests[name] = true
+
+	return nil
+}
+
+// AllowTags switches the tags of this template set into allowlist mode: only
+// the given tags (and those allowed by previous calls) can be used, every other
+// tag is rejected at parse time. This includes tags added to pongo2 in future
+// releases. Banned tags stay banned even if allowed. Calling it without any
+// names disallows all tags.
+func (set *TemplateSet) AllowTags(names ...string) error {
+	set.initOnce.Do(set.initBuiltins)
+	for _, name := range names {
+		if _, has := set.tags[name]; !has {
+			return fmt.Errorf("tag '%s' not found", name)
+		}
+	}
+	if set.firstTemplateCreated.Load() {
+		return errors.New("you cannot allow any tags after you've added your first template to your template set")
+	}
+	set.allowedTags = allowNames(set.allowedTags, names)
+
+	return nil
+}
+
+// AllowFilters switches the filters of this template set into allowlist mode.
+// It works like AllowTags.
+func (set *TemplateSet) AllowFilters(names ...string) error {
+	set.initOnce.Do(set.initBuiltins)
+	for _, name := range names {
+		_, has := set.filters[name]
+		if !has {
+			_, has = set.filterArgs[name]
+		}
+		if !has {
+			return fmt.Errorf("filter '%s' not found", name)
+		}
+	}
+	if set.firstTemplateCreated.Load() {
+		return errors.New("you cannot allow any filters after you've added your first template to your template set")
+	}
+	set.allowedFilters = allowNames(set.allowedFilters, names)
+
+	return nil
+}
+
+// AllowTests switches the tests (as in `{% if x is odd %}`) of this template
+// set into allowlist mode. It works like AllowTags.
+func (set *TemplateSet) AllowTests(names ...string) error {
+	set.initOnce.Do(set.initBuiltins)
+	for _, name := range names {
+		if _, has := set.test(name); !has {
+			return fmt.Errorf("test '%s' not found", name)
+		}
+	}
+	if set.firstTemplateCreated.Load() {
+		return errors.New("you cannot allow any tests after you've added your first template to your template set")
+	}
+	set.allowedTests = allowNames(set.allowedTests, names)
+
+	return nil
+}
+
+// allowNames adds names to the allowlist, creating it if necessary.
+func allowNames(allowlist map[string]bool, names []string) map[string]bool {
+	if allowlist == nil {
+		allowlist = make(map[string]bool, len(names))
+	}
+	for _, name := range names {
+		allowlist[name] = true
+	}
+	return allowlist
+}
+
+// isTagAllowed reports whether the tag is neither banned nor excluded by the
+// tag allowlist.
+func (set *TemplateSet) isTagAllowed(name string) bool {
+	if set.bannedTags[name] {
+		return false
+	}
+	return set.allowedTags == nil || set.allowedTags[name]
+}
+
+// isFilterAllowed reports whether the filter is neither banned nor excluded
+// by the filter allowlist.
+func (set *TemplateSet) isFilterAllowed(name string) bool {
+	if set.bannedFilters[name] {
+		return false
+	}
+	return set.allowedFilters == nil || set.allowedFilters[name]
+}
+
+// isTestAllowed reports whether the test is neither banned nor excluded by
+// the test allowlist.
+func (set *TemplateSet) isTestAllowed(name string) bool {
+	if set.bannedTests[name] {
+		return false
+	}
+	return set.allowedTests == nil || set.allowedTests[name]
+}
+
 // RegisterFilter registers a new filter for this template set.
 func (set *TemplateSet) RegisterFilter(name string, fn FilterFunction) error {
 	set.initOnce.Do(set.initBuiltins)
@@ -196,6 +376,96 @@ func (set *TemplateSet) SetAutoescape(v bool) {
 	set.autoescape = v
 }
 
+// builtinAutoescapeModes maps the builtin autoescape modes to the filters
+// they escape with. An empty filter disables escaping.
+var builtinAutoescapeModes = map[string]string{
+	"html":  "escape",
+	"xml":   "escapexml",
+	"json":  "escapejson",
+	"latex": "escapelatex",
+	"shell": "escapeshell",
+	"none":  "",
+}
+
+// autoescapeRule assigns an autoescape mode to the templates matching pattern.
+type autoescapeRule struct {
+	pattern string
+	mode    string
+}
+
+// match reports whether the template name matches the rule's pattern.
+func (rule autoescapeRule) match(name string) bool {
+	name = filepath.ToSlash(name)
+	if strings.HasPrefix(rule.pattern, ".") {
+		return strings.HasSuffix(name, rule.pattern)
+	}
+	if ok, _ := path.Match(rule.pattern, name); ok {
+		return true
+	}
+	ok, _ := path.Match(rule.pattern, path.Base(name))
+	return ok
+}
+
+// RegisterAutoescapeMode registers a new autoescape mode for this template
+// set, escaping with the given filter (an empty filter name disables
+// escaping). Modes can be assigned to templates with SetAutoescapeMode and
+// used with {% autoescape "mode" %}.
+func (set *TemplateSet) RegisterAutoescapeMode(name, filterName string) error {
+	set.initOnce.Do(set.initBuiltins)
+	if _, existing := set.autoescapeModes[name]; existing {
+		return fmt.Errorf("autoescape mode '%s' is already registered", name)
+	}
+	if filterName != "" && !set.FilterExists(filterName) {
+		return fmt.Errorf("filter '%s' not found", filterName)
+	}
+	set.autoescapeModes[name] = filterName
+	return nil
+}
+
+// SetAutoescapeMode assigns an autoescape mode ("html", "xml", "json",
+// "latex", "shell", "none" or a mode registered with RegisterAutoescapeMode)
+// to the templates matching pattern, overriding Options.AutoescapeFilter for
+// them. A pattern starting with a dot matches a file extension (".txt"),
+// anything else is a path.Match pattern matched against the template name
+// and its base name ("emails/*.txt", "*.json"). For filesystem loaders, the
+// name is relative to the loader's base directory (or the working directory),
+// no matter how the template was loaded. Rules are checked in the
+// order they were added, the first match wins. Templates created from
+// strings don't match any rule.
+//
+// Like bans, rules can only be added before the first template is created.
+func (set *TemplateSet) SetAutoescapeMode(pattern, mode string) error {
+	set.initOnce.Do(set.initBuiltins)
+	if _, has := set.autoescapeModes[mode]; !has {
+		return fmt.Errorf("autoescape mode '%s' not found", mode)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid autoescape pattern '%s': %w", pattern, err)
+	}
+	if set.firstTemplateCreated.Load() {
+		return errors.New("you cannot set autoescape modes after you've added your first template to your template set")
+	}
+	set.autoescapeRules = append(set.autoescapeRules, autoescapeRule{pattern: pattern, mode: mode})
+	return nil
+}
+
+// autoescapeModeFor returns the autoescape mode assigned to the template
+// name by SetAutoescapeMode, if any. Paths resolved by a filesystem loader
+// are matched relative to its base directory.
+func (set *TemplateSet) autoescapeModeFor(name string, src *templateSource) (string, bool) {
+	if src != nil && src.path != "" {
+		if namer, ok := src.loader.(relativeNamer); ok {
+			name = namer.relativeName(src.path)
+		}
+	}
+	for _, rule := range set.autoescapeRules {
+		if rule.match(name) {
+			return rule.mode, true
+		}
+	}
+	return "", false
+}
+
 // ReplaceFilter replaces an already registered filter in this template set.
 // Use this function with caution since it allows you to change existing filter behaviour.
 func (set *TemplateSet) ReplaceFilter(name string, fn FilterFunction) error {
@@ -255,6 +525,48 @@ func (set *TemplateSet) ReplaceTag(name string, parserFn TagParser) error {
 	return nil
 }
 
+// RegisterTest registers a new test for this template set.
+// It returns an error if a test with the same name is registered in this set
+// or globally.
+func (set *TemplateSet) RegisterTest(name string, fn TestFunction) error {
+	set.initOnce.Do(set.initBuiltins)
+	if _, existing := set.test(name); existing {
+		return fmt.Errorf("test with name '%s' is already registered", name)
+	}
+	set.tests[name] = fn
+	return nil
+}
+
+// ReplaceTest replaces an already registered test in this template set. A global
+// test replaced this way is only overridden for this set.
+// Use this function with caution since it allows you to change existing test behaviour.
+func (set *TemplateSet) ReplaceTest(name string, fn TestFunction) error {
+	set.initOnce.Do(set.initBuiltins)
+	if _, existing := set.test(name); !existing {
+		return fmt.Errorf("test with name '%s' does not exist (therefore cannot be overridden)", name)
+	}
+	set.tests[name] = fn
+	return nil
+}
+
+// TestExists returns true if the given test is registered in this template set.
+// This checks the tests registered via TemplateSet.RegisterTest and all global tests.
+func (set *TemplateSet) TestExists(name string) bool {
+	set.initOnce.Do(set.initBuiltins)
+	_, existing := set.test(name)
+	return existing
+}
+
+// test returns the test registered as name in this set or, if there's none,
+// the global test with that name.
+func (set *TemplateSet) test(name string) (TestFunction, bool) {
+	if fn, existing := set.tests[name]; existing {
+		return fn, true
+	}
+	fn, existing := tests[name]
+	return fn, existing
+}
+
 // FilterExists returns true if the given filter is registered in this template set.
 // This checks the set's filter registry, which initially contains copies of all builtin filters
 // plus any filters registered via RegisterFilter.
@@ -276,6 +588,48 @@ func (set *TemplateSet) TagExists(name string) bool {
 	return existing
 }
 
+// FilterNames returns the names of the filters templates of this set can use:
+// the registered filters which are neither banned nor excluded by
+// AllowFilters (sorted).
+func (set *TemplateSet) FilterNames() []string {
+	set.initOnce.Do(set.initBuiltins)
+	names := slices.Collect(maps.Keys(set.filters))
+	for name := range set.filterArgs {
+		if _, existing := set.filters[name]; !existing {
+			names = append(names, name)
+		}
+	}
+	names = slices.DeleteFunc(names, func(name string) bool { return !set.isFilterAllowed(name) })
+	slices.Sort(names)
+	return names
+}
+
+// TagNames returns the names of the tags templates of this set can use: the
+// registered tags which are neither banned nor excluded by AllowTags (sorted).
+func (set *TemplateSet) TagNames() []string {
+	set.initOnce.Do(set.initBuiltins)
+	names := slices.Collect(maps.Keys(set.tags))
+	names = slices.DeleteFunc(names, func(name string) bool { return !set.isTagAllowed(name) })
+	slices.Sort(names)
+	return names
+}
+
+// TestNames returns the names of the tests templates of this set can use:
+// the registered tests which are neither banned nor excluded by AllowTests
+// (sorted).
+func (set *TemplateSet) TestNames() []string {
+	set.initOnce.Do(set.initBuiltins)
+	names := slices.Collect(maps.Keys(set.tests))
+	for name := range tests {
+		if _, existing := set.tests[name]; !existing {
+			names = append(names, name)
+		}
+	}
+	names = slices.DeleteFunc(names, func(name string) bool { return !set.isTestAllowed(name) })
+	slices.Sort(names)
+	return names
+}
+
 // ApplyFilter applies a filter registered in this template set to a given value
 // using the given parameters. Returns a *pongo2.Value or an error.
 // This is useful for applying set-specific filters, including any custom filters
@@ -372,7 +726,74 @@ func (set *TemplateSet) resolveTemplate(tpl *Template, path string) (name string
 		}
 	}
 
-	return path, nil, nil, fmt.Errorf("unable to resolve template")
+	return path, nil, nil, errUnresolvedTemplate
+}
+
+var errUnresolvedTemplate = errors.New("unable to resolve template")
+
+// loadErrorCode returns the code of an error of loadSource.
+func loadErrorCode(err error) ErrorCode {
+	if errors.Is(err, errUnresolvedTemplate) || errors.Is(err, fs.ErrNotExist) {
+		return ErrCodeTemplateNotFound
+	}
+	return ErrCodeExecution
+}
+
+// loadSource resolves and reads the given path and returns its content
+// together with the loader it was found by and its version.
+func (set *TemplateSet) loadSource(tpl *Template, path string) (*templateSource, []byte, error) {
+	name, loader, fd, err := set.resolveTemplate(tpl, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src := &templateSource{
+		loader: loader,
+		path:   name,
+	}
+	if versioner, ok := loader.(TemplateVersioner); ok {
+		// Determine the version before reading, so a concurrent change
+		// leads to a stale version at worst (and a recompilation).
+		src.version, err = versioner.Version(name)
+		if err != nil {
+			src.version = ""
+		}
+	}
+
+	buf, err := io.ReadAll(fd)
+	if closer, ok := fd.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if src.version == "" {
+		src.version = contentVersion(buf)
+	}
+
+	return src, buf, nil
+}
+
+// ListTemplates returns the sorted names of all templates starting with the
+// given prefix, aggregated across all loaders of this set implementing
+// TemplateLister. Loaders not implementing it are skipped.
+func (set *TemplateSet) ListTemplates(prefix string) ([]string, error) {
+	var names []string
+	for _, loader := range set.loaders {
+		lister, ok := loader.(TemplateLister)
+		if !ok {
+			continue
+		}
+		list, err := lister.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, list...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
 }
 
 // CleanCache cleans the template cache. If filenames is not empty,
@@ -409,8 +830,8 @@ func (set *TemplateSet) FromCache(filename string) (*Template, error) {
 
 	tpl, has := set.templateCache[cleanedFilename]
 
-	// Cache miss
-	if !has {
+	// Cache miss (or a stale template if auto reload is enabled)
+	if !has || (set.AutoReload && tpl.isStale()) {
 		tpl, err := set.FromFile(cleanedFilename)
 		if err != nil {
 			return nil, err
@@ -435,24 +856,17 @@ func (set *TemplateSet) FromBytes(tpl []byte) (*Template, error) {
 
 // FromFile loads a template from a filename and returns a Template instance.
 func (set *TemplateSet) FromFile(filename string) (*Template, error) {
-	_, _, fd, err := set.resolveTemplate(nil, filename)
-	if err != nil {
-		return nil, &Error{
-			Filename:  filename,
-			Sender:    "fromfile",
-			OrigError: err,
-		}
-	}
-	buf, err := io.ReadAll(fd)
+	src, buf, err := set.loadSource(nil, filename)
 	if err != nil {
 		return nil, &Error{
 			Filename:  filename,
 			Sender:    "fromfile",
 			OrigError: err,
+			Code:      loadErrorCode(err),
 		}
 	}
 
-	return newTemplate(set, filename, false, buf)
+	return newTemplateWithSource(set, filename, false, src, buf)
 }
 
 // RenderTemplateString is a shortcut and renders a template string directly.
@@ -3,6 +3,7 @@ package pongo2
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -240,3 +241,453 @@ func TestOptions(t *testing.T) {
 		t.Errorf("TrimBlocks should remove leading newline, got %q", result)
 	}
 }
+
+func TestTemplateSetAutoReload(t *testing.T) {
+	loader := NewMapLoader(map[string]string{
+		"base.tpl":    "base1 {% block content %}{% endblock %} {% include \"footer.tpl\" %}",
+		"footer.tpl":  "footer1",
+		"child.tpl":   `{% extends "base.tpl" %}{% block content %}child{% endblock %}`,
+		"unused.tpl":  "unused",
+		"ssi.tpl":     `{% ssi "plain.txt" %}`,
+		"plain.txt":   "plain1",
+		"literal.tpl": "literal",
+	})
+	set := NewSet("autoreload", loader)
+
+	render := func(name string) (*Template, string) {
+		t.Helper()
+		tpl, err := set.FromCache(name)
+		if err != nil {
+			t.Fatalf("FromCache(%q) failed: %v", name, err)
+		}
+		out, err := tpl.Execute(nil)
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return tpl, out
+	}
+
+	tpl1, out := render("child.tpl")
+	if out != "base1 child footer1" {
+		t.Fatalf("got %q", out)
+	}
+	version, fingerprint := tpl1.Version(), tpl1.Fingerprint()
+
+	// Without AutoReload, the cached template is used
+	loader.Set("footer.tpl", "footer2")
+	if tpl2, out := render("child.tpl"); tpl2 != tpl1 || out != "base1 child footer1" {
+		t.Errorf("template was reloaded without AutoReload: %q", out)
+	}
+
+	set.AutoReload = true
+	tpl2, out := render("child.tpl")
+	if tpl2 == tpl1 || out != "base1 child footer2" {
+		t.Errorf("changed include was not reloaded: %q", out)
+	}
+	if tpl2.Version() != version {
+		t.Errorf("Version() changed although child.tpl is unchanged")
+	}
+	if tpl2.Fingerprint() == fingerprint {
+		t.Errorf("Fingerprint() did not change although footer.tpl changed")
+	}
+	if tpl3, _ := render("child.tpl"); tpl3 != tpl2 {
+		t.Errorf("unchanged template was reloaded")
+	}
+
+	loader.Set("base.tpl", "base2 {% block content %}{% endblock %}")
+	if _, out := render("child.tpl"); out != "base2 child" {
+		t.Errorf("changed parent was not reloaded: %q", out)
+	}
+
+	if _, out := render("ssi.tpl"); out != "plain1" {
+		t.Fatalf("got %q", out)
+	}
+	loader.Set("plain.txt", "plain2")
+	if _, out := render("ssi.tpl"); out != "plain2" {
+		t.Errorf("changed ssi file was not reloaded: %q", out)
+	}
+
+	loader.Set("unused.tpl", "changed")
+	lit1, _ := render("literal.tpl")
+	if lit2, _ := render("literal.tpl"); lit1 != lit2 {
+		t.Errorf("unrelated change caused a reload")
+	}
+}
+
+func TestTemplateVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	tplPath := filepath.Join(tmpDir, "test.tpl")
+	if err := os.WriteFile(tplPath, []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+
+	loader := MustNewLocalFileSystemLoader(tmpDir)
+	tpl, err := NewSet("version", loader).FromFile("test.tpl")
+	if err != nil {
+		t.Fatalf("FromFile failed: %v", err)
+	}
+	version, err := loader.Version(tplPath)
+	if err != nil {
+		t.Fatalf("Version failed: %v", err)
+	}
+	if tpl.Version() != version {
+		t.Errorf("Version() = %q, want %q", tpl.Version(), version)
+	}
+
+	s1, _ := FromString("Hello")
+	s2, _ := FromString("Hello")
+	s3, _ := FromString("Hello!")
+	if s1.Fingerprint() != s2.Fingerprint() || s1.Fingerprint() == s3.Fingerprint() {
+		t.Errorf("string template fingerprints must depend on the content only")
+	}
+}
+
+func TestTemplateSetListTemplates(t *testing.T) {
+	set := NewSet("list",
+		NewMapLoader(map[string]string{"a.tpl": "", "shared.tpl": ""}),
+		NewMapLoader(map[string]string{"b.tpl": "", "shared.tpl": ""}),
+		&DummyLoader{}, // doesn't implement TemplateLister
+	)
+
+	got, err := set.ListTemplates("")
+	if err != nil {
+		t.Fatalf("ListTemplates failed: %v", err)
+	}
+	want := []string{"a.tpl", "b.tpl", "shared.tpl"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListTemplates = %q, want %q", got, want)
+	}
+}
+
+func TestAllowTagsFiltersTests(t *testing.T) {
+	set := NewSet("test-allow", &DummyLoader{})
+	if err := set.AllowTags("if", "for", "set"); err != nil {
+		t.Fatalf("AllowTags failed: %v", err)
+	}
+	if err := set.AllowFilters("upper", "default"); err != nil {
+		t.Fatalf("AllowFilters failed: %v", err)
+	}
+	if err := set.AllowTests("odd"); err != nil {
+		t.Fatalf("AllowTests failed: %v", err)
+	}
+	if err := set.BanTag("set"); err != nil {
+		t.Fatalf("BanTag failed: %v", err)
+	}
+
+	if err := set.AllowTags("nonexistent"); err == nil {
+		t.Error("AllowTags should fail for non-existent tag")
+	}
+	if err := set.AllowFilters("nonexistent"); err == nil {
+		t.Error("AllowFilters should fail for non-existent filter")
+	}
+	if err := set.AllowTests("nonexistent"); err == nil {
+		t.Error("AllowTests should fail for non-existent test")
+	}
+
+	if got, want := set.TagNames(), []string{"for", "if"}; !slices.Equal(got, want) {
+		t.Errorf("TagNames() = %v, want %v", got, want)
+	}
+	if got, want := set.FilterNames(), []string{"default", "upper"}; !slices.Equal(got, want) {
+		t.Errorf("FilterNames() = %v, want %v", got, want)
+	}
+	if got, want := set.TestNames(), []string{"odd"}; !slices.Equal(got, want) {
+		t.Errorf("TestNames() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		tpl     string
+		allowed bool
+	}{
+		{"allowed tags, filters and tests", `{% for i in items %}{% if i is odd %}{{ i|default:"x"|upper }}{% endif %}{% endfor %}`, true},
+		{"tag not allowed", `{% with a=1 %}{{ a }}{% endwith %}`, false},
+		{"banned tag stays banned", `{% set a = 1 %}`, false},
+		{"filter not allowed", `{{ "a"|lower }}`, false},
+		{"filter not allowed in tag", `{% for i in items|first %}{% endfor %}`, false},
+		{"filter tag not allowed", `{% filter lower %}A{% endfilter %}`, false},
+		{"test not allowed", `{% if 1 is even %}{% endif %}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := set.FromString(tt.tpl)
+			if tt.allowed && err != nil {
+				t.Errorf("FromString failed: %v", err)
+			}
+			if !tt.allowed {
+				if err == nil || !strings.Contains(err.Error(), "not allowed") {
+					t.Errorf("FromString error = %v, want sandbox restriction", err)
+				}
+			}
+		})
+	}
+
+	if err := set.AllowTags("with"); err == nil {
+		t.Error("AllowTags should fail after the first template has been created")
+	}
+}
+
+func TestAllowNothing(t *testing.T) {
+	set := NewSet("test-allow-nothing", &DummyLoader{})
+	if err := set.AllowTags(); err != nil {
+		t.Fatalf("AllowTags failed: %v", err)
+	}
+	if err := set.AllowFilters(); err != nil {
+		t.Fatalf("AllowFilters failed: %v", err)
+	}
+
+	out, err := set.RenderTemplateString("Hello {{ name }}!", Context{"name": "<b>"})
+	if err != nil {
+		t.Fatalf("RenderTemplateString failed: %v", err)
+	}
+	if out != "Hello &lt;b&gt;!" {
+		t.Errorf("got %q, want autoescaped output", out)
+	}
+	if _, err := set.FromString("{% if true %}{% endif %}"); err == nil {
+		t.Error("tags should not be allowed")
+	}
+	if _, err := set.FromString("{{ name|safe }}"); err == nil {
+		t.Error("filters should not be allowed")
+	}
+}
+
+func TestTemplateSetTests(t *testing.T) {
+	lenient := NewSet("test-tests-lenient", &DummyLoader{})
+	strict := NewSet("test-tests-strict", &DummyLoader{})
+
+	if err := lenient.RegisterTest("valid", func(in *Value, args *Args) (bool, error) {
+		return !in.IsNil(), nil
+	}); err != nil {
+		t.Fatalf("RegisterTest failed: %v", err)
+	}
+	if err := strict.RegisterTest("valid", func(in *Value, args *Args) (bool, error) {
+		return in.Len() >= args.GetDefault(0, "min", 3).Integer(), nil
+	}); err != nil {
+		t.Fatalf("RegisterTest failed: %v", err)
+	}
+	if err := strict.RegisterTest("valid", nil); err == nil {
+		t.Error("RegisterTest should fail for an already registered test")
+	}
+	if err := strict.ReplaceTest("odd", func(in *Value, args *Args) (bool, error) {
+		return true, nil
+	}); err != nil {
+		t.Fatalf("ReplaceTest failed: %v", err)
+	}
+	if err := strict.ReplaceTest("nonexistent", nil); err == nil {
+		t.Error("ReplaceTest should fail for a non-existent test")
+	}
+	if err := strict.BanTest("even"); err != nil {
+		t.Fatalf("BanTest failed: %v", err)
+	}
+	if err := strict.BanTest("even"); err == nil {
+		t.Error("BanTest should fail for an already banned test")
+	}
+	if err := strict.BanTest("nonexistent"); err == nil {
+		t.Error("BanTest should fail for a non-existent test")
+	}
+
+	if TestExists("valid") {
+		t.Error("set tests must not be registered globally")
+	}
+	if !lenient.TestExists("valid") || !strict.TestExists("valid") || lenient.TestExists("nonexistent") {
+		t.Error("TestExists returned unexpected results")
+	}
+
+	tpl := `{{ "ab" is valid }} {{ "ab" is valid(2) }} {{ 2 is odd }} {{ "valid" is test }}`
+	out, err := lenient.RenderTemplateString(tpl, nil)
+	if err != nil {
+		t.Fatalf("RenderTemplateString failed: %v", err)
+	}
+	if out != "True True False True" {
+		t.Errorf("lenient: got %q", out)
+	}
+	out, err = strict.RenderTemplateString(tpl, nil)
+	if err != nil {
+		t.Fatalf("RenderTemplateString failed: %v", err)
+	}
+	if out != "False True True True" {
+		t.Errorf("strict: got %q", out)
+	}
+
+	if _, err := strict.FromString("{{ 2 is even }}"); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("banned test: got %v", err)
+	}
+	if _, err := NewSet("test-tests-other", &DummyLoader{}).FromString("{{ 1 is valid }}"); err == nil {
+		t.Error("set tests must not leak into other sets")
+	}
+	if err := strict.BanTest("odd"); err == nil {
+		t.Error("BanTest should fail after the first template has been created")
+	}
+
+	// Global tests registered after a set has been used are still available to it
+	if err := RegisterTest("test_tests_global_late", func(in *Value, args *Args) (bool, error) {
+		return in.String() == "late", nil
+	}); err != nil {
+		t.Fatalf("RegisterTest failed: %v", err)
+	}
+	t.Cleanup(func() { delete(tests, "test_tests_global_late") })
+	if !lenient.TestExists("test_tests_global_late") || !slices.Contains(lenient.TestNames(), "test_tests_global_late") {
+		t.Error("global test registered late is missing from the set")
+	}
+	out, err = lenient.RenderTemplateString(`{{ "late" is test_tests_global_late }}`, nil)
+	if err != nil {
+		t.Fatalf("RenderTemplateString failed: %v", err)
+	}
+	if out != "True" {
+		t.Errorf("global test registered late: got %q", out)
+	}
+	if err := lenient.RegisterTest("test_tests_global_late", nil); err == nil {
+		t.Error("RegisterTest should fail for a globally registered test")
+	}
+}
+
+func TestTemplateSetAutoescapeModes(t *testing.T) {
+	templates := map[string]string{
+		"page.html":       `<p>{{ s }}</p>{% include "data.json" %}`,
+		"data.json":       `{"s": "{{ s }}"}`,
+		"feed.xml":        "<t>{{ s }}{{ ctrl }}</t>",
+		"doc.tex":         `\textbf{ {{ tex }} }`,
+		"mail.txt":        `Hi {{ s }}`,
+		"emails/body.txt": `{{ s }} {% autoescape "html" %}{{ s }}{% endautoescape %}`,
+		"run.sh":          `rm {{ file }}`,
+		"modes.html":      `{{ s }} {% autoescape "json" %}{{ s }}{% endautoescape %} {% autoescape "none" %}{{ s }}{% endautoescape %} {{ s }}`,
+		"first.html":      `{% firstof s %} {% autoescape "none" %}{% firstof s %}{% endautoescape %}`,
+	}
+	set := NewSet("test-autoescape-modes", NewMapLoader(templates))
+	for pattern, mode := range map[string]string{".json": "json", "*.xml": "xml", ".tex": "latex", ".txt": "none", "run.sh": "shell"} {
+		if err := set.SetAutoescapeMode(pattern, mode); err != nil {
+			t.Fatalf("SetAutoescapeMode(%q, %q) failed: %v", pattern, mode, err)
+		}
+	}
+	if err := set.SetAutoescapeMode(".csv", "csv"); err == nil {
+		t.Error("SetAutoescapeMode should fail for an unknown mode")
+	}
+	if err := set.SetAutoescapeMode("[", "none"); err == nil {
+		t.Error("SetAutoescapeMode should fail for an invalid pattern")
+	}
+	if err := set.RegisterAutoescapeMode("csv", "nonexistent"); err == nil {
+		t.Error("RegisterAutoescapeMode should fail for a non-existent filter")
+	}
+	if err := set.RegisterAutoescapeMode("upper", "upper"); err != nil {
+		t.Fatalf("RegisterAutoescapeMode failed: %v", err)
+	}
+	if err := set.RegisterAutoescapeMode("html", "upper"); err == nil {
+		t.Error("RegisterAutoescapeMode should fail for an existing mode")
+	}
+
+	ctx := Context{
+		"s":    `<a & "b">`,
+		"ctrl": "\x00\x1b",
+		"tex":  `50% of $10_{x}`,
+		"file": "it's here.txt",
+	}
+	tests := []struct {
+		template string
+		output   string
+	}{
+		{"page.html", `<p>&lt;a &amp; &quot;b&quot;&gt;</p>{"s": "\u003ca \u0026 \"b\"\u003e"}`},
+		{"feed.xml", `<t>&lt;a &amp; &quot;b&quot;&gt;</t>`},
+		{"doc.tex", `\textbf{ 50\% of \$10\_\{x\} }`},
+		{"mail.txt", `Hi <a & "b">`},
+		{"emails/body.txt", `<a & "b"> &lt;a &amp; &quot;b&quot;&gt;`},
+		{"run.sh", `rm 'it'\''s here.txt'`},
+		{"modes.html", `&lt;a &amp; &quot;b&quot;&gt; \u003ca \u0026 \"b\"\u003e <a & "b"> &lt;a &amp; &quot;b&quot;&gt;`},
+		{"first.html", `&lt;a &amp; &quot;b&quot;&gt; <a & "b">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			out, err := set.RenderTemplateFile(tt.template, ctx)
+			if err != nil {
+				t.Fatalf("RenderTemplateFile failed: %v", err)
+			}
+			if out != tt.output {
+				t.Errorf("expected %q, got %q", tt.output, out)
+			}
+		})
+	}
+
+	out, err := set.RenderTemplateString(`{% autoescape "upper" %}{{ "x" }}{% endautoescape %}`, nil)
+	if err != nil || out != "X" {
+		t.Errorf("custom mode: got %q, %v", out, err)
+	}
+	if _, err := set.FromString(`{% autoescape "csv" %}{% endautoescape %}`); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unknown mode: got %v", err)
+	}
+	if err := set.SetAutoescapeMode(".md", "none"); err == nil {
+		t.Error("SetAutoescapeMode should fail after the first template has been created")
+	}
+}
+
+func TestTemplateSetAutoescapeModesContextual(t *testing.T) {
+	set := NewSet("test-autoescape-modes-contextual", NewMapLoader(map[string]string{
+		"page.htm":  `<a onclick="f('{{ s }}')">`,
+		"page.json": `{"s": "{{ s }}"}`,
+	}))
+	set.Options.ContextualAutoescape = true
+	if err := set.RegisterAutoescapeMode("html5", "escape"); err != nil {
+		t.Fatalf("RegisterAutoescapeMode failed: %v", err)
+	}
+	for pattern, mode := range map[string]string{".htm": "html5", ".json": "json"} {
+		if err := set.SetAutoescapeMode(pattern, mode); err != nil {
+			t.Fatalf("SetAutoescapeMode(%q, %q) failed: %v", pattern, mode, err)
+		}
+	}
+
+	for name, expected := range map[string]string{
+		"page.htm":  `<a onclick="f('\u0027\u003E')">`,
+		"page.json": `{"s": "'\u003e"}`,
+	} {
+		out, err := set.RenderTemplateFile(name, Context{"s": "'>"})
+		if err != nil {
+			t.Fatalf("%s: RenderTemplateFile failed: %v", name, err)
+		}
+		if out != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, out)
+		}
+	}
+}
+
+func TestTemplateSetAutoescapeModesFilesystem(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "emails"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"emails/a.txt": "{{ s }}",
+		"page.html":    `{{ s }} {% include "emails/a.txt" %}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set := NewSet("test-autoescape-modes-fs", MustNewLocalFileSystemLoader(tmpDir))
+	if err := set.SetAutoescapeMode("emails/*.txt", "none"); err != nil {
+		t.Fatalf("SetAutoescapeMode failed: %v", err)
+	}
+	ctx := Context{"s": "<b>"}
+
+	render := func(tpl *Template, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("failed to load template: %v", err)
+		}
+		out, err := tpl.Execute(ctx)
+		if err != nil {
+			t.Fatalf("failed to execute template: %v", err)
+		}
+		return out
+	}
+	if out := render(set.FromFile("emails/a.txt")); out != "<b>" {
+		t.Errorf("FromFile: got %q", out)
+	}
+	if out := render(set.FromCache("emails/a.txt")); out != "<b>" {
+		t.Errorf("FromCache: got %q", out)
+	}
+	if out := render(set.FromFile(filepath.Join(tmpDir, "emails", "a.txt"))); out != "<b>" {
+		t.Errorf("FromFile with absolute path: got %q", out)
+	}
+	if out := render(set.FromCache("page.html")); out != "&lt;b&gt; <b>" {
+		t.Errorf("include: got %q", out)
+	}
+}
@@ -3,6 +3,7 @@ package pongo2
 import (
 	"bytes"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -72,3 +73,77 @@ func TestTokenString(t *testing.T) {
 		t.Errorf("Token.String() should contain value, got %q", str)
 	}
 }
+
+func TestTemplateCompileTimeWhitespace(t *testing.T) {
+	set := NewSet("test-compile-whitespace", &DummyLoader{})
+	tpl, err := set.FromString("{% if true %}\n  a {{- ' b ' -}} c\n  {% endif %}\nd")
+	if err != nil {
+		t.Fatalf("FromString failed: %v", err)
+	}
+
+	render := func() string {
+		t.Helper()
+		out, err := tpl.Execute(nil)
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return out
+	}
+
+	if out := render(); out != "\n  a b c\n  \nd" {
+		t.Errorf("without options: got %q", out)
+	}
+
+	tpl.Options.TrimBlocks = true
+	tpl.Options.LStripBlocks = true
+	for i := range 2 {
+		// Whitespace must not be stripped again by later executions
+		if out := render(); out != "  a b c\nd" {
+			t.Errorf("with options (execution %d): got %q", i+1, out)
+		}
+	}
+
+	tpl.Options.TrimBlocks = false
+	tpl.Options.LStripBlocks = false
+	if out := render(); out != "\n  a b c\n  \nd" {
+		t.Errorf("after resetting options: got %q", out)
+	}
+
+	t.Run("failed recompilation", func(t *testing.T) {
+		tpl, err := set.FromString(`<p {{ x }}>`)
+		if err != nil {
+			t.Fatalf("FromString failed: %v", err)
+		}
+		tpl.Options.ContextualAutoescape = true
+		if _, err := tpl.Execute(nil); err == nil {
+			t.Fatal("expected a compile error")
+		}
+		tpl.Options.ContextualAutoescape = false
+		if out, err := tpl.Execute(Context{"x": "y"}); err != nil || out != "<p y>" {
+			t.Errorf("got %q, %v", out, err)
+		}
+	})
+
+	t.Run("concurrent executions", func(t *testing.T) {
+		tpl, err := set.FromString("{% for i in items %}\n  {{ i }}\n{% endfor %}")
+		if err != nil {
+			t.Fatalf("FromString failed: %v", err)
+		}
+		tpl.Options.TrimBlocks = true
+		tpl.Options.LStripBlocks = true
+
+		var wg sync.WaitGroup
+		results := make([]string, 8)
+		for i := range results {
+			wg.Go(func() {
+				results[i], _ = tpl.Execute(Context{"items": []int{1, 2}})
+			})
+		}
+		wg.Wait()
+		for _, out := range results {
+			if out != "  1\n  2\n" {
+				t.Errorf("got %q", out)
+			}
+		}
+	})
+}
@@ -14,16 +14,19 @@ func init() {
 	tests = make(map[string]TestFunction)
 }
 
-// TestExists returns true if the given test is already registered
+// TestExists returns true if the given test is already registered globally.
+// Use TemplateSet.TestExists to check tests in a specific template set.
 func TestExists(name string) bool {
 	_, existing := tests[name]
 	return existing
 }
 
-// RegisterTest registers a new test. If there's already a test with the same
-// name, RegisterTest will panic. You usually want to call this
-// function in the test's init() function:
-// http://golang.org/doc/effective_go.html#init
+// RegisterTest registers a new test globally. If there's already a test with
+// the same name, RegisterTest returns an error. Template sets look up global
+// tests when a template is parsed, so a test registered later is available to
+// templates parsed afterwards. You usually want to call this function in the
+// test's init() function: http://golang.org/doc/effective_go.html#init
+// Use TemplateSet.RegisterTest to register a test for a single template set.
 func RegisterTest(name string, fn TestFunction) error {
 	if TestExists(name) {
 		return fmt.Errorf("test with name '%s' is already registered", name)
@@ -32,8 +35,10 @@ func RegisterTest(name string, fn TestFunction) error {
 	return nil
 }
 
-// ReplaceTest replaces an already registered test with a new implementation. Use this
+// ReplaceTest replaces an already registered global test with a new implementation. Use this
 // function with caution since it allows you to change existing test behaviour.
+// Like RegisterTest, it only affects templates parsed afterwards and doesn't
+// override tests replaced via TemplateSet.ReplaceTest.
 func ReplaceTest(name string, fn TestFunction) error {
 	if !TestExists(name) {
 		return fmt.Errorf("test with name '%s' does not exist (therefore cannot be overridden)", name)
@@ -114,7 +119,7 @@ func (tc *testCall) Evaluate(ctx *ExecutionContext) (*Value, error) {
 		passed = tc.FilterApplied("safe") || tc.term.FilterApplied("escape")
 		if !passed {
 			if vv, err := tc.term.Evaluate(ctx); err == nil {
-				passed = vv.safe
+				passed = vv.isSafe()
 			}
 		}
 
@@ -150,7 +155,7 @@ func (tc *testCall) Evaluate(ctx *ExecutionContext) (*Value, error) {
 			return nil, err
 		}
 
-		passed, err = PerformTest(tc.name, t, args)
+		passed, err = tc.testFunc(t, args)
 		if err != nil {
 			if e, ok := err.(*Error); ok {
 				err = e.updateFromTokenIfNeeded(ctx.template, tc.token)
@@ -193,10 +198,15 @@ func (p *Parser) parseTest(term IEvaluator) (IEvaluator, error) {
 		negate: negate,
 	}
 
+	// Check sandbox test restriction
+	if !p.template.set.isTestAllowed(identToken.Val) {
+		return nil, p.Error(fmt.Sprintf("Usage of test '%s' is not allowed (sandbox restriction active).", identToken.Val), identToken).withCode(ErrCodeSandbox)
+	}
+
 	// Value the appropriate tests function and bind it
-	testFn, exists := tests[identToken.Val]
+	testFn, exists := p.template.set.test(identToken.Val)
 	if !exists {
-		return nil, p.Error(fmt.Sprintf("Test '%s' does not exist.", identToken.Val), identToken)
+		return nil, p.Error(fmt.Sprintf("Test '%s' does not exist.", identToken.Val), identToken).withCode(ErrCodeUnknownTest)
 	}
 
 	test.testFunc = testFn
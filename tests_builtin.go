@@ -93,6 +93,9 @@ func testTest(in *Value, args *Args) (bool, error) {
 		return false, err
 	}
 
+	if set := args.TemplateSet(); set != nil {
+		return set.TestExists(in.String()), nil
+	}
 	return TestExists(in.String()), nil
 }
 
@@ -212,7 +215,7 @@ func testIn(in *Value, args *Args) (bool, error) {
 	container := args.First()
 
 	matched := false
-	container.Iterate(func(idx, count int, key, value *Value) bool {
+	container.iterateOrder(args.ctx, func(idx, count int, key, value *Value) bool {
 		v := value
 		if value == nil {
 			v = key
@@ -223,7 +226,7 @@ func testIn(in *Value, args *Args) (bool, error) {
 		}
 
 		return true
-	}, nil)
+	}, func() {}, false, false)
 
 	return matched, nil
 }
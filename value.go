@@ -1,9 +1,11 @@
 package pongo2
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,8 +14,9 @@ import (
 )
 
 type Value struct {
-	val  reflect.Value
-	safe bool // used to indicate whether a Value needs explicit escaping in the template
+	val       reflect.Value
+	safe      bool   // used to indicate whether a Value needs explicit escaping in the template
+	undefined string // path of an undefined variable, output as placeholder by {{ }} (Options.DebugUndefined)
 }
 
 // AsValue converts any given value to a pongo2.Value
@@ -49,12 +52,22 @@ func (v *Value) getResolvedValue() reflect.Value {
 // IsString checks whether the underlying value is a string
 func (v *Value) IsString() bool {
 	rv := v.getResolvedValue()
-	return rv.Kind() == reflect.String || v.IsTemplate()
+	return rv.Kind() == reflect.String || v.IsTemplate() || v.isPongoString()
+}
+
+// isPongoString reports whether the value implements PongoStringer or
+// PongoSafeHTML.
+func (v *Value) isPongoString() bool {
+	if _, ok := TypeAssert[PongoStringer](v); ok {
+		return true
+	}
+	_, ok := TypeAssert[PongoSafeHTML](v)
+	return ok
 }
 
 func (v *Value) IsStringer() (isStringer bool) {
 	rv := v.getResolvedValue()
-	isStringer = rv.Kind() == reflect.String || v.IsTemplate()
+	isStringer = rv.Kind() == reflect.String || v.IsTemplate() || v.isPongoString()
 	if !isStringer {
 		_, isStringer = TypeAssert[fmt.Stringer](v)
 	}
@@ -171,7 +184,8 @@ func (v *Value) EvaluateTemplate(ctx Context) (*Value, error) {
 //  3. float (any precision)
 //  4. bool
 //  5. time.Time
-//  6. String() will be called on the underlying value if provided
+//  6. SafeHTML(), PongoString() or String() will be called on the underlying
+//     value if provided (in this order)
 //
 // NIL values will lead to an empty string. Unsupported types are leading
 // to their respective type name.
@@ -181,6 +195,12 @@ func (v *Value) String() string {
 	}
 
 	if v.val.IsValid() {
+		if t, ok := TypeAssert[PongoSafeHTML](v); ok {
+			return t.SafeHTML()
+		}
+		if t, ok := TypeAssert[PongoStringer](v); ok {
+			return t.PongoString()
+		}
 		if t, ok := TypeAssert[fmt.Stringer](v); ok {
 			return t.String()
 		}
@@ -351,8 +371,13 @@ func (v *Value) Time() time.Time {
 //   - bool == true
 //   - underlying value is a struct
 //
-// Otherwise returns always FALSE.
+// Otherwise returns always FALSE. Values implementing PongoTruth decide
+// themselves.
 func (v *Value) IsTrue() bool {
+	if t, ok := TypeAssert[PongoTruth](v); ok {
+		return t.Truth()
+	}
+
 	rv := v.getResolvedValue()
 	switch rv.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -381,6 +406,10 @@ func (v *Value) IsTrue() bool {
 //
 //	AsValue(1).Negate().IsTrue() == false
 func (v *Value) Negate() *Value {
+	if t, ok := TypeAssert[PongoTruth](v); ok {
+		return AsValue(!t.Truth())
+	}
+
 	rv := v.getResolvedValue()
 	switch rv.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -482,11 +511,22 @@ func (v *Value) SetIndex(i int, val *Value) {
 //
 //	AsValue("Hello, World!").Contains(AsValue("World")) == true
 func (v *Value) Contains(other *Value) bool {
+	return v.contains(nil, other)
+}
+
+// contains implements Contains. Struct fields and map keys denied by the
+// access policy of ctx (if not nil) are reported as missing; struct fields
+// are looked up by tag name if ctx has StructTags enabled.
+func (v *Value) contains(ctx *ExecutionContext, other *Value) bool {
+	policy := ctx.accessPolicy()
 	baseValue := v.getResolvedValue()
 	switch baseValue.Kind() {
 	case reflect.Struct:
-		fieldValue := baseValue.FieldByName(other.String())
-		return fieldValue.IsValid()
+		sf, found := structFieldByName(baseValue.Type(), other.String(), ctx != nil && ctx.StructTags)
+		if found && policy != nil && !policy.AllowField(baseValue.Type(), sf) {
+			return false
+		}
+		return found
 	case reflect.Map:
 		// We can't check against invalid types
 		if !other.val.IsValid() {
@@ -497,6 +537,10 @@ func (v *Value) Contains(other *Value) bool {
 			return false
 		}
 
+		if policy != nil && !policy.AllowMapKey(baseValue.Type(), other.getResolvedValue()) {
+			return false
+		}
+
 		// Use MapIndex directly - type check already verified key type matches
 		mapValue := baseValue.MapIndex(other.getResolvedValue())
 		return mapValue.IsValid()
@@ -539,6 +583,9 @@ func (v *Value) IsSliceOrArray() bool {
 
 // IsIterable checks whether the underlying value is iterable
 func (v *Value) IsIterable() bool {
+	if _, ok := TypeAssert[PongoIterable](v); ok {
+		return true
+	}
 	switch v.getResolvedValue().Kind() {
 	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
 		return true
@@ -559,6 +606,12 @@ func (v *Value) IsStruct() bool {
 // Element returns a Value of the key name from a map[string]T. If the underlying value is not a map, an empty value
 // will be returned.
 func (v *Value) Element(name string) *Value {
+	if g, ok := TypeAssert[PongoGetter](v); ok {
+		if val, found := g.Get(name); found {
+			return AsValue(val)
+		}
+	}
+
 	rv := v.getResolvedValue()
 	for rv.Kind() == reflect.Interface && !rv.IsNil() {
 		rv = rv.Elem()
@@ -605,10 +658,22 @@ func (v *Value) Attribute(attribute string) *Value {
 // For structs, it uses the key's string representation as the field name.
 // Returns nil Value if the key/field doesn't exist or the type doesn't support item access.
 func (v *Value) GetItem(key *Value) *Value {
+	return v.getItem(nil, key)
+}
+
+// getItem implements GetItem. Struct fields and map keys denied by the access
+// policy of ctx (if not nil) are returned as nil Value; struct fields are
+// looked up by tag name if ctx has StructTags enabled.
+func (v *Value) getItem(ctx *ExecutionContext, key *Value) *Value {
+	policy := ctx.accessPolicy()
 	if key.IsNil() {
 		return AsValue(nil)
 	}
 
+	if val, found := v.getCustomItem(key); found {
+		return AsValue(val)
+	}
+
 	rv := v.getResolvedValue()
 	switch rv.Kind() {
 	case reflect.Map:
@@ -631,6 +696,10 @@ func (v *Value) GetItem(key *Value) *Value {
 			}
 		}
 
+		if policy != nil && !policy.AllowMapKey(rv.Type(), mapKey) {
+			return AsValue(nil)
+		}
+
 		val := rv.MapIndex(mapKey)
 		if val.IsValid() {
 			return &Value{val: val}
@@ -638,8 +707,11 @@ func (v *Value) GetItem(key *Value) *Value {
 		return AsValue(nil)
 
 	case reflect.Struct:
-		field := rv.FieldByName(key.String())
-		if field.IsValid() {
+		sf, found := structFieldByName(rv.Type(), key.String(), ctx != nil && ctx.StructTags)
+		if !found || (policy != nil && !policy.AllowField(rv.Type(), sf)) {
+			return AsValue(nil)
+		}
+		if field, err := rv.FieldByIndexErr(sf.Index); err == nil {
 			return &Value{val: field}
 		}
 		return AsValue(nil)
@@ -649,6 +721,20 @@ func (v *Value) GetItem(key *Value) *Value {
 	}
 }
 
+// getCustomItem looks key up with PongoIndexer.Index or (for string keys)
+// PongoGetter.Get.
+func (v *Value) getCustomItem(key *Value) (any, bool) {
+	if ix, ok := TypeAssert[PongoIndexer](v); ok {
+		if val, found := ix.Index(key.Interface()); found {
+			return val, true
+		}
+	}
+	if g, ok := TypeAssert[PongoGetter](v); ok && key.getResolvedValue().Kind() == reflect.String {
+		return g.Get(key.String())
+	}
+	return nil, false
+}
+
 // Iterate iterates over a map, array, slice or a string. It calls the
 // function's first argument for every value with the following arguments:
 //
@@ -667,10 +753,26 @@ func (v *Value) Iterate(fn func(idx, count int, key, value *Value) bool, empty f
 // not affect the iteration through a map because maps don't have any particular order.
 // However, you can force an order using the `sorted` keyword (and even use `reversed sorted`).
 func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
+	v.iterateOrder(nil, fn, empty, reverse, sorted)
+}
+
+// iterateOrder implements IterateOrder. Map keys denied by the access policy
+// of ctx (if not nil) are skipped.
+func (v *Value) iterateOrder(ctx *ExecutionContext, fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
+	if seq, ok := v.sequence(); ok {
+		v.iterateSequence(seq, fn, empty, reverse, sorted)
+		return
+	}
+
 	rv := v.getResolvedValue()
 	switch rv.Kind() {
 	case reflect.Map:
 		keys := sortedKeys(rv.MapKeys())
+		if policy := ctx.accessPolicy(); policy != nil {
+			keys = slices.DeleteFunc(keys, func(key reflect.Value) bool {
+				return !policy.AllowMapKey(rv.Type(), key)
+			})
+		}
 		if sorted {
 			if reverse {
 				sort.Sort(sort.Reverse(keys))
@@ -899,6 +1001,11 @@ func (v *Value) Interface() any {
 	return nil
 }
 
+// MarshalJSON encodes the underlying value as JSON (null for nil values).
+func (v *Value) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Interface())
+}
+
 // EqualValueTo checks whether two values are containing the same value or object (if comparable).
 func (v *Value) EqualValueTo(other *Value) bool {
 	// Handle numeric comparison: float vs int should compare by value (e.g., 8.0 == 8)
@@ -3,7 +3,9 @@ package pongo2
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -20,6 +22,9 @@ const (
 var (
 	typeOfValuePtr   = reflect.TypeFor[*Value]()
 	typeOfExecCtxPtr = reflect.TypeFor[*ExecutionContext]()
+	typeOfArgsPtr    = reflect.TypeFor[*Args]()
+
+	typeOfKeywordOptions = reflect.TypeFor[KeywordOptions]()
 )
 
 type variablePart struct {
@@ -29,8 +34,9 @@ type variablePart struct {
 	subscript IEvaluator
 	isNil     bool
 
-	isFunctionCall bool
-	callingArgs    []functionCallArgument // needed for a function call, represents all argument nodes (INode supports nested function calls)
+	isFunctionCall   bool
+	callingArgs      []functionCallArgument          // needed for a function call, represents all argument nodes (INode supports nested function calls)
+	namedCallingArgs map[string]functionCallArgument // keyword arguments of a function call (name=expr)
 }
 
 func (p *variablePart) String() string {
@@ -79,6 +85,10 @@ type variableResolver struct {
 	locationToken *Token
 
 	parts []*variablePart
+
+	// allowUndefined is set for variables which may be undefined even with
+	// StrictUndefined, i.e. the ones the default filter is applied to.
+	allowUndefined bool
 }
 
 type nodeFilteredVariable struct {
@@ -91,6 +101,7 @@ type nodeFilteredVariable struct {
 type nodeVariable struct {
 	locationToken *Token
 	expr          IEvaluator
+	escaper       *contextEscaper // set with Options.ContextualAutoescape
 }
 
 type executionCtxEval struct{}
@@ -195,9 +206,15 @@ func (nv *nodeVariable) Evaluate(ctx *ExecutionContext) (*Value, error) {
 		return nil, err
 	}
 
-	if !nv.expr.FilterApplied("safe") && !value.safe && value.IsString() && ctx.Autoescape {
+	if nv.escaper != nil && ctx.Autoescape {
+		// escape for the position in the HTML document
+		value, err = nv.escaper.escape(ctx, value, nv.expr.FilterApplied("safe"))
+		if err != nil {
+			return nil, err
+		}
+	} else if !nv.expr.FilterApplied("safe") && !value.isSafe() && value.IsString() && ctx.Autoescape {
 		// apply escape filter
-		escapeFn := ctx.template.set.filters[ctx.template.Options.AutoescapeFilter]
+		escapeFn := ctx.template.set.filters[ctx.AutoescapeFilter]
 		if escapeFn != nil {
 			value, err = escapeFn(value, nil)
 			if err != nil {
@@ -214,10 +231,21 @@ func (nv *nodeVariable) Execute(ctx *ExecutionContext, writer TemplateWriter) er
 	if err != nil {
 		return err
 	}
+	if value.undefined != "" && value.IsNil() {
+		// Options.DebugUndefined: output a placeholder (escaped like any
+		// other output, subscripts in the path may come from user data)
+		value = AsValue("{{ " + value.undefined + " }}")
+	}
 
-	if !nv.expr.FilterApplied("safe") && !value.safe && value.IsString() && ctx.Autoescape {
+	if nv.escaper != nil && ctx.Autoescape {
+		// escape for the position in the HTML document
+		value, err = nv.escaper.escape(ctx, value, nv.expr.FilterApplied("safe"))
+		if err != nil {
+			return err
+		}
+	} else if !nv.expr.FilterApplied("safe") && !value.isSafe() && value.IsString() && ctx.Autoescape {
 		// apply escape filter
-		escapeFn := ctx.template.set.filters[ctx.template.Options.AutoescapeFilter]
+		escapeFn := ctx.template.set.filters[ctx.AutoescapeFilter]
 		if escapeFn != nil {
 			value, err = escapeFn(value, nil)
 			if err != nil {
@@ -260,23 +288,38 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 
 	var current reflect.Value
 	var isSafe bool
+	var subscripts []*Value // evaluated so far, for undefined paths
 
 	for idx, part := range vr.parts {
 		if idx == 0 {
-			current = vr.lookupInitialValue(ctx)
+			var found bool
+			if current, found = vr.lookupInitialValue(ctx); !found {
+				return vr.undefined(ctx, idx, subscripts)
+			}
 		} else {
-			resolved, isNil, err := vr.resolveNextPart(ctx, current, part)
+			// Subscripts are evaluated once for PongoIndexer/PongoGetter and reflection
+			var subscript *Value
+			if part.typ == varTypeSubscript {
+				sv, err := part.subscript.Evaluate(ctx)
+				if err != nil {
+					return nil, err
+				}
+				subscript = sv
+				subscripts = append(subscripts, sv)
+			}
+
+			resolved, isNil, err := vr.resolveNextPart(ctx, current, part, subscript)
 			if err != nil {
 				return nil, err
 			}
-			if isNil {
-				return AsValue(nil), nil
+			if isNil || !resolved.IsValid() {
+				return vr.undefined(ctx, idx, subscripts)
 			}
 			current = resolved
 		}
 
 		if !current.IsValid() {
-			return AsValue(nil), nil
+			return vr.nilPart(ctx, idx, subscripts)
 		}
 
 		// Unpack *Value if needed
@@ -287,8 +330,19 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 			current = reflect.ValueOf(current.Interface())
 		}
 
+		// Evaluate lazy values (memoized for the whole render)
+		var err error
+		current, isSafe, err = vr.resolveLazy(ctx, current, isSafe)
+		if err != nil {
+			return nil, err
+		}
+		if !current.IsValid() {
+			return vr.nilPart(ctx, idx, subscripts)
+		}
+
 		// Handle function call
-		if part.isFunctionCall || current.Kind() == reflect.Func {
+		// (iter.Seq and iter.Seq2 functions are loop sources, not called)
+		if part.isFunctionCall || (current.Kind() == reflect.Func && !isSequenceFunc(current.Type())) {
 			permitted := !ctx.DisableContextFunctions
 			if idx > 0 {
 				permitted = !ctx.DisableNestedFunctions
@@ -306,7 +360,7 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 		}
 
 		if !current.IsValid() {
-			return AsValue(nil), nil
+			return vr.nilPart(ctx, idx, subscripts)
 		}
 
 		if ctx.DeepResolve {
@@ -323,6 +377,59 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 	return &Value{val: current, safe: isSafe}, nil
 }
 
+// undefined returns the result of a variable whose path up to parts[idx] is
+// undefined: an error if StrictUndefined is set (unless the variable is
+// allowed to be undefined), a placeholder value if DebugUndefined is set
+// and nil otherwise.
+func (vr *variableResolver) undefined(ctx *ExecutionContext, idx int, subscripts []*Value) (*Value, error) {
+	switch {
+	case ctx.StrictUndefined && !vr.allowUndefined:
+		return nil, withErrorCode(ErrCodeUndefinedVariable, fmt.Errorf("'%s' is undefined", vr.path(idx, subscripts)))
+	case ctx.DebugUndefined:
+		return &Value{undefined: vr.path(idx, subscripts)}, nil
+	}
+	return AsValue(nil), nil
+}
+
+// nilPart returns the result of a variable whose part idx resolved to nil:
+// nil if it's the last part, otherwise the next part is undefined.
+func (vr *variableResolver) nilPart(ctx *ExecutionContext, idx int, subscripts []*Value) (*Value, error) {
+	if idx == len(vr.parts)-1 || (!ctx.StrictUndefined && !ctx.DebugUndefined) {
+		return AsValue(nil), nil
+	}
+	if next := vr.parts[idx+1]; next.typ == varTypeSubscript {
+		sv, err := next.subscript.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		subscripts = append(subscripts, sv)
+	}
+	return vr.undefined(ctx, idx+1, subscripts)
+}
+
+// path returns the variable's path up to parts[idx] (like user.name or
+// user["name"]), using the values of the subscripts.
+func (vr *variableResolver) path(idx int, subscripts []*Value) string {
+	var b strings.Builder
+	for i, part := range vr.parts[:idx+1] {
+		switch {
+		case part.typ == varTypeSubscript:
+			sv := subscripts[0]
+			subscripts = subscripts[1:]
+			if sv.IsString() {
+				b.WriteString("[" + strconv.Quote(sv.String()) + "]")
+			} else {
+				b.WriteString("[" + sv.String() + "]")
+			}
+		case i > 0:
+			b.WriteString("." + part.String())
+		default:
+			b.WriteString(part.String())
+		}
+	}
+	return b.String()
+}
+
 func (vr *variableResolver) resolveTemplate(ctx *ExecutionContext, current reflect.Value) (reflect.Value, bool, error) {
 	switch current.Kind() {
 	case reflect.Ptr:
@@ -452,12 +559,13 @@ func (vr *variableResolver) lookupInContext(m Context, key string, ignoreVariabl
 }
 
 // lookupInitialValue looks up the first part of the variable in the context.
-func (vr *variableResolver) lookupInitialValue(ctx *ExecutionContext) reflect.Value {
-	val, inPrivate := vr.lookupInContext(ctx.Private, vr.parts[0].s, ctx.IgnoreVariableCase)
-	if !inPrivate {
-		val, _ = vr.lookupInContext(ctx.Public, vr.parts[0].s, ctx.IgnoreVariableCase)
+// found is false if the variable isn't defined.
+func (vr *variableResolver) lookupInitialValue(ctx *ExecutionContext) (val reflect.Value, found bool) {
+	v, found := vr.lookupInContext(ctx.Private, vr.parts[0].s, ctx.IgnoreVariableCase)
+	if !found {
+		v, found = vr.lookupInContext(ctx.Public, vr.parts[0].s, ctx.IgnoreVariableCase)
 	}
-	return reflect.ValueOf(val)
+	return reflect.ValueOf(v), found
 }
 
 // unpackValue unpacks a *Value if the current value is of that type.
@@ -470,17 +578,30 @@ func (vr *variableResolver) unpackValue(current reflect.Value, isSafe bool) (ref
 	return current, isSafe
 }
 
-// resolveNextPart resolves the next part of a variable path from the current value.
+// resolveNextPart resolves the next part of a variable path from the current value
+// (subscript is the evaluated subscript of subscript parts).
 // Returns (resolved value, isNil, error).
 func (vr *variableResolver) resolveNextPart(
 	ctx *ExecutionContext,
 	current reflect.Value,
 	part *variablePart,
+	subscript *Value,
 ) (reflect.Value, bool, error) {
+	// Types implementing PongoGetter or PongoIndexer resolve parts themselves
+	if val, found := vr.resolveCustomPart(current, part, subscript); found {
+		return val, false, nil
+	}
+
 	// Check for method call first
 	if part.typ == varTypeIdent {
 		funcValue := current.MethodByName(part.s)
 		if funcValue.IsValid() {
+			if policy := ctx.template.set.AccessPolicy; policy != nil {
+				method, _ := current.Type().MethodByName(part.s)
+				if !policy.AllowMethod(current.Type(), method) {
+					return reflect.Value{}, false, vr.accessDenied("method", part.s, current.Type())
+				}
+			}
 			return funcValue, false, nil
 		}
 	}
@@ -493,7 +614,39 @@ func (vr *variableResolver) resolveNextPart(
 		}
 	}
 
-	return vr.resolvePartByType(ctx, current, part)
+	return vr.resolvePartByType(ctx, current, part, subscript)
+}
+
+// resolveCustomPart resolves part using PongoGetter.Get (identifiers and
+// string subscripts) or PongoIndexer.Index (integer parts and subscripts).
+func (vr *variableResolver) resolveCustomPart(current reflect.Value, part *variablePart, subscript *Value) (reflect.Value, bool) {
+	if !current.IsValid() || !current.CanInterface() {
+		return reflect.Value{}, false
+	}
+	obj := &Value{val: current}
+
+	switch part.typ {
+	case varTypeIdent:
+		if g, ok := TypeAssert[PongoGetter](obj); ok {
+			if val, found := g.Get(part.s); found {
+				return reflect.ValueOf(val), true
+			}
+		}
+	case varTypeInt:
+		if ix, ok := TypeAssert[PongoIndexer](obj); ok {
+			if val, found := ix.Index(part.i); found {
+				return reflect.ValueOf(val), true
+			}
+		}
+	case varTypeSubscript:
+		if subscript.IsNil() {
+			return reflect.Value{}, false
+		}
+		if val, found := obj.getCustomItem(subscript); found {
+			return reflect.ValueOf(val), true
+		}
+	}
+	return reflect.Value{}, false
 }
 
 // resolvePartByType resolves a variable part based on its type.
@@ -501,14 +654,15 @@ func (vr *variableResolver) resolvePartByType(
 	ctx *ExecutionContext,
 	current reflect.Value,
 	part *variablePart,
+	subscript *Value,
 ) (reflect.Value, bool, error) {
 	switch part.typ {
 	case varTypeInt:
 		return vr.resolveIntIndex(current, part)
 	case varTypeIdent:
-		return vr.resolveIdentifier(current, part, ctx.IgnoreVariableCase)
+		return vr.resolveIdentifier(ctx, current, part)
 	case varTypeSubscript:
-		return vr.resolveSubscript(ctx, current, part)
+		return vr.resolveSubscript(ctx, current, subscript)
 	default:
 		panic("unimplemented")
 	}
@@ -530,19 +684,46 @@ func (vr *variableResolver) resolveIntIndex(current reflect.Value, part *variabl
 		}
 		return reflect.Value{}, true, nil
 	default:
-		return reflect.Value{}, false, fmt.Errorf("can't access an index on type %s (variable %s)",
-			current.Kind().String(), vr.String())
+		return reflect.Value{}, false, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("can't access an index on type %s (variable %s)",
+			current.Kind().String(), vr.String()))
 	}
 }
 
-func (vr *variableResolver) resolveStructField(current reflect.Value, fieldName string, ignoreCase bool) reflect.Value {
-	rv := current.FieldByName(fieldName)
-	if !rv.IsValid() && ignoreCase {
+func (vr *variableResolver) resolveStructField(ctx *ExecutionContext, current reflect.Value, fieldName string) (reflect.Value, error) {
+	var rv reflect.Value
+	var sf reflect.StructField
+	var found bool
+	typ := current.Type()
+
+	// Exact matches take precedence over case-insensitive ones, and tag names
+	// over Go names
+	var tagged *structFieldIndex
+	if ctx.StructTags {
+		tagged = getStructFieldIndex(typ)
+		sf, found = tagged.lookup(fieldName, false)
+	}
+	if !found {
+		sf, found = typ.FieldByName(fieldName)
+	}
+	if !found && ctx.IgnoreVariableCase && tagged != nil {
+		sf, found = tagged.lookup(fieldName, true)
+	}
+	if !found && ctx.IgnoreVariableCase {
 		lowerName := strings.ToLower(fieldName)
-		rv = current.FieldByNameFunc(func(name string) bool {
+		sf, found = typ.FieldByNameFunc(func(name string) bool {
 			return strings.ToLower(name) == lowerName
 		})
 	}
+	if found && tagged != nil && tagged.isHidden(sf) {
+		return reflect.Value{}, nil
+	}
+	if found {
+		if policy := ctx.template.set.AccessPolicy; policy != nil && !policy.AllowField(typ, sf) {
+			return reflect.Value{}, vr.accessDenied("field", sf.Name, typ)
+		}
+		// an error means a nil embedded pointer on the way to the field
+		rv, _ = current.FieldByIndexErr(sf.Index)
+	}
 	if !rv.IsValid() {
 		// see if there is an anonymous embedded struct that has a field with this name
 		typ := current.Type()
@@ -556,40 +737,66 @@ func (vr *variableResolver) resolveStructField(current reflect.Value, fieldName
 				}
 
 				if f.Kind() == reflect.Struct {
-					if rv = vr.resolveStructField(f, fieldName, ignoreCase); rv.IsValid() {
+					var err error
+					if rv, err = vr.resolveStructField(ctx, f, fieldName); err != nil {
+						return reflect.Value{}, err
+					}
+					if rv.IsValid() {
 						break
 					}
 				}
 			}
 		}
 	}
-	return rv
+	return rv, nil
 }
 
-func (vr *variableResolver) resolveMapStringKey(current reflect.Value, key string, ignoreCase bool) reflect.Value {
-	rv := current.MapIndex(reflect.ValueOf(key))
-	if !rv.IsValid() && ignoreCase {
-		lowerName := strings.ToLower(key)
+func (vr *variableResolver) resolveMapStringKey(
+	current reflect.Value,
+	key string,
+	ignoreCase bool,
+	policy AccessPolicy,
+) (reflect.Value, error) {
+	return vr.resolveMapKey(current, reflect.ValueOf(key), ignoreCase, policy)
+}
+
+// resolveMapKey looks up key in the map current. String keys are matched
+// case-insensitively if ignoreCase is set.
+func (vr *variableResolver) resolveMapKey(
+	current reflect.Value,
+	key reflect.Value,
+	ignoreCase bool,
+	policy AccessPolicy,
+) (reflect.Value, error) {
+	rv := current.MapIndex(key)
+	if !rv.IsValid() && ignoreCase && key.Kind() == reflect.String {
+		lowerName := strings.ToLower(key.String())
 		for _, mapKey := range current.MapKeys() {
 			if strings.ToLower(mapKey.String()) == lowerName {
+				key = mapKey
 				rv = current.MapIndex(mapKey)
 				break
 			}
 		}
 	}
-	return rv
+	if policy != nil && !policy.AllowMapKey(current.Type(), key) {
+		return reflect.Value{}, vr.accessDenied("map key", key.Interface(), current.Type())
+	}
+	return rv, nil
 }
 
 // resolveIdentifier resolves a field or map key access by name.
-func (vr *variableResolver) resolveIdentifier(current reflect.Value, part *variablePart, ignoreCase bool) (reflect.Value, bool, error) {
+func (vr *variableResolver) resolveIdentifier(ctx *ExecutionContext, current reflect.Value, part *variablePart) (reflect.Value, bool, error) {
 	switch current.Kind() {
 	case reflect.Struct:
-		return vr.resolveStructField(current, part.s, ignoreCase), false, nil
+		rv, err := vr.resolveStructField(ctx, current, part.s)
+		return rv, false, err
 	case reflect.Map:
-		return vr.resolveMapStringKey(current, part.s, ignoreCase), false, nil
+		rv, err := vr.resolveMapStringKey(current, part.s, ctx.IgnoreVariableCase, ctx.template.set.AccessPolicy)
+		return rv, false, err
 	default:
-		return reflect.Value{}, false, fmt.Errorf("can't access a field by name on type %s (variable %s)",
-			current.Kind().String(), vr.String())
+		return reflect.Value{}, false, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("can't access a field by name on type %s (variable %s)",
+			current.Kind().String(), vr.String()))
 	}
 }
 
@@ -597,13 +804,8 @@ func (vr *variableResolver) resolveIdentifier(current reflect.Value, part *varia
 func (vr *variableResolver) resolveSubscript(
 	ctx *ExecutionContext,
 	current reflect.Value,
-	part *variablePart,
+	sv *Value,
 ) (reflect.Value, bool, error) {
-	sv, err := part.subscript.Evaluate(ctx)
-	if err != nil {
-		return reflect.Value{}, false, err
-	}
-
 	switch current.Kind() {
 	case reflect.String:
 		// For strings, return the character at the index (Django-compatible behavior)
@@ -620,22 +822,20 @@ func (vr *variableResolver) resolveSubscript(
 		}
 		return reflect.Value{}, true, nil
 	case reflect.Struct:
-		return vr.resolveStructField(current, sv.String(), ctx.IgnoreVariableCase), false, nil
+		rv, err := vr.resolveStructField(ctx, current, sv.String())
+		return rv, false, err
 	case reflect.Map:
 		if sv.IsNil() {
 			return reflect.Value{}, true, nil
 		}
 		if sv.val.Type().AssignableTo(current.Type().Key()) {
-			if sv.val.Kind() == reflect.String {
-				return vr.resolveMapStringKey(current, sv.val.String(), ctx.IgnoreVariableCase), false, nil
-			} else {
-				return current.MapIndex(sv.val), false, nil
-			}
+			rv, err := vr.resolveMapKey(current, sv.val, ctx.IgnoreVariableCase, ctx.template.set.AccessPolicy)
+			return rv, false, err
 		}
 		return reflect.Value{}, true, nil
 	default:
-		return reflect.Value{}, false, fmt.Errorf("can't access an index on type %s (variable %s)",
-			current.Kind().String(), vr.String())
+		return reflect.Value{}, false, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("can't access an index on type %s (variable %s)",
+			current.Kind().String(), vr.String()))
 	}
 }
 
@@ -660,7 +860,7 @@ func (vr *variableResolver) handleFunctionCall(
 	}
 
 	if current.Kind() != reflect.Func {
-		return nil, fmt.Errorf("'%s' is not a function (it is %s)", vr.String(), current.Kind().String())
+		return nil, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("'%s' is not a function (it is %s)", vr.String(), current.Kind().String()))
 	}
 
 	t := current.Type()
@@ -671,15 +871,26 @@ func (vr *variableResolver) handleFunctionCall(
 		currArgs = append([]functionCallArgument{executionCtxEval{}}, currArgs...)
 	}
 
+	// Keyword arguments (and surplus positional arguments in case of *Args) are
+	// passed using the last parameter
+	trailing, currArgs, err := vr.prepareTrailingParameter(ctx, t, currArgs, part.namedCallingArgs)
+	if err != nil {
+		return nil, err
+	}
+	numIn := t.NumIn()
+	if trailing.IsValid() {
+		numIn--
+	}
+
 	// Validate input argument count
-	if len(currArgs) != t.NumIn() && (len(currArgs) < t.NumIn()-1 || !t.IsVariadic()) {
-		return nil, fmt.Errorf("function input argument count (%d) of '%s' must be equal to the calling argument count (%d)",
-			t.NumIn(), vr.String(), len(currArgs))
+	if len(currArgs) != numIn && (len(currArgs) < numIn-1 || !t.IsVariadic()) {
+		return nil, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("function input argument count (%d) of '%s' must be equal to the calling argument count (%d)",
+			numIn, vr.String(), len(currArgs)))
 	}
 
 	// Validate output argument count
 	if t.NumOut() != 1 && t.NumOut() != 2 {
-		return nil, fmt.Errorf("'%s' must have exactly 1 or 2 output arguments, the second argument must be of type error", vr.String())
+		return nil, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("'%s' must have exactly 1 or 2 output arguments, the second argument must be of type error", vr.String()))
 	}
 
 	// Evaluate and prepare parameters
@@ -687,6 +898,9 @@ func (vr *variableResolver) handleFunctionCall(
 	if err != nil {
 		return nil, err
 	}
+	if trailing.IsValid() {
+		parameters = append(parameters, trailing)
+	}
 
 	// Execute the function call
 	return vr.executeCall(current, t, parameters)
@@ -727,6 +941,152 @@ func (vr *variableResolver) prepareCallParameters(
 	return parameters, nil
 }
 
+// prepareTrailingParameter builds the value of the last parameter of t if it
+// receives keyword arguments:
+//
+//   - *Args receives all keyword arguments plus the positional arguments not
+//     taken by the preceding parameters
+//   - an options struct (or pointer to one) embedding KeywordOptions gets its
+//     fields set by name (see optionsField); it may be omitted by the caller,
+//     a pointer then is nil
+//
+// It returns an invalid value if the last parameter is an ordinary one, plus
+// the positional arguments left for the remaining parameters.
+func (vr *variableResolver) prepareTrailingParameter(
+	ctx *ExecutionContext,
+	t reflect.Type,
+	currArgs []functionCallArgument,
+	namedArgs map[string]functionCallArgument,
+) (reflect.Value, []functionCallArgument, error) {
+	numFixed := t.NumIn() - 1
+	if numFixed >= 0 && !t.IsVariadic() {
+		last := t.In(numFixed)
+		switch {
+		case last == typeOfArgsPtr && len(currArgs) >= numFixed:
+			args := &Args{set: ctx.template.set}
+			for _, arg := range currArgs[numFixed:] {
+				v, err := arg.Evaluate(ctx)
+				if err != nil {
+					return reflect.Value{}, nil, err
+				}
+				args.args = append(args.args, v)
+			}
+			if len(namedArgs) > 0 {
+				args.kwArgs = make(map[string]*Value, len(namedArgs))
+				for name, arg := range namedArgs {
+					v, err := arg.Evaluate(ctx)
+					if err != nil {
+						return reflect.Value{}, nil, err
+					}
+					args.kwArgs[name] = v
+				}
+			}
+			return reflect.ValueOf(args), currArgs[:numFixed], nil
+
+		case isOptionsStruct(last) && len(currArgs) == numFixed:
+			opts, err := vr.buildOptions(ctx, last, namedArgs)
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+			return opts, currArgs, nil
+		}
+	}
+
+	if len(namedArgs) > 0 {
+		return reflect.Value{}, nil, fmt.Errorf("'%s' does not accept keyword arguments (its last parameter must be *pongo2.Args or a struct embedding pongo2.KeywordOptions)",
+			vr.String())
+	}
+	return reflect.Value{}, currArgs, nil
+}
+
+// isOptionsStruct returns true if typ is a struct or a pointer to a struct
+// embedding KeywordOptions, which is filled with keyword arguments. Other
+// structs are ordinary parameters.
+func isOptionsStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := range typ.NumField() {
+		if sf := typ.Field(i); sf.Anonymous && sf.Type == typeOfKeywordOptions {
+			return true
+		}
+	}
+	return false
+}
+
+// buildOptions creates a value of the options struct typ (or pointer to it)
+// with the fields set from the keyword arguments.
+func (vr *variableResolver) buildOptions(
+	ctx *ExecutionContext,
+	typ reflect.Type,
+	namedArgs map[string]functionCallArgument,
+) (reflect.Value, error) {
+	structType := typ
+	if typ.Kind() == reflect.Ptr {
+		if len(namedArgs) == 0 {
+			return reflect.Zero(typ), nil
+		}
+		structType = typ.Elem()
+	}
+
+	opts := reflect.New(structType).Elem()
+	for _, name := range slices.Sorted(maps.Keys(namedArgs)) {
+		sf, ok := optionsField(structType, name)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%w: %s ('%s' has no option '%s')", ErrArgName, name, vr.String(), name)
+		}
+		v, err := namedArgs[name].Evaluate(ctx)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		fv, err := coerceValue(reflect.ValueOf(v.Interface()), sf.Type)
+		if err == errIncompatibleType {
+			return reflect.Value{}, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("keyword argument '%s' of '%s' must be of type %s (not %T)",
+				name, vr.String(), sf.Type, v.Interface()))
+		} else if err != nil {
+			return reflect.Value{}, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("keyword argument '%s' of '%s' can't be converted to %s: %w",
+				name, vr.String(), sf.Type, err))
+		}
+		opts.FieldByIndex(sf.Index).Set(fv)
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		return opts.Addr(), nil
+	}
+	return opts, nil
+}
+
+// optionsField returns the exported field of the options struct typ set by
+// the keyword argument name. The name matches a field's `pongo2` (or `json`)
+// struct tag name, or its Go name ignoring case and underscores (so per_page
+// sets PerPage). Fields tagged with "-" can't be set.
+func optionsField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+	var byGoName reflect.StructField
+	foundGoName := false
+	for _, sf := range reflect.VisibleFields(typ) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		tagName, tagged := structTagName(sf)
+		if tagName == "-" {
+			continue
+		}
+		if tagged && tagName == name {
+			return sf, true
+		}
+		if !foundGoName && normalize(sf.Name) == normalize(name) {
+			byGoName, foundGoName = sf, true
+		}
+	}
+	return byGoName, foundGoName
+}
+
 // getFnArgType returns the expected type for a function argument at the given index.
 func (vr *variableResolver) getFnArgType(t reflect.Type, idx, numArgs int, isVariadic bool) reflect.Type {
 	if isVariadic && idx >= t.NumIn()-1 {
@@ -736,26 +1096,30 @@ func (vr *variableResolver) getFnArgType(t reflect.Type, idx, numArgs int, isVar
 }
 
 // convertArgToParam converts an evaluated Value to a reflect.Value suitable for function call.
+// See coerceValue for the supported conversions.
 func (vr *variableResolver) convertArgToParam(pv *Value, fnArg reflect.Type, idx int, isVariadic bool) (reflect.Value, error) {
 	if fnArg == typeOfValuePtr {
 		return reflect.ValueOf(pv), nil
 	}
 
-	// Check type compatibility
-	if fnArg != reflect.TypeOf(pv.Interface()) && fnArg.Kind() != reflect.Interface {
-		if isVariadic {
-			return reflect.Value{}, fmt.Errorf("function variadic input argument of '%s' must be of type %s or *pongo2.Value (not %T)",
-				vr.String(), fnArg.String(), pv.Interface())
+	param, err := coerceValue(reflect.ValueOf(pv.Interface()), fnArg)
+	if err == nil {
+		return param, nil
+	}
+	if isVariadic {
+		if err == errIncompatibleType {
+			return reflect.Value{}, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("function variadic input argument of '%s' must be of type %s or *pongo2.Value (not %T)",
+				vr.String(), fnArg.String(), pv.Interface()))
 		}
-		return reflect.Value{}, fmt.Errorf("function input argument %d of '%s' must be of type %s or *pongo2.Value (not %T)",
-			idx, vr.String(), fnArg.String(), pv.Interface())
+		return reflect.Value{}, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("function variadic input argument of '%s' can't be converted to %s: %w",
+			vr.String(), fnArg.String(), err))
 	}
-
-	if pv.IsNil() {
-		var empty any
-		return reflect.ValueOf(&empty).Elem(), nil
+	if err == errIncompatibleType {
+		return reflect.Value{}, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("function input argument %d of '%s' must be of type %s or *pongo2.Value (not %T)",
+			idx, vr.String(), fnArg.String(), pv.Interface()))
 	}
-	return reflect.ValueOf(pv.Interface()), nil
+	return reflect.Value{}, withErrorCode(ErrCodeTypeMismatch, fmt.Errorf("function input argument %d of '%s' can't be converted to %s: %w",
+		idx, vr.String(), fnArg.String(), err))
 }
 
 // executeCall performs the actual function call and processes the result.
@@ -798,7 +1162,7 @@ func (vr *variableResolver) executeCall(
 func (vr *variableResolver) Evaluate(ctx *ExecutionContext) (*Value, error) {
 	value, err := vr.resolve(ctx)
 	if err != nil {
-		return AsValue(nil), ctx.Error(err.Error(), vr.locationToken)
+		return AsValue(nil), ctx.OrigError(err, vr.locationToken)
 	}
 	return value, nil
 }
@@ -1103,12 +1467,32 @@ variableLoop:
 				}
 
 				if p.Peek(TokenSymbol, ")") == nil {
-					// No closing bracket, so we're parsing an expression
-					exprArg, err := p.ParseExpression()
-					if err != nil {
-						return nil, err
+					// No closing bracket, so we're parsing an expression, optionally
+					// preceded by a keyword (name=expr)
+					if p.PeekType(TokenIdentifier) != nil && p.PeekN(1, TokenSymbol, "=") != nil {
+						nameToken := p.MatchType(TokenIdentifier)
+						p.Consume() // consume '='
+						exprArg, err := p.ParseExpression()
+						if err != nil {
+							return nil, err
+						}
+						if _, exists := part.namedCallingArgs[nameToken.Val]; exists {
+							return nil, p.Error(fmt.Sprintf("Keyword argument '%s' given more than once.", nameToken.Val), nameToken)
+						}
+						if part.namedCallingArgs == nil {
+							part.namedCallingArgs = make(map[string]functionCallArgument)
+						}
+						part.namedCallingArgs[nameToken.Val] = exprArg
+					} else {
+						if len(part.namedCallingArgs) > 0 {
+							return nil, p.Error("Positional argument follows keyword argument.", nil)
+						}
+						exprArg, err := p.ParseExpression()
+						if err != nil {
+							return nil, err
+						}
+						part.callingArgs = append(part.callingArgs, exprArg)
 					}
-					part.callingArgs = append(part.callingArgs, exprArg)
 
 					if p.Match(TokenSymbol, ")") != nil {
 						// If there's a closing bracket after an expression, we will stop parsing the arguments
@@ -1159,8 +1543,8 @@ filterLoop:
 		}
 
 		// Check sandbox filter restriction
-		if _, isBanned := p.template.set.bannedFilters[filter.name]; isBanned {
-			return nil, p.Error(fmt.Sprintf("Usage of filter '%s' is not allowed (sandbox restriction active).", filter.name), nil)
+		if !p.template.set.isFilterAllowed(filter.name) {
+			return nil, p.Error(fmt.Sprintf("Usage of filter '%s' is not allowed (sandbox restriction active).", filter.name), nil).withCode(ErrCodeSandbox)
 		}
 
 		v.filterChain = append(v.filterChain, filter)
@@ -1168,6 +1552,11 @@ filterLoop:
 		continue filterLoop
 	}
 
+	// The default filter handles undefined variables (Options.StrictUndefined)
+	if vr, ok := v.resolver.(*variableResolver); ok && len(v.filterChain) > 0 && v.filterChain[0].name == "default" {
+		vr.allowUndefined = true
+	}
+
 	return v, nil
 }
 
@@ -1188,5 +1577,13 @@ func (p *Parser) parseVariableElement() (INode, error) {
 		return nil, p.Error("'}}' expected", nil)
 	}
 
+	if p.html != nil {
+		escaper, err := p.html.outputEscaper()
+		if err != nil {
+			return nil, p.Error(fmt.Sprintf("Contextual autoescape: output not allowed in %s.", err), node.locationToken)
+		}
+		node.escaper = escaper
+	}
+
 	return node, nil
 }
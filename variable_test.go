@@ -1,6 +1,10 @@
 package pongo2
 
 import (
+	"errors"
+	"fmt"
+	"image"
+	"iter"
 	"reflect"
 	"strings"
 	"testing"
@@ -714,3 +718,448 @@ func (s stringWithMethods) Upper() stringWithMethods {
 func (s stringWithMethods) Len() int {
 	return len(s)
 }
+
+// policyUser is a helper type for testing access policies.
+type policyUser struct {
+	Name         string `template:"name"`
+	PasswordHash string
+	Profile      policyProfile `template:"profile"`
+}
+
+type policyProfile struct {
+	Bio string `template:"bio"`
+}
+
+func (u policyUser) DisplayName() string {
+	return "~" + u.Name
+}
+
+func (u policyUser) Database() *strings.Builder {
+	return &strings.Builder{}
+}
+
+// testAccessPolicy only allows exported fields carrying a `template` struct
+// tag, denies methods returning a *strings.Builder and hides the "secret" map key.
+type testAccessPolicy struct{}
+
+func (testAccessPolicy) AllowField(typ reflect.Type, field reflect.StructField) bool {
+	return field.IsExported() && field.Tag.Get("template") != ""
+}
+
+func (testAccessPolicy) AllowMethod(typ reflect.Type, method reflect.Method) bool {
+	return method.Type.NumOut() == 0 || method.Type.Out(0) != reflect.TypeFor[*strings.Builder]()
+}
+
+func (testAccessPolicy) AllowMapKey(typ reflect.Type, key reflect.Value) bool {
+	return key.Kind() != reflect.String || key.String() != "secret"
+}
+
+func TestAccessPolicy(t *testing.T) {
+	user := &policyUser{Name: "alice", PasswordHash: "hash", Profile: policyProfile{Bio: "hi"}}
+	ctx := Context{
+		"user": user,
+		"m":    map[string]string{"public": "yes", "secret": "no", "Upper": "u"},
+		"key":  "PasswordHash",
+		"users": []policyUser{
+			{Name: "bob", PasswordHash: "2"},
+			{Name: "alice", PasswordHash: "1"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		denied   bool
+	}{
+		{name: "allowed field", template: "{{ user.Name }}", expected: "alice"},
+		{name: "allowed nested field", template: "{{ user.Profile.Bio }}", expected: "hi"},
+		{name: "allowed method", template: "{{ user.DisplayName() }}", expected: "~alice"},
+		{name: "allowed map key", template: "{{ m.public }}", expected: "yes"},
+		{name: "missing field", template: "{{ user.Missing }}", expected: ""},
+		{name: "denied field", template: "{{ user.PasswordHash }}", denied: true},
+		{name: "denied field via subscript", template: "{{ user[key] }}", denied: true},
+		{name: "denied method", template: "{{ user.Database() }}", denied: true},
+		{name: "denied map key", template: "{{ m.secret }}", denied: true},
+		{name: "denied map key via subscript", template: `{{ m["secret"] }}`, denied: true},
+		{name: "dictsort by allowed field", template: `{% for u in users|dictsort:"Name" %}{{ u.Name }} {% endfor %}`, expected: "alice bob "},
+		{name: "dictsort by denied field", template: `{% for u in users|dictsort:"PasswordHash" %}{{ u.Name }} {% endfor %}`, expected: "bob alice "},
+		{name: "in allowed field", template: `{% if "Name" in user %}yes{% endif %}`, expected: "yes"},
+		{name: "in denied field", template: `{% if "PasswordHash" in user %}yes{% else %}no{% endif %}`, expected: "no"},
+		{name: "in allowed map key", template: `{% if "public" in m %}yes{% endif %}`, expected: "yes"},
+		{name: "in denied map key", template: `{% if "secret" in m %}yes{% else %}no{% endif %}`, expected: "no"},
+		{name: "for over map skips denied keys", template: `{% for k, v in m sorted %}{{ k }}={{ v }} {% endfor %}`, expected: "Upper=u public=yes "},
+		{name: "in test allowed map value", template: `{% if "yes" is in m %}yes{% else %}no{% endif %}`, expected: "yes"},
+		{name: "in test denied map value", template: `{% if "no" is in m %}yes{% else %}no{% endif %}`, expected: "no"},
+	}
+
+	set := NewSet("test-policy", &DummyLoader{})
+	set.AccessPolicy = testAccessPolicy{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := set.FromString(tt.template)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+
+			result, err := tpl.Execute(ctx)
+			if tt.denied {
+				if !errors.Is(err, ErrAccessDenied) {
+					t.Errorf("expected ErrAccessDenied, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("ignore variable case", func(t *testing.T) {
+		set := NewSet("test-policy-case", &DummyLoader{})
+		set.AccessPolicy = testAccessPolicy{}
+		set.Options.IgnoreVariableCase = true
+
+		for _, tpl := range []string{"{{ user.passwordhash }}", "{{ m.SECRET }}"} {
+			_, err := set.RenderTemplateString(tpl, ctx)
+			if !errors.Is(err, ErrAccessDenied) {
+				t.Errorf("%s: expected ErrAccessDenied, got %v", tpl, err)
+			}
+		}
+	})
+}
+
+type taggedAddress struct {
+	City string `json:"city"`
+}
+
+type taggedUser struct {
+	taggedAddress
+	FirstName string `pongo2:"first_name" json:"firstName"`
+	LastName  string `json:"last_name,omitempty"`
+	Email     string `json:",omitempty"`
+	Password  string `pongo2:"-"`
+	Token     string `json:"-"`
+	ID        int    `json:"Email"`
+}
+
+func TestStructTags(t *testing.T) {
+	user := taggedUser{
+		taggedAddress: taggedAddress{City: "Berlin"},
+		FirstName:     "Ada",
+		LastName:      "Lovelace",
+		Email:         "ada@example.com",
+		Password:      "secret",
+		Token:         "token",
+		ID:            42,
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		ignoreCase bool
+		expected   string
+	}{
+		{name: "pongo2 tag", template: "{{ user.first_name }}", expected: "Ada"},
+		{name: "pongo2 tag takes precedence over json tag", template: "{{ user.firstName }}", expected: ""},
+		{name: "json tag with options", template: "{{ user.last_name }}", expected: "Lovelace"},
+		{name: "Go name still works", template: "{{ user.FirstName }}", expected: "Ada"},
+		{name: "tag without name", template: "{{ user.Email }}", expected: "42"},
+		{name: "promoted field", template: "{{ user.city }}", expected: "Berlin"},
+		{name: "hidden by pongo2 tag", template: "{{ user.Password }}", expected: ""},
+		{name: "hidden by json tag", template: "{{ user.Token }}", expected: ""},
+		{name: "hidden via subscript", template: `{{ user["Password"] }}`, expected: ""},
+		{name: "subscript", template: `{{ user["first_name"] }}`, expected: "Ada"},
+		{name: "ignore case", template: "{{ user.FIRST_NAME }}", ignoreCase: true, expected: "Ada"},
+		{name: "ignore case hidden", template: "{{ user.password }}", ignoreCase: true, expected: ""},
+		{name: "in", template: `{% if "first_name" in user %}yes{% endif %}`, expected: "yes"},
+		{name: "in hidden", template: `{% if "Password" in user %}yes{% else %}no{% endif %}`, expected: "no"},
+		{name: "dictsort", template: `{% for u in users|dictsort:"first_name" %}{{ u.FirstName }} {% endfor %}`, expected: "Ada Grace "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := NewSet("test-struct-tags", &DummyLoader{})
+			set.Options.StructTags = true
+			set.Options.IgnoreVariableCase = tt.ignoreCase
+
+			result, err := set.RenderTemplateString(tt.template, Context{
+				"user":  &user,
+				"users": []taggedUser{{FirstName: "Grace"}, user},
+			})
+			if err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		set := NewSet("test-struct-tags-off", &DummyLoader{})
+		result, err := set.RenderTemplateString("{{ user.first_name }}|{{ user.Password }}", Context{"user": user})
+		if err != nil {
+			t.Fatalf("failed to execute template: %v", err)
+		}
+		if result != "|secret" {
+			t.Errorf("expected %q, got %q", "|secret", result)
+		}
+	})
+}
+
+// ormModel mimics an ORM model whose relations are loaded on access.
+type ormModel struct {
+	name      string
+	relations map[string]any
+	Internal  string
+}
+
+func (m *ormModel) PongoString() string { return "<" + m.name + ">" }
+
+func (m *ormModel) Get(name string) (any, bool) {
+	rel, ok := m.relations[name]
+	return rel, ok
+}
+
+func (m *ormModel) Index(key any) (any, bool) {
+	if i, ok := key.(int); ok {
+		return fmt.Sprintf("row %d", i), true
+	}
+	return nil, false
+}
+
+func (m *ormModel) Iter() iter.Seq[any] {
+	return func(yield func(any) bool) {
+		_ = yield("a") && yield("b")
+	}
+}
+
+func (m *ormModel) Truth() bool { return m.name != "" }
+
+func (m *ormModel) Display() string { return "display " + m.name }
+
+type safeBadge string
+
+func (b safeBadge) SafeHTML() string { return "<b>" + string(b) + "</b>" }
+
+func TestPongoInterfaces(t *testing.T) {
+	newModel := func(name string) *ormModel {
+		return &ormModel{
+			name:      name,
+			relations: map[string]any{"owner": "ann", "count": 3},
+			Internal:  "internal",
+		}
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{name: "PongoStringer", template: "{{ m }}", expected: "&lt;shop&gt;"},
+		{name: "PongoStringer filter", template: "{{ m|upper }}", expected: "&lt;SHOP&gt;"},
+		{name: "PongoGetter", template: "{{ m.owner }} {{ m.count + 1 }}", expected: "ann 4"},
+		{name: "PongoGetter subscript", template: `{{ m["owner"] }}`, expected: "ann"},
+		{name: "PongoGetter fallback to methods", template: "{{ m.Display() }}", expected: "display shop"},
+		{name: "PongoGetter fallback to fields", template: "{{ m.Internal }}", expected: "internal"},
+		{name: "PongoIndexer", template: "{{ m.0 }}|{{ m[2] }}", expected: "row 0|row 2"},
+		{name: "PongoIterable", template: "{% for x in m %}{{ x }}{% endfor %}", expected: "ab"},
+		{name: "PongoTruth", template: "{% if m %}yes{% endif %}{% if not empty %}no{% endif %}", expected: "yesno"},
+		{name: "PongoSafeHTML", template: "{{ badge }}", expected: "<b>new</b>"},
+		{name: "PongoSafeHTML test", template: "{% if badge is escaped %}safe{% endif %}", expected: "safe"},
+	}
+
+	set := NewSet("test-pongo-interfaces", &DummyLoader{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := Context{"m": newModel("shop"), "empty": newModel(""), "badge": safeBadge("new")}
+			result, err := set.RenderTemplateString(tt.template, ctx)
+			if err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("Value API", func(t *testing.T) {
+		v := AsValue(newModel("shop"))
+		if v.Element("owner").String() != "ann" {
+			t.Errorf("Element: got %q", v.Element("owner").String())
+		}
+		if v.GetItem(AsValue(1)).String() != "row 1" {
+			t.Errorf("GetItem: got %q", v.GetItem(AsValue(1)).String())
+		}
+		if !v.IsIterable() || !v.IsString() || !v.IsTrue() {
+			t.Error("expected an iterable, string-like, true value")
+		}
+	})
+}
+
+type paginateOptions struct {
+	KeywordOptions
+	PerPage  int
+	Page     int    `pongo2:"p"`
+	Ordering string `json:"order_by"`
+	Hidden   bool   `pongo2:"-"`
+}
+
+const buttonMacro = `{% macro button(text, kind="primary", disabled=false) %}{{ text }}/{{ kind }}/{{ disabled }}{% endmacro %}`
+
+func TestKeywordArguments(t *testing.T) {
+	ctx := Context{
+		"items": []int{1, 2, 3},
+		"paginate": func(items []int, args *Args) string {
+			return fmt.Sprintf("%d items, per_page=%d page=%d extra=%v",
+				len(items), args.GetDefault(-1, "per_page", 10).Integer(), args.GetDefault(-1, "page", 1).Integer(), args.Values())
+		},
+		"paginate_opts": func(items []int, opts paginateOptions) string {
+			return fmt.Sprintf("%d items, %+v", len(items), opts)
+		},
+		"paginate_ptr": func(opts *paginateOptions) string {
+			if opts == nil {
+				return "nil"
+			}
+			return fmt.Sprintf("%+v", *opts)
+		},
+		"positional": func(a, b int) int { return a + b },
+		"point":      func(p image.Point) string { return p.String() },
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		errMsg   string
+	}{
+		{name: "*Args", template: "{{ paginate(items, per_page=20, page=2) }}", expected: "3 items, per_page=20 page=2 extra=[]"},
+		{name: "*Args defaults", template: "{{ paginate(items) }}", expected: "3 items, per_page=10 page=1 extra=[]"},
+		{name: "*Args surplus positional", template: "{{ paginate(items, 5, page=3) }}", expected: "3 items, per_page=10 page=3 extra=[5]"},
+		{
+			name:     "options struct",
+			template: `{{ paginate_opts(items, per_page=20, p=2, order_by="name") }}`,
+			expected: "3 items, {KeywordOptions:{} PerPage:20 Page:2 Ordering:name Hidden:false}",
+		},
+		{name: "options struct by Go name", template: "{{ paginate_opts(items, PerPage=5) }}", expected: "3 items, {KeywordOptions:{} PerPage:5 Page:0 Ordering: Hidden:false}"},
+		{name: "options struct omitted", template: "{{ paginate_opts(items) }}", expected: "3 items, {KeywordOptions:{} PerPage:0 Page:0 Ordering: Hidden:false}"},
+		{name: "options struct pointer", template: "{{ paginate_ptr(per_page=3.0) }}", expected: "{KeywordOptions:{} PerPage:3 Page:0 Ordering: Hidden:false}"},
+		{name: "options struct pointer omitted", template: "{{ paginate_ptr() }}", expected: "nil"},
+		{name: "unknown option", template: "{{ paginate_opts(items, size=1) }}", errMsg: "invalid parameter name: size"},
+		{name: "hidden option", template: "{{ paginate_opts(items, hidden=true) }}", errMsg: "invalid parameter name: hidden"},
+		{name: "option type mismatch", template: `{{ paginate_opts(items, per_page="a") }}`, errMsg: "keyword argument 'per_page' of 'paginate_opts' must be of type int (not string)"},
+		{name: "no keyword support", template: "{{ positional(1, b=2) }}", errMsg: "'positional' does not accept keyword arguments"},
+		{name: "struct without marker", template: "{{ point(X=1) }}", errMsg: "'point' does not accept keyword arguments"},
+		{name: "struct without marker omitted", template: "{{ point() }}", errMsg: "must be equal to the calling argument count (0)"},
+		{name: "macro", template: buttonMacro + `{{ button("Submit", kind="success") }}`, expected: "Submit/success/False"},
+		{name: "macro keywords only", template: buttonMacro + `{{ button(disabled=true, text="Off") }}`, expected: "Off/primary/True"},
+		{name: "macro unknown keyword", template: buttonMacro + `{{ button("a", size=1) }}`, errMsg: "Macro 'button' has no argument named 'size'."},
+		{name: "macro keyword repeats positional", template: buttonMacro + `{{ button("a", text="b") }}`, errMsg: "Macro 'button' got multiple values for argument 'text'."},
+	}
+
+	set := NewSet("test-kwargs", &DummyLoader{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := set.RenderTemplateString(tt.template, ctx)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	for _, tpl := range []string{"{{ paginate(items, page=1, page=2) }}", "{{ paginate(page=1, items) }}"} {
+		if _, err := set.FromString(tpl); err == nil {
+			t.Errorf("%s: expected parse error", tpl)
+		}
+	}
+}
+
+func TestUndefinedModes(t *testing.T) {
+	type User struct {
+		Name string
+	}
+	ctx := Context{
+		"user":  &User{Name: "Ann"},
+		"users": map[string]any{"ann": &User{Name: "Ann"}},
+		"items": []int{1, 2},
+		"key":   "bob",
+		"none":  nil,
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		output   string // without StrictUndefined and DebugUndefined
+		debug    string // with DebugUndefined
+		strict   string // error with StrictUndefined (empty: no error)
+	}{
+		{"defined", `{{ user.Name }}`, "Ann", "Ann", ""},
+		{"nil", `{{ none }}`, "", "", ""},
+		{"undefined variable", `{{ usr.Name }}`, "", "{{ usr }}", "'usr' is undefined"},
+		{"undefined field", `{{ user.Nmae }}`, "", "{{ user.Nmae }}", "'user.Nmae' is undefined"},
+		{"undefined key", `{{ users[key] }}`, "", `{{ users[&quot;bob&quot;] }}`, `'users["bob"]' is undefined`},
+		{"index out of range", `{{ items.5 }}`, "", "{{ items.5 }}", "'items.5' is undefined"},
+		{"attribute of nil", `{{ none.Name }}`, "", "{{ none.Name }}", "'none.Name' is undefined"},
+		{"filter", `{{ user.Nmae|upper }}`, "", "", "'user.Nmae' is undefined"},
+		{"filters see an empty value", `{{ user.Nmae|add:"x" }}|{{ user.Nmae|length }}|{{ user.Nmae|slugify }}`, "x|0|", "x|0|", "'user.Nmae' is undefined"},
+		{"if", `{% if user.Nmae %}yes{% else %}no{% endif %}`, "no", "no", "'user.Nmae' is undefined"},
+		{"for", `{% for i in itmes %}{{ i }}{% endfor %}`, "", "", "'itmes' is undefined"},
+		{"default", `{{ user.Nmae|default:"x" }}`, "x", "x", ""},
+		{"default of nil attribute", `{{ none.Name|default:"x" }}`, "x", "x", ""},
+		{"defined test", `{{ user.Nmae is defined }} {{ user.Name is defined }}`, "False True", "False True", ""},
+		{"undefined test", `{{ usr is undefined }} {{ user is undefined }}`, "True False", "True False", ""},
+		{"defined guard", `{% if usr is defined and usr.Name %}yes{% else %}no{% endif %}`, "no", "no", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []string{"", "debug", "strict"} {
+				set := NewSet("test-undefined-"+mode, &DummyLoader{})
+				set.Options.DebugUndefined = mode == "debug"
+				set.Options.StrictUndefined = mode == "strict"
+				tpl, err := set.FromString(tt.template)
+				if err != nil {
+					t.Fatalf("unexpected compile error: %v", err)
+				}
+				result, err := tpl.Execute(ctx)
+
+				if mode == "strict" && tt.strict != "" {
+					var e *Error
+					if !errors.As(err, &e) || e.Code != ErrCodeUndefinedVariable {
+						t.Fatalf("strict: expected an undefined-variable error, got %v", err)
+					}
+					if !strings.Contains(err.Error(), tt.strict) {
+						t.Errorf("strict: expected error containing %q, got %v", tt.strict, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", mode, err)
+				}
+				expected := tt.output
+				if mode == "debug" {
+					expected = tt.debug
+				}
+				if result != expected {
+					t.Errorf("%s: expected %q, got %q", mode, expected, result)
+				}
+			}
+		})
+	}
+}